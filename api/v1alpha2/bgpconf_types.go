@@ -28,6 +28,9 @@ import (
 type NodeConfStatus struct {
 	RouterId string `json:"routerId,omitempty"`
 	As       uint32 `json:"as,omitempty"`
+	// Conflicts are the other BgpConfs selecting the node with the same precedence,
+	// the first one by name is effective.
+	Conflicts []string `json:"conflicts,omitempty"`
 }
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -66,6 +69,54 @@ type BgpConfSpec struct {
 	Families         []uint32          `json:"families,omitempty"`
 	UseMultiplePaths bool              `json:"useMultiplePaths,omitempty"`
 	GracefulRestart  *GracefulRestart  `json:"gracefulRestart,omitempty"`
+	// NodeSelector selects the nodes the config applies to, all nodes if empty.
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+	// Priority decides the effective config of a node selected by several ones, the highest wins.
+	// On the same priority, a config with a nodeSelector wins over one without.
+	Priority int32 `json:"priority,omitempty"`
+	// Mesh builds iBGP sessions between the nodes this config applies to.
+	Mesh *Mesh `json:"mesh,omitempty"`
+	// BmpServers are the BMP stations the speakers stream their peer state and routes to.
+	BmpServers []BmpServer `json:"bmpServers,omitempty"`
+	// ImportPolicies and ExportPolicies are the names of the BgpPolicies applied to the routes
+	// received from and sent to all peers, after those of each BgpPeer.
+	ImportPolicies []string `json:"importPolicies,omitempty"`
+	ExportPolicies []string `json:"exportPolicies,omitempty"`
+}
+
+// BmpServer is a BMP station, RFC 7854, each speaker connects to it and sends the
+// peer up/down notifications and the route monitoring messages.
+type BmpServer struct {
+	Address string `json:"address"`
+	// Port defaults to 11019
+	Port uint32 `json:"port,omitempty"`
+	// RouteMonitoringPolicy is the rib monitored, PRE or POST policy Adj-RIB-In, LOCAL for
+	// the Loc-RIB, with the routes announced by the speaker, or ALL. It defaults to LOCAL.
+	// +kubebuilder:validation:Enum=PRE;POST;LOCAL;ALL
+	RouteMonitoringPolicy string `json:"routeMonitoringPolicy,omitempty"`
+	// StatisticsTimeout is the interval of the statistics reports in seconds, 0 disables them
+	StatisticsTimeout int32  `json:"statisticsTimeout,omitempty"`
+	SysName           string `json:"sysName,omitempty"`
+	SysDescr          string `json:"sysDescr,omitempty"`
+}
+
+const (
+	MeshModeFullMesh       = "FullMesh"
+	MeshModeRouteReflector = "RouteReflector"
+)
+
+// Mesh peers the speakers with each other, they are discovered from the Node list
+// and peered with on their InternalIP and the listenPort of the BgpConf.
+type Mesh struct {
+	// Mode is FullMesh, every speaker peers with all the other ones, or RouteReflector,
+	// the speakers only peer with the route reflectors, and only the route reflectors
+	// peer with the BgpPeers.
+	// +kubebuilder:validation:Enum=FullMesh;RouteReflector
+	Mode string `json:"mode"`
+	// RouteReflectorSelector selects the route reflectors among the nodes in RouteReflector mode.
+	RouteReflectorSelector *metav1.LabelSelector `json:"routeReflectorSelector,omitempty"`
+	// ClusterId of the route reflectors, the router id of each one if empty.
+	ClusterId string `json:"clusterId,omitempty"`
 }
 
 type GracefulRestart struct {
@@ -94,6 +145,12 @@ type BgpConfList struct {
 
 func (c BgpConfSpec) ToGoBgpGlobalConf() (*api.Global, error) {
 	c.AsPerRack = nil
+	c.NodeSelector = nil
+	c.Priority = 0
+	c.Mesh = nil
+	c.BmpServers = nil
+	c.ImportPolicies = nil
+	c.ExportPolicies = nil
 
 	jsonBytes, err := json.Marshal(c)
 	if err != nil {
@@ -19,6 +19,7 @@ package v1alpha2
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 
 	"github.com/golang/protobuf/jsonpb"
 	api "github.com/osrg/gobgp/api"
@@ -83,6 +84,18 @@ type TimersState struct {
 type NodePeerStatus struct {
 	PeerState   PeerState   `json:"peerState,omitempty"`
 	TimersState TimersState `json:"timersState,omitempty"`
+	// BfdState is the state of the BFD session with the peer, empty if BFD is not enabled
+	BfdState string `json:"bfdState,omitempty"`
+	// Template is the peer resolved from spec.template on the node
+	Template *ResolvedPeerTemplate `json:"template,omitempty"`
+}
+
+type ResolvedPeerTemplate struct {
+	NeighborAddress string `json:"neighborAddress,omitempty"`
+	LocalAddress    string `json:"localAddress,omitempty"`
+	PeerAs          uint32 `json:"peerAs,omitempty"`
+	// Error is why the template could not be resolved on the node, there is no session then
+	Error string `json:"error,omitempty"`
 }
 
 // BgpPeerStatus defines the observed state of BgpPeer
@@ -135,6 +148,17 @@ type PeerConf struct {
 	AllowOwnAs        uint32 `json:"allowOwnAs,omitempty"`
 	ReplacePeerAs     bool   `json:"replacePeerAs,omitempty"`
 	AdminDown         bool   `json:"adminDown,omitempty"`
+
+	// PasswordSecretRef refers to the Secret holding the md5 password, it takes precedence over AuthPassword
+	PasswordSecretRef *SecretKeyRef `json:"passwordSecretRef,omitempty"`
+}
+
+type SecretKeyRef struct {
+	Name string `json:"name"`
+	// Namespace of the Secret, only the namespace of openelb is allowed, which it defaults to
+	Namespace string `json:"namespace,omitempty"`
+	// Key of the password in the Secret, defaults to "password"
+	Key string `json:"key,omitempty"`
 }
 
 type Transport struct {
@@ -143,6 +167,7 @@ type Transport struct {
 	RemoteAddress string `json:"remoteAddress,omitempty"`
 	RemotePort    uint32 `json:"remotePort,omitempty"`
 	TcpMss        uint32 `json:"tcpMss,omitempty"`
+	LocalAddress  string `json:"localAddress,omitempty"`
 }
 
 type MpGracefulRestartConfig struct {
@@ -178,11 +203,78 @@ type AfiSafi struct {
 	AddPaths          *AddPaths          `json:"addPaths,omitempty"`
 }
 
+const (
+	ImportActionAccept = "Accept"
+	ImportActionReject = "Reject"
+)
+
+// MaxPrefixes limits the routes received from the peer in each family.
+type MaxPrefixes struct {
+	// Shutdown closes the session once the peer sends more routes than it
+	// +kubebuilder:validation:Minimum=1
+	Shutdown uint32 `json:"shutdown"`
+	// Warning logs once the peer sends more routes than it, it must not be above Shutdown.
+	// gobgp takes it as a percentage of Shutdown, so it is rounded down to one.
+	Warning uint32 `json:"warning,omitempty"`
+}
+
+// toGoBgpPrefixLimit returns the prefix limit of family, the warning threshold is a percentage for gobgp.
+func (m *MaxPrefixes) toGoBgpPrefixLimit(family *api.Family) (*api.PrefixLimit, error) {
+	if m.Warning > m.Shutdown {
+		return nil, fmt.Errorf("field Spec.MaxPrefixes.Warning %d is above Shutdown %d", m.Warning, m.Shutdown)
+	}
+
+	limit := &api.PrefixLimit{
+		Family:      family,
+		MaxPrefixes: m.Shutdown,
+	}
+	if m.Warning > 0 {
+		limit.ShutdownThresholdPct = uint32(uint64(m.Warning) * 100 / uint64(m.Shutdown))
+		if limit.ShutdownThresholdPct == 0 {
+			limit.ShutdownThresholdPct = 1
+		}
+	}
+
+	return limit, nil
+}
+
 type EbgpMultihop struct {
 	Enabled     bool   `json:"enabled,omitempty"`
 	MultihopTtl uint32 `json:"multihopTtl,omitempty"`
 }
 
+// Bfd enables BFD with the peer, the session is torn down as soon as BFD detects the peer down.
+// Only single hop sessions are supported, and the speakers need --bfd-port.
+type Bfd struct {
+	// DesiredMinTxInterval in milliseconds, defaults to 300
+	DesiredMinTxInterval uint32 `json:"desiredMinTxInterval,omitempty"`
+	// RequiredMinRxInterval in milliseconds, defaults to 300
+	RequiredMinRxInterval uint32 `json:"requiredMinRxInterval,omitempty"`
+	// DetectMultiplier defaults to 3
+	// +kubebuilder:validation:Maximum=255
+	DetectMultiplier uint32 `json:"detectMultiplier,omitempty"`
+}
+
+// PeerTemplate takes fields of the peer from each node, so that one BgpPeer describes
+// the peering of every node, e.g. with the ToR of its rack.
+type PeerTemplate struct {
+	// NeighborAddress overrides conf.neighborAddress
+	NeighborAddress *NodeValueSource `json:"neighborAddress,omitempty"`
+	// LocalAddress overrides transport.localAddress, the default gateway source is
+	// the address of the node on the route to its default gateway
+	LocalAddress *NodeValueSource `json:"localAddress,omitempty"`
+	// PeerAs overrides conf.peerAs, the default gateway source is invalid
+	PeerAs *NodeValueSource `json:"peerAs,omitempty"`
+}
+
+// NodeValueSource is where a value is read on each node, exactly one of the fields must be set.
+type NodeValueSource struct {
+	Label      string `json:"label,omitempty"`
+	Annotation string `json:"annotation,omitempty"`
+	// DefaultGateway takes the default gateway of the node, the IPv4 one if there is
+	DefaultGateway bool `json:"defaultGateway,omitempty"`
+}
+
 type BgpPeerSpec struct {
 	Conf            *PeerConf        `json:"conf,omitempty"`
 	EbgpMultihop    *EbgpMultihop    `json:"ebgpMultihop,omitempty"`
@@ -190,6 +282,24 @@ type BgpPeerSpec struct {
 	Transport       *Transport       `json:"transport,omitempty"`
 	GracefulRestart *GracefulRestart `json:"gracefulRestart,omitempty"`
 	AfiSafis        []*AfiSafi       `json:"afiSafis,omitempty"`
+	Bfd             *Bfd             `json:"bfd,omitempty"`
+	Template        *PeerTemplate    `json:"template,omitempty"`
+	// ExtendedNexthop sends the routes with the address of the node on the session as nexthop, so the
+	// IPv4 routes get an IPv6 nexthop over IPv6 sessions, RFC 5549. It is always on over interfaces.
+	ExtendedNexthop *bool `json:"extendedNexthop,omitempty"`
+	// UsingPortForward DNATs the sessions the peer opens to port 179 of the node to the listen port
+	// of the BgpConf, so the speaker could listen on an unprivileged or non-standard port. IPv4 only.
+	UsingPortForward bool `json:"usingPortForward,omitempty"`
+	// MaxPrefixes protects the speaker from a peer leaking too many routes.
+	MaxPrefixes *MaxPrefixes `json:"maxPrefixes,omitempty"`
+	// DefaultImportAction Reject keeps all the routes received from the peer out of the global rib,
+	// even those an import policy accepts, OpenELB only needs to announce.
+	// +kubebuilder:validation:Enum=Accept;Reject
+	DefaultImportAction string `json:"defaultImportAction,omitempty"`
+	// ImportPolicies and ExportPolicies are the names of the BgpPolicies applied to the routes
+	// received from and sent to the peer, before those of the BgpConf. Not supported over interfaces.
+	ImportPolicies []string `json:"importPolicies,omitempty"`
+	ExportPolicies []string `json:"exportPolicies,omitempty"`
 
 	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
 }
@@ -206,6 +316,20 @@ type BgpPeerList struct {
 
 func (c BgpPeerSpec) ToGoBgpPeer() (*api.Peer, error) {
 	c.NodeSelector = nil
+	c.Bfd = nil
+	c.Template = nil
+	c.ExtendedNexthop = nil
+	c.UsingPortForward = false
+	c.DefaultImportAction = ""
+	c.ImportPolicies = nil
+	c.ExportPolicies = nil
+	maxPrefixes := c.MaxPrefixes
+	c.MaxPrefixes = nil
+	if c.Conf != nil && c.Conf.PasswordSecretRef != nil {
+		conf := *c.Conf
+		conf.PasswordSecretRef = nil
+		c.Conf = &conf
+	}
 
 	jsonBytes, err := json.Marshal(c)
 	if err != nil {
@@ -214,7 +338,23 @@ func (c BgpPeerSpec) ToGoBgpPeer() (*api.Peer, error) {
 
 	var result api.Peer
 	m := jsonpb.Unmarshaler{}
-	return &result, m.Unmarshal(bytes.NewReader(jsonBytes), &result)
+	if err = m.Unmarshal(bytes.NewReader(jsonBytes), &result); err != nil {
+		return nil, err
+	}
+
+	if maxPrefixes != nil {
+		for _, afiSafi := range result.AfiSafis {
+			if afiSafi.Config == nil {
+				continue
+			}
+			afiSafi.PrefixLimits, err = maxPrefixes.toGoBgpPrefixLimit(afiSafi.Config.Family)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &result, nil
 }
 
 func GetStatusFromGoBgpPeer(peer *api.Peer) (NodePeerStatus, error) {
@@ -232,6 +372,8 @@ func GetStatusFromGoBgpPeer(peer *api.Peer) (NodePeerStatus, error) {
 	if err != nil {
 		return nodePeerStatus, err
 	}
+	// The status is readable by anyone who can list BgpPeers
+	nodePeerStatus.PeerState.AuthPassword = ""
 
 	jsonStr, err = m.MarshalToString(peer.Timers.State)
 	if err != nil {
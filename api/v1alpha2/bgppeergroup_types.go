@@ -0,0 +1,119 @@
+/*
+Copyright 2022 The Kubesphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/golang/protobuf/jsonpb"
+	api "github.com/osrg/gobgp/api"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PeerGroupConf is shared by the neighbors of the group, the name of the group is the name of the BgpPeerGroup.
+type PeerGroupConf struct {
+	AuthPassword     string `json:"authPassword,omitempty"`
+	Description      string `json:"description,omitempty"`
+	LocalAs          uint32 `json:"localAs,omitempty"`
+	PeerAs           uint32 `json:"peerAs,omitempty"`
+	PeerType         uint32 `json:"peerType,omitempty"`
+	RemovePrivateAs  string `json:"removePrivateAs,omitempty"`
+	RouteFlapDamping bool   `json:"routeFlapDamping,omitempty"`
+	SendCommunity    uint32 `json:"sendCommunity,omitempty"`
+
+	// PasswordSecretRef refers to the Secret holding the md5 password, it takes precedence over AuthPassword
+	PasswordSecretRef *SecretKeyRef `json:"passwordSecretRef,omitempty"`
+}
+
+type BgpPeerGroupSpec struct {
+	Conf            *PeerGroupConf   `json:"conf,omitempty"`
+	EbgpMultihop    *EbgpMultihop    `json:"ebgpMultihop,omitempty"`
+	Timers          *Timers          `json:"timers,omitempty"`
+	Transport       *Transport       `json:"transport,omitempty"`
+	GracefulRestart *GracefulRestart `json:"gracefulRestart,omitempty"`
+	AfiSafis        []*AfiSafi       `json:"afiSafis,omitempty"`
+	// DynamicNeighbors are the prefixes whose routers may connect in as neighbors of the group
+	DynamicNeighbors []string `json:"dynamicNeighbors,omitempty"`
+
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+}
+
+// NodePeerGroupStatus lists the sessions accepted from the dynamic neighbors on one node.
+type NodePeerGroupStatus struct {
+	Neighbors []NodePeerStatus `json:"neighbors,omitempty"`
+}
+
+// BgpPeerGroupStatus defines the observed state of BgpPeerGroup
+type BgpPeerGroupStatus struct {
+	NodesPeerGroupStatus map[string]NodePeerGroupStatus `json:"nodesPeerGroupStatus,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:storageversion
+// +kubebuilder:resource:scope=Cluster
+
+// BgpPeerGroup is the Schema for the bgppeergroups API
+type BgpPeerGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BgpPeerGroupSpec   `json:"spec,omitempty"`
+	Status BgpPeerGroupStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// BgpPeerGroupList contains a list of BgpPeerGroup
+type BgpPeerGroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []BgpPeerGroup `json:"items"`
+}
+
+func (c BgpPeerGroupSpec) ToGoBgpPeerGroup(name string) (*api.PeerGroup, error) {
+	c.NodeSelector = nil
+	c.DynamicNeighbors = nil
+	if c.Conf != nil && c.Conf.PasswordSecretRef != nil {
+		conf := *c.Conf
+		conf.PasswordSecretRef = nil
+		c.Conf = &conf
+	}
+
+	jsonBytes, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var result api.PeerGroup
+	m := jsonpb.Unmarshaler{}
+	err = m.Unmarshal(bytes.NewReader(jsonBytes), &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Conf == nil {
+		result.Conf = &api.PeerGroupConf{}
+	}
+	result.Conf.PeerGroupName = name
+
+	return &result, nil
+}
+
+func init() {
+	SchemeBuilder.Register(&BgpPeerGroup{}, &BgpPeerGroupList{})
+}
@@ -0,0 +1,445 @@
+/*
+Copyright 2022 The Kubesphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	api "github.com/osrg/gobgp/api"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+const (
+	MatchTypeAny    = "Any"
+	MatchTypeAll    = "All"
+	MatchTypeInvert = "Invert"
+
+	RouteActionAccept = "Accept"
+	RouteActionReject = "Reject"
+
+	CommunityActionAdd     = "Add"
+	CommunityActionRemove  = "Remove"
+	CommunityActionReplace = "Replace"
+)
+
+// Prefix matches the routes of the prefix whose mask length is in the range.
+type Prefix struct {
+	Prefix string `json:"prefix"`
+	// MaskLengthMin and MaskLengthMax default to the mask length of the prefix, so only it matches
+	MaskLengthMin uint32 `json:"maskLengthMin,omitempty"`
+	MaskLengthMax uint32 `json:"maskLengthMax,omitempty"`
+}
+
+type PrefixSet struct {
+	Name string `json:"name"`
+	// +kubebuilder:validation:MinItems=1
+	Prefixes []Prefix `json:"prefixes"`
+}
+
+type NeighborSet struct {
+	Name string `json:"name"`
+	// Neighbors are the addresses or prefixes of the neighbors
+	// +kubebuilder:validation:MinItems=1
+	Neighbors []string `json:"neighbors"`
+}
+
+type CommunitySet struct {
+	Name string `json:"name"`
+	// Communities in the "AS:VALUE" form, well-known names like "no-export",
+	// or regular expressions matched against "AS:VALUE"
+	// +kubebuilder:validation:MinItems=1
+	Communities []string `json:"communities"`
+}
+
+// MatchSet refers to a set of the BgpPolicy by name.
+type MatchSet struct {
+	Name string `json:"name"`
+	// MatchType defaults to Any, All is only supported by the community sets
+	// +kubebuilder:validation:Enum=Any;All;Invert
+	MatchType string `json:"matchType,omitempty"`
+}
+
+// Conditions of a statement, all of them have to match.
+type Conditions struct {
+	PrefixSet    *MatchSet `json:"prefixSet,omitempty"`
+	NeighborSet  *MatchSet `json:"neighborSet,omitempty"`
+	CommunitySet *MatchSet `json:"communitySet,omitempty"`
+}
+
+type CommunityAction struct {
+	// +kubebuilder:validation:Enum=Add;Remove;Replace
+	Type string `json:"type"`
+	// Communities in the "AS:VALUE" form or well-known names, Remove also takes regular expressions
+	Communities []string `json:"communities,omitempty"`
+}
+
+type AsPathPrepend struct {
+	// As defaults to the AS of the BgpConf
+	As uint32 `json:"as,omitempty"`
+	// UseLeftMost prepends the left most AS of the path instead of As
+	UseLeftMost bool `json:"useLeftMost,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=10
+	Repeat uint32 `json:"repeat"`
+}
+
+// Actions of a statement, the route is modified before it is accepted or rejected.
+type Actions struct {
+	// RouteAction ends the evaluation of the route, without it the next statement is evaluated
+	// +kubebuilder:validation:Enum=Accept;Reject
+	RouteAction   string           `json:"routeAction,omitempty"`
+	Community     *CommunityAction `json:"community,omitempty"`
+	AsPathPrepend *AsPathPrepend   `json:"asPathPrepend,omitempty"`
+}
+
+type Statement struct {
+	// Conditions select the routes the actions apply to, all routes if empty
+	Conditions *Conditions `json:"conditions,omitempty"`
+	Actions    Actions     `json:"actions"`
+}
+
+// BgpPolicySpec defines the sets and the statements of the policy, it is attached by name to
+// all peers through the importPolicies and exportPolicies of the BgpConf, or to one of them
+// through those of the BgpPeer.
+type BgpPolicySpec struct {
+	PrefixSets    []PrefixSet    `json:"prefixSets,omitempty"`
+	NeighborSets  []NeighborSet  `json:"neighborSets,omitempty"`
+	CommunitySets []CommunitySet `json:"communitySets,omitempty"`
+	// Statements are evaluated in order, until one accepts or rejects the route
+	// +kubebuilder:validation:MinItems=1
+	Statements []Statement `json:"statements"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:storageversion
+// +kubebuilder:resource:scope=Cluster
+
+// BgpPolicy is the Schema for the bgppolicies API
+type BgpPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec BgpPolicySpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// BgpPolicyList contains a list of BgpPolicy
+type BgpPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []BgpPolicy `json:"items"`
+}
+
+var _ webhook.Validator = &BgpPolicy{}
+
+// +kubebuilder:webhook:path=/validate-network-kubesphere-io-v1alpha2-bgppolicy,mutating=false,sideEffects=NoneOnDryRun,failurePolicy=fail,groups=network.kubesphere.io,resources=bgppolicies,verbs=create;update,versions=v1alpha2,name=validate.bgppolicy.network.kubesphere.io
+
+func (p BgpPolicy) ValidateCreate() error {
+	_, _, err := p.Spec.ToGoBgpPolicy(p.Name, 0)
+	return err
+}
+
+func (p BgpPolicy) ValidateUpdate(old runtime.Object) error {
+	_, _, err := p.Spec.ToGoBgpPolicy(p.Name, 0)
+	return err
+}
+
+func (p BgpPolicy) ValidateDelete() error {
+	return nil
+}
+
+func (p BgpPolicy) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&p).
+		Complete()
+}
+
+// ToGoBgpPolicy converts c into the gobgp policy called name and the defined sets it refers to.
+// The sets are named name/set and the statements name/index, as gobgp shares them between policies.
+// as is prepended by the statements without an AS of their own.
+func (c BgpPolicySpec) ToGoBgpPolicy(name string, as uint32) ([]*api.DefinedSet, *api.Policy, error) {
+	var sets []*api.DefinedSet
+	types := make(map[string]api.DefinedType)
+	addSet := func(set *api.DefinedSet, field string) error {
+		if set.Name == "" || strings.Contains(set.Name, "/") {
+			return fmt.Errorf("field Spec.%s name %q invalid", field, set.Name)
+		}
+		if _, ok := types[set.Name]; ok {
+			return fmt.Errorf("field Spec.%s name %s duplicated", field, set.Name)
+		}
+		types[set.Name] = set.DefinedType
+		set.Name = name + "/" + set.Name
+		sets = append(sets, set)
+		return nil
+	}
+
+	for _, s := range c.PrefixSets {
+		set := &api.DefinedSet{
+			DefinedType: api.DefinedType_PREFIX,
+			Name:        s.Name,
+		}
+		for _, p := range s.Prefixes {
+			prefix, err := p.toGoBgpPrefix()
+			if err != nil {
+				return nil, nil, fmt.Errorf("field Spec.PrefixSets %s invalid, %v", s.Name, err)
+			}
+			// gobgp keeps the prefixes of a set in the tree of one family
+			if len(set.Prefixes) > 0 && strings.Contains(set.Prefixes[0].IpPrefix, ":") != strings.Contains(prefix.IpPrefix, ":") {
+				return nil, nil, fmt.Errorf("field Spec.PrefixSets %s mixes IPv4 and IPv6 prefixes", s.Name)
+			}
+			set.Prefixes = append(set.Prefixes, prefix)
+		}
+		if len(set.Prefixes) == 0 {
+			return nil, nil, fmt.Errorf("field Spec.PrefixSets %s is empty", s.Name)
+		}
+		if err := addSet(set, "PrefixSets"); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, s := range c.NeighborSets {
+		set := &api.DefinedSet{
+			DefinedType: api.DefinedType_NEIGHBOR,
+			Name:        s.Name,
+		}
+		for _, n := range s.Neighbors {
+			prefix, err := neighborPrefix(n)
+			if err != nil {
+				return nil, nil, fmt.Errorf("field Spec.NeighborSets %s invalid, %v", s.Name, err)
+			}
+			set.List = append(set.List, prefix)
+		}
+		// gobgp matches every neighbor with an empty set
+		if len(set.List) == 0 {
+			return nil, nil, fmt.Errorf("field Spec.NeighborSets %s is empty", s.Name)
+		}
+		if err := addSet(set, "NeighborSets"); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, s := range c.CommunitySets {
+		set := &api.DefinedSet{
+			DefinedType: api.DefinedType_COMMUNITY,
+			Name:        s.Name,
+		}
+		for _, community := range s.Communities {
+			if err := validCommunityRegexp(community); err != nil {
+				return nil, nil, fmt.Errorf("field Spec.CommunitySets %s invalid, %v", s.Name, err)
+			}
+			set.List = append(set.List, community)
+		}
+		if len(set.List) == 0 {
+			return nil, nil, fmt.Errorf("field Spec.CommunitySets %s is empty", s.Name)
+		}
+		if err := addSet(set, "CommunitySets"); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	matchSet := func(m *MatchSet, typ api.DefinedType, field string) (*api.MatchSet, error) {
+		if m == nil {
+			return nil, nil
+		}
+		if t, ok := types[m.Name]; !ok || t != typ {
+			return nil, fmt.Errorf("field %s refers to an unknown set %s", field, m.Name)
+		}
+
+		result := &api.MatchSet{
+			Name: name + "/" + m.Name,
+		}
+		switch m.MatchType {
+		case "", MatchTypeAny:
+			result.MatchType = api.MatchType_ANY
+		case MatchTypeInvert:
+			result.MatchType = api.MatchType_INVERT
+		case MatchTypeAll:
+			if typ != api.DefinedType_COMMUNITY {
+				return nil, fmt.Errorf("field %s match type All is only supported by community sets", field)
+			}
+			result.MatchType = api.MatchType_ALL
+		default:
+			return nil, fmt.Errorf("field %s match type %s invalid", field, m.MatchType)
+		}
+		return result, nil
+	}
+
+	policy := &api.Policy{
+		Name: name,
+	}
+	if len(c.Statements) == 0 {
+		return nil, nil, fmt.Errorf("field Spec.Statements is empty")
+	}
+	for i, s := range c.Statements {
+		field := fmt.Sprintf("Spec.Statements[%d]", i)
+		statement := &api.Statement{
+			Name:    fmt.Sprintf("%s/%d", name, i),
+			Actions: &api.Actions{},
+		}
+
+		if s.Conditions != nil {
+			var err error
+			statement.Conditions = &api.Conditions{}
+			statement.Conditions.PrefixSet, err = matchSet(s.Conditions.PrefixSet, api.DefinedType_PREFIX, field+".Conditions.PrefixSet")
+			if err != nil {
+				return nil, nil, err
+			}
+			statement.Conditions.NeighborSet, err = matchSet(s.Conditions.NeighborSet, api.DefinedType_NEIGHBOR, field+".Conditions.NeighborSet")
+			if err != nil {
+				return nil, nil, err
+			}
+			statement.Conditions.CommunitySet, err = matchSet(s.Conditions.CommunitySet, api.DefinedType_COMMUNITY, field+".Conditions.CommunitySet")
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		switch s.Actions.RouteAction {
+		case "":
+			statement.Actions.RouteAction = api.RouteAction_NONE
+		case RouteActionAccept:
+			statement.Actions.RouteAction = api.RouteAction_ACCEPT
+		case RouteActionReject:
+			statement.Actions.RouteAction = api.RouteAction_REJECT
+		default:
+			return nil, nil, fmt.Errorf("field %s.Actions.RouteAction %s invalid", field, s.Actions.RouteAction)
+		}
+
+		if a := s.Actions.Community; a != nil {
+			action, err := a.toGoBgpCommunityAction()
+			if err != nil {
+				return nil, nil, fmt.Errorf("field %s.Actions.Community invalid, %v", field, err)
+			}
+			statement.Actions.Community = action
+		}
+
+		if a := s.Actions.AsPathPrepend; a != nil {
+			if a.Repeat < 1 || a.Repeat > 10 {
+				return nil, nil, fmt.Errorf("field %s.Actions.AsPathPrepend.Repeat %d is not in 1..10", field, a.Repeat)
+			}
+			action := &api.AsPrependAction{
+				Asn:         a.As,
+				Repeat:      a.Repeat,
+				UseLeftMost: a.UseLeftMost,
+			}
+			if action.Asn == 0 && !action.UseLeftMost {
+				action.Asn = as
+			}
+			statement.Actions.AsPrepend = action
+		}
+
+		policy.Statements = append(policy.Statements, statement)
+	}
+
+	return sets, policy, nil
+}
+
+func (p Prefix) toGoBgpPrefix() (*api.Prefix, error) {
+	_, ipNet, err := net.ParseCIDR(p.Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	length, bits := ipNet.Mask.Size()
+	result := &api.Prefix{
+		IpPrefix:      ipNet.String(),
+		MaskLengthMin: p.MaskLengthMin,
+		MaskLengthMax: p.MaskLengthMax,
+	}
+	if result.MaskLengthMin == 0 {
+		result.MaskLengthMin = uint32(length)
+	}
+	if result.MaskLengthMax == 0 {
+		result.MaskLengthMax = uint32(length)
+	}
+	if result.MaskLengthMin < uint32(length) || result.MaskLengthMin > result.MaskLengthMax ||
+		result.MaskLengthMax > uint32(bits) {
+		return nil, fmt.Errorf("mask length range %d..%d of %s invalid", result.MaskLengthMin, result.MaskLengthMax, p.Prefix)
+	}
+
+	return result, nil
+}
+
+// neighborPrefix returns the prefix of n, a single address is a host prefix.
+func neighborPrefix(n string) (string, error) {
+	if ip := net.ParseIP(n); ip != nil {
+		if ip.To4() != nil {
+			return ip.String() + "/32", nil
+		}
+		return ip.String() + "/128", nil
+	}
+
+	_, ipNet, err := net.ParseCIDR(n)
+	if err != nil {
+		return "", fmt.Errorf("neighbor %s is neither an address nor a prefix", n)
+	}
+	return ipNet.String(), nil
+}
+
+// validCommunityRegexp accepts what ParseCommunity does, or a regular expression.
+func validCommunityRegexp(c string) error {
+	if _, err := ParseCommunity(c); err == nil {
+		return nil
+	}
+	if _, err := regexp.Compile(c); err != nil {
+		return fmt.Errorf("failed to parse %s as community or regular expression: %v", c, err)
+	}
+
+	return nil
+}
+
+func (a *CommunityAction) toGoBgpCommunityAction() (*api.CommunityAction, error) {
+	result := &api.CommunityAction{}
+	switch a.Type {
+	case CommunityActionAdd:
+		result.ActionType = api.CommunityActionType_COMMUNITY_ADD
+	case CommunityActionRemove:
+		result.ActionType = api.CommunityActionType_COMMUNITY_REMOVE
+	case CommunityActionReplace:
+		result.ActionType = api.CommunityActionType_COMMUNITY_REPLACE
+	default:
+		return nil, fmt.Errorf("type %s invalid", a.Type)
+	}
+	// Replacing with nothing removes all the communities
+	if len(a.Communities) == 0 && a.Type != CommunityActionReplace {
+		return nil, fmt.Errorf("no communities to %s", strings.ToLower(a.Type))
+	}
+
+	for _, c := range a.Communities {
+		if a.Type == CommunityActionRemove {
+			if err := validCommunityRegexp(c); err != nil {
+				return nil, err
+			}
+		} else if _, err := ParseCommunity(c); err != nil {
+			return nil, err
+		}
+		result.Communities = append(result.Communities, c)
+	}
+
+	return result, nil
+}
+
+func init() {
+	SchemeBuilder.Register(&BgpPolicy{}, &BgpPolicyList{})
+}
@@ -21,13 +21,18 @@ import (
 	"math/big"
 	"net"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/openelb/openelb/pkg/util"
 	"github.com/openelb/openelb/pkg/validate"
 
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
 	"github.com/openelb/openelb/pkg/constant"
 	"github.com/openelb/openelb/pkg/manager/client"
+	api "github.com/osrg/gobgp/api"
+	bgppacket "github.com/osrg/gobgp/pkg/packet/bgp"
 	cnet "github.com/projectcalico/libcalico-go/lib/net"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -73,6 +78,51 @@ func (e Eip) GetProtocol() string {
 	return constant.OpenELBProtocolBGP
 }
 
+// GetAggregate returns the smallest prefix covering the Eip in the CIDR form.
+func (e Eip) GetAggregate() (string, error) {
+	if _, cidr, err := net.ParseCIDR(e.Spec.Address); err == nil {
+		return cidr.String(), nil
+	}
+
+	base, size, err := e.GetSize()
+	if err != nil {
+		return "", err
+	}
+
+	bits := 8 * net.IPv4len
+	if base.To4() == nil {
+		bits = 8 * net.IPv6len
+	} else {
+		base = base.To4()
+	}
+	ones := bits - new(big.Int).Sub(big.NewInt(size), big.NewInt(1)).BitLen()
+
+	last := cnet.IncrementIP(cnet.IP{IP: base}, big.NewInt(size-1)).IP
+	if last.To4() != nil {
+		last = last.To4()
+	}
+	for ; ones > 0; ones-- {
+		mask := net.CIDRMask(ones, bits)
+		if base.Mask(mask).Equal(last.Mask(mask)) {
+			break
+		}
+	}
+
+	return (&net.IPNet{IP: base.Mask(net.CIDRMask(ones, bits)), Mask: net.CIDRMask(ones, bits)}).String(), nil
+}
+
+// GetHostRoutes returns which services are announced with host routes, All if the Eip is not aggregated.
+func (e Eip) GetHostRoutes() string {
+	if e.Spec.Aggregate == nil {
+		return HostRoutesAll
+	}
+	if e.Spec.Aggregate.HostRoutes == "" {
+		return HostRoutesLocal
+	}
+
+	return e.Spec.Aggregate.HostRoutes
+}
+
 func (e Eip) GetSize() (net.IP, int64, error) {
 	ip := net.ParseIP(e.Spec.Address)
 	if ip != nil {
@@ -115,6 +165,42 @@ type EipSpec struct {
 	Interface     string `json:"interface,omitempty"`
 	Disable       bool   `json:"disable,omitempty"`
 	UsingKnownIPs bool   `json:"usingKnownIPs,omitempty"`
+	// WithdrawWithoutEndpoints withdraws the route, stops answering ARP, or removes the vip
+	// from keepalived, for the services of this Eip while they have no ready endpoints
+	WithdrawWithoutEndpoints bool `json:"withdrawWithoutEndpoints,omitempty"`
+	// PathAttributes are attached to the BGP routes of the services of this Eip,
+	// each of them can be overridden by an annotation of the Service
+	PathAttributes *PathAttributes `json:"pathAttributes,omitempty"`
+	// Aggregate announces the prefix covering the Eip from every eligible node, only for bgp
+	Aggregate *Aggregate `json:"aggregate,omitempty"`
+}
+
+const (
+	HostRoutesAll   = "All"
+	HostRoutesLocal = "Local"
+	HostRoutesNone  = "None"
+)
+
+// Aggregate configures the route covering the whole Eip
+type Aggregate struct {
+	// HostRoutes selects the services which are still announced with host routes,
+	// Local only announces the services with externalTrafficPolicy=Local. Defaults to Local
+	// +kubebuilder:validation:Enum=All;Local;None
+	HostRoutes string `json:"hostRoutes,omitempty"`
+}
+
+// PathAttributes are the optional BGP path attributes of the announced routes
+type PathAttributes struct {
+	// Communities in the "AS:VALUE" form, or well-known names like "no-export"
+	Communities []string `json:"communities,omitempty"`
+	// LargeCommunities in the "ASN:DATA1:DATA2" form
+	LargeCommunities []string `json:"largeCommunities,omitempty"`
+	LocalPref        *uint32  `json:"localPref,omitempty"`
+	Med              *uint32  `json:"med,omitempty"`
+	// AsPathPrepend is the number of extra copies of the local AS, on top of the one every
+	// eBGP peer gets, e.g. 2 shows the local AS 3 times to an eBGP peer and 2 times to an iBGP one
+	// +kubebuilder:validation:Maximum=10
+	AsPathPrepend uint32 `json:"asPathPrepend,omitempty"`
 }
 
 // EipStatus defines the observed state of EIP
@@ -196,20 +282,133 @@ func (e Eip) ValidateCreate() error {
 			return fmt.Errorf("field spec.interface should not be empty")
 		}
 	}
+	if e.Spec.Aggregate != nil && e.GetProtocol() != constant.OpenELBProtocolBGP {
+		return fmt.Errorf("field spec.aggregate is only supported by bgp")
+	}
 	if validate.HasOpenELBDefaultEipAnnotation(e.Annotations) && existDefaultEip {
 		return fmt.Errorf("already exists a default EIP")
 	}
-	return nil
+
+	_, err = e.Spec.PathAttributes.ToGoBgpPathAttributes(0)
+	return err
 }
 
 func (e Eip) ValidateUpdate(old runtime.Object) error {
 	oldE := old.(*Eip)
 	if !reflect.DeepEqual(e.Spec, oldE.Spec) {
-		if e.Spec.Disable == oldE.Spec.Disable {
+		// Fields which only change how the addresses are announced can be modified at any time
+		spec, oldSpec := e.Spec.DeepCopy(), oldE.Spec.DeepCopy()
+		spec.WithdrawWithoutEndpoints, oldSpec.WithdrawWithoutEndpoints = false, false
+		spec.PathAttributes, oldSpec.PathAttributes = nil, nil
+		spec.Aggregate, oldSpec.Aggregate = nil, nil
+		if !reflect.DeepEqual(spec, oldSpec) && e.Spec.Disable == oldE.Spec.Disable {
 			return fmt.Errorf("only allow modify field disable")
 		}
 	}
-	return nil
+	if e.Spec.Aggregate != nil && e.GetProtocol() != constant.OpenELBProtocolBGP {
+		return fmt.Errorf("field spec.aggregate is only supported by bgp")
+	}
+
+	_, err := e.Spec.PathAttributes.ToGoBgpPathAttributes(0)
+	return err
+}
+
+// ToGoBgpPathAttributes converts p into gobgp path attributes, as is the local AS
+// prepended by asPathPrepend, gobgp prepends it once more when sending to eBGP peers.
+// A nil p has no attributes.
+func (p *PathAttributes) ToGoBgpPathAttributes(as uint32) ([]*any.Any, error) {
+	if p == nil {
+		return nil, nil
+	}
+
+	var attrs []*any.Any
+	if len(p.Communities) > 0 {
+		communities := make([]uint32, 0, len(p.Communities))
+		for _, c := range p.Communities {
+			community, err := ParseCommunity(c)
+			if err != nil {
+				return nil, err
+			}
+			communities = append(communities, community)
+		}
+		a, _ := ptypes.MarshalAny(&api.CommunitiesAttribute{
+			Communities: communities,
+		})
+		attrs = append(attrs, a)
+	}
+
+	if len(p.LargeCommunities) > 0 {
+		communities := make([]*api.LargeCommunity, 0, len(p.LargeCommunities))
+		for _, c := range p.LargeCommunities {
+			community, err := bgppacket.ParseLargeCommunity(c)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s as large community: %v", c, err)
+			}
+			communities = append(communities, &api.LargeCommunity{
+				GlobalAdmin: community.ASN,
+				LocalData1:  community.LocalData1,
+				LocalData2:  community.LocalData2,
+			})
+		}
+		a, _ := ptypes.MarshalAny(&api.LargeCommunitiesAttribute{
+			Communities: communities,
+		})
+		attrs = append(attrs, a)
+	}
+
+	if p.LocalPref != nil {
+		a, _ := ptypes.MarshalAny(&api.LocalPrefAttribute{
+			LocalPref: *p.LocalPref,
+		})
+		attrs = append(attrs, a)
+	}
+
+	if p.Med != nil {
+		a, _ := ptypes.MarshalAny(&api.MultiExitDiscAttribute{
+			Med: *p.Med,
+		})
+		attrs = append(attrs, a)
+	}
+
+	if p.AsPathPrepend > 0 {
+		numbers := make([]uint32, p.AsPathPrepend)
+		for i := range numbers {
+			numbers[i] = as
+		}
+		a, _ := ptypes.MarshalAny(&api.AsPathAttribute{
+			Segments: []*api.AsSegment{
+				{
+					Type:    uint32(bgppacket.BGP_ASPATH_ATTR_TYPE_SEQ),
+					Numbers: numbers,
+				},
+			},
+		})
+		attrs = append(attrs, a)
+	}
+
+	return attrs, nil
+}
+
+// ParseCommunity accepts "AS:VALUE", a plain number or a well-known community name.
+func ParseCommunity(c string) (uint32, error) {
+	if v, err := strconv.ParseUint(c, 10, 32); err == nil {
+		return uint32(v), nil
+	}
+
+	if v, ok := bgppacket.WellKnownCommunityValueMap[c]; ok {
+		return uint32(v), nil
+	}
+
+	elems := strings.Split(c, ":")
+	if len(elems) == 2 {
+		as, err1 := strconv.ParseUint(elems[0], 10, 16)
+		value, err2 := strconv.ParseUint(elems[1], 10, 16)
+		if err1 == nil && err2 == nil {
+			return uint32(as<<16 | value), nil
+		}
+	}
+
+	return 0, fmt.Errorf("failed to parse %s as community", c)
 }
 
 func (e Eip) ValidateDelete() error {
@@ -0,0 +1,74 @@
+/*
+Copyright 2022 The Kubesphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NodeAnnouncementStatus is the result of the speaker running on one node.
+type NodeAnnouncementStatus struct {
+	// Announced is true if the speaker applied the last SetBalancer call
+	Announced bool `json:"announced,omitempty"`
+	// Pending is true while the speaker defers the last SetBalancer call, see the debounce window
+	// and the hold-down of the speaker
+	Pending bool `json:"pending,omitempty"`
+	// Nodes are the nodes the address is announced through, the desired ones while pending, empty for vip
+	Nodes []string `json:"nodes,omitempty"`
+	// Error returned by the speaker, empty on success
+	Error              string      `json:"error,omitempty"`
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// ServiceAnnouncementStatus defines the observed state of ServiceAnnouncement
+type ServiceAnnouncementStatus struct {
+	Eip      string `json:"eip,omitempty"`
+	Address  string `json:"address,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+	// The key is the name of the node where the openelb-manager runs
+	NodesAnnouncementStatus map[string]NodeAnnouncementStatus `json:"nodesAnnouncementStatus,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:storageversion
+// +kubebuilder:printcolumn:name="eip",type=string,JSONPath=`.status.eip`
+// +kubebuilder:printcolumn:name="address",type=string,JSONPath=`.status.address`
+// +kubebuilder:printcolumn:name="protocol",type=string,JSONPath=`.status.protocol`
+// +kubebuilder:resource:scope=Namespaced,categories=networking
+
+// ServiceAnnouncement records how the address of a Service with the same name is announced.
+// It is owned by the Service and written by the lb controller.
+type ServiceAnnouncement struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status ServiceAnnouncementStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ServiceAnnouncementList contains a list of ServiceAnnouncement
+type ServiceAnnouncementList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ServiceAnnouncement `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ServiceAnnouncement{}, &ServiceAnnouncementList{})
+}
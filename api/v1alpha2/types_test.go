@@ -6,6 +6,8 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/openelb/openelb/pkg/constant"
+	api "github.com/osrg/gobgp/api"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
@@ -126,5 +128,307 @@ var _ = Describe("Test eip types", func() {
 		e2 = e.DeepCopy()
 		e2.Spec.Disable = true
 		Expect(e2.ValidateUpdate(e)).ShouldNot(HaveOccurred())
+
+		e2 = e.DeepCopy()
+		e2.Spec.WithdrawWithoutEndpoints = true
+		e2.Spec.PathAttributes = &PathAttributes{Communities: []string{"65000:100"}}
+		Expect(e2.ValidateUpdate(e)).ShouldNot(HaveOccurred())
+
+		e2.Spec.PathAttributes.Communities = []string{"65000:100000"}
+		Expect(e2.ValidateUpdate(e)).Should(HaveOccurred())
+
+		e2 = e.DeepCopy()
+		e2.Spec.Aggregate = &Aggregate{}
+		Expect(e2.ValidateUpdate(e)).ShouldNot(HaveOccurred())
+
+		e.Spec.Protocol = constant.OpenELBProtocolLayer2
+		e2 = e.DeepCopy()
+		e2.Spec.Aggregate = &Aggregate{}
+		Expect(e2.ValidateUpdate(e)).Should(HaveOccurred())
+	})
+
+	It("Test GetAggregate", func() {
+		e := &Eip{
+			Spec: EipSpec{
+				Address: "192.168.0.0/24",
+			},
+		}
+		Expect(e.GetAggregate()).Should(Equal("192.168.0.0/24"))
+
+		e.Spec.Address = "192.168.0.5-192.168.0.9"
+		Expect(e.GetAggregate()).Should(Equal("192.168.0.0/28"))
+
+		e.Spec.Address = "192.168.0.128-192.168.1.127"
+		Expect(e.GetAggregate()).Should(Equal("192.168.0.0/23"))
+
+		e.Spec.Address = "192.168.0.1"
+		Expect(e.GetAggregate()).Should(Equal("192.168.0.1/32"))
+
+		e.Spec.Address = "2001:db8::/64"
+		Expect(e.GetAggregate()).Should(Equal("2001:db8::/64"))
+	})
+
+	It("Test GetHostRoutes", func() {
+		e := &Eip{}
+		Expect(e.GetHostRoutes()).Should(Equal(HostRoutesAll))
+
+		e.Spec.Aggregate = &Aggregate{}
+		Expect(e.GetHostRoutes()).Should(Equal(HostRoutesLocal))
+
+		e.Spec.Aggregate.HostRoutes = HostRoutesNone
+		Expect(e.GetHostRoutes()).Should(Equal(HostRoutesNone))
+	})
+
+	It("Test ParseCommunity", func() {
+		c, err := ParseCommunity("65000:100")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(c).Should(Equal(uint32(65000<<16 | 100)))
+
+		c, err = ParseCommunity("no-export")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(c).Should(Equal(uint32(0xffffff01)))
+
+		c, err = ParseCommunity("100")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(c).Should(Equal(uint32(100)))
+
+		_, err = ParseCommunity("65000:100:1")
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("Test ToGoBgpPathAttributes", func() {
+		var p *PathAttributes
+		attrs, err := p.ToGoBgpPathAttributes(65000)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(attrs).Should(BeEmpty())
+
+		localPref := uint32(200)
+		p = &PathAttributes{
+			Communities:      []string{"65000:100"},
+			LargeCommunities: []string{"65000:1:2"},
+			LocalPref:        &localPref,
+			AsPathPrepend:    3,
+		}
+		attrs, err = p.ToGoBgpPathAttributes(65000)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(attrs).Should(HaveLen(4))
+
+		p.LargeCommunities = []string{"65000:1"}
+		_, err = p.ToGoBgpPathAttributes(65000)
+		Expect(err).Should(HaveOccurred())
+	})
+})
+
+var _ = Describe("Test bgppeergroup types", func() {
+	It("Test ToGoBgpPeerGroup with passwordSecretRef", func() {
+		spec := BgpPeerGroupSpec{
+			Conf: &PeerGroupConf{
+				PeerAs:       65001,
+				AuthPassword: "secret",
+				PasswordSecretRef: &SecretKeyRef{
+					Name: "group-password",
+				},
+			},
+			DynamicNeighbors: []string{"172.22.0.0/24"},
+		}
+
+		group, err := spec.ToGoBgpPeerGroup("group1")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(group.Conf.PeerGroupName).Should(Equal("group1"))
+		Expect(group.Conf.AuthPassword).Should(Equal("secret"))
+		Expect(spec.Conf.PasswordSecretRef).ShouldNot(BeNil())
+	})
+})
+
+var _ = Describe("Test bgppeer types", func() {
+	It("Test ToGoBgpPeer with passwordSecretRef", func() {
+		spec := BgpPeerSpec{
+			Conf: &PeerConf{
+				NeighborAddress: "192.168.0.2",
+				PeerAs:          65001,
+				AuthPassword:    "secret",
+				PasswordSecretRef: &SecretKeyRef{
+					Name: "peer-password",
+				},
+			},
+		}
+
+		peer, err := spec.ToGoBgpPeer()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(peer.Conf.AuthPassword).Should(Equal("secret"))
+		Expect(spec.Conf.PasswordSecretRef).ShouldNot(BeNil())
+	})
+
+	It("Test GetStatusFromGoBgpPeer redacts the password", func() {
+		peer, err := BgpPeerSpec{
+			Conf: &PeerConf{
+				NeighborAddress: "192.168.0.2",
+				AuthPassword:    "secret",
+			},
+		}.ToGoBgpPeer()
+		Expect(err).ShouldNot(HaveOccurred())
+		peer.State = &api.PeerState{
+			NeighborAddress: "192.168.0.2",
+			AuthPassword:    "secret",
+		}
+		peer.Timers = &api.Timers{State: &api.TimersState{}}
+
+		status, err := GetStatusFromGoBgpPeer(peer)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(status.PeerState.NeighborAddress).Should(Equal("192.168.0.2"))
+		Expect(status.PeerState.AuthPassword).Should(BeEmpty())
+	})
+
+	It("Test ToGoBgpPeer with template", func() {
+		peer, err := BgpPeerSpec{
+			Conf: &PeerConf{
+				NeighborAddress: "192.168.0.2",
+			},
+			Transport: &Transport{
+				LocalAddress: "192.168.0.1",
+			},
+			Template: &PeerTemplate{
+				NeighborAddress: &NodeValueSource{DefaultGateway: true},
+			},
+		}.ToGoBgpPeer()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(peer.Transport.LocalAddress).Should(Equal("192.168.0.1"))
+	})
+
+	It("Test ToGoBgpPeer with maxPrefixes", func() {
+		spec := BgpPeerSpec{
+			Conf: &PeerConf{
+				PeerAs:          65001,
+				NeighborAddress: "192.168.0.2",
+			},
+			AfiSafis: []*AfiSafi{{
+				Config: &AfiSafiConfig{
+					Family:  &Family{Afi: "AFI_IP", Safi: "SAFI_UNICAST"},
+					Enabled: true,
+				},
+			}},
+			MaxPrefixes:         &MaxPrefixes{Shutdown: 1000, Warning: 805},
+			DefaultImportAction: ImportActionReject,
+		}
+		peer, err := spec.ToGoBgpPeer()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(peer.AfiSafis[0].PrefixLimits).Should(Equal(&api.PrefixLimit{
+			Family:               peer.AfiSafis[0].Config.Family,
+			MaxPrefixes:          1000,
+			ShutdownThresholdPct: 80,
+		}))
+
+		spec.MaxPrefixes = &MaxPrefixes{Shutdown: 1000, Warning: 5}
+		peer, err = spec.ToGoBgpPeer()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(peer.AfiSafis[0].PrefixLimits.ShutdownThresholdPct).Should(Equal(uint32(1)))
+
+		spec.MaxPrefixes = &MaxPrefixes{Shutdown: 10, Warning: 20}
+		_, err = spec.ToGoBgpPeer()
+		Expect(err).Should(HaveOccurred())
+	})
+})
+
+var _ = Describe("Test bgppolicy types", func() {
+	spec := BgpPolicySpec{
+		PrefixSets: []PrefixSet{{
+			Name:     "eips",
+			Prefixes: []Prefix{{Prefix: "172.22.0.0/16", MaskLengthMax: 32}},
+		}},
+		NeighborSets: []NeighborSet{{
+			Name:      "tors",
+			Neighbors: []string{"192.168.0.2", "192.168.1.0/24"},
+		}},
+		CommunitySets: []CommunitySet{{
+			Name:        "blackhole",
+			Communities: []string{"65535:666", "^65001:.*$"},
+		}},
+		Statements: []Statement{
+			{
+				Conditions: &Conditions{
+					CommunitySet: &MatchSet{Name: "blackhole", MatchType: MatchTypeAll},
+				},
+				Actions: Actions{RouteAction: RouteActionReject},
+			},
+			{
+				Conditions: &Conditions{
+					PrefixSet:   &MatchSet{Name: "eips"},
+					NeighborSet: &MatchSet{Name: "tors", MatchType: MatchTypeInvert},
+				},
+				Actions: Actions{
+					Community:     &CommunityAction{Type: CommunityActionAdd, Communities: []string{"65001:100", "no-export"}},
+					AsPathPrepend: &AsPathPrepend{Repeat: 2},
+				},
+			},
+		},
+	}
+
+	It("Test ToGoBgpPolicy", func() {
+		sets, policy, err := spec.ToGoBgpPolicy("p", 65001)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(sets).Should(Equal([]*api.DefinedSet{
+			{
+				DefinedType: api.DefinedType_PREFIX,
+				Name:        "p/eips",
+				Prefixes:    []*api.Prefix{{IpPrefix: "172.22.0.0/16", MaskLengthMin: 16, MaskLengthMax: 32}},
+			},
+			{
+				DefinedType: api.DefinedType_NEIGHBOR,
+				Name:        "p/tors",
+				List:        []string{"192.168.0.2/32", "192.168.1.0/24"},
+			},
+			{
+				DefinedType: api.DefinedType_COMMUNITY,
+				Name:        "p/blackhole",
+				List:        []string{"65535:666", "^65001:.*$"},
+			},
+		}))
+		Expect(policy.Name).Should(Equal("p"))
+		Expect(policy.Statements).Should(HaveLen(2))
+		Expect(policy.Statements[0].Name).Should(Equal("p/0"))
+		Expect(policy.Statements[0].Conditions.CommunitySet).Should(Equal(&api.MatchSet{
+			MatchType: api.MatchType_ALL,
+			Name:      "p/blackhole",
+		}))
+		Expect(policy.Statements[0].Actions.RouteAction).Should(Equal(api.RouteAction_REJECT))
+		Expect(policy.Statements[1].Conditions.NeighborSet).Should(Equal(&api.MatchSet{
+			MatchType: api.MatchType_INVERT,
+			Name:      "p/tors",
+		}))
+		Expect(policy.Statements[1].Actions).Should(Equal(&api.Actions{
+			RouteAction: api.RouteAction_NONE,
+			Community: &api.CommunityAction{
+				ActionType:  api.CommunityActionType_COMMUNITY_ADD,
+				Communities: []string{"65001:100", "no-export"},
+			},
+			AsPrepend: &api.AsPrependAction{Asn: 65001, Repeat: 2},
+		}))
+	})
+
+	It("Test ValidateCreate", func() {
+		policy := BgpPolicy{Spec: *spec.DeepCopy()}
+		policy.Name = "p"
+		Expect(policy.ValidateCreate()).ShouldNot(HaveOccurred())
+
+		invalid := []func(s *BgpPolicySpec){
+			func(s *BgpPolicySpec) { s.Statements = nil },
+			func(s *BgpPolicySpec) { s.PrefixSets[0].Prefixes[0].MaskLengthMax = 33 },
+			func(s *BgpPolicySpec) { s.PrefixSets[0].Prefixes[0].MaskLengthMin = 8 },
+			func(s *BgpPolicySpec) {
+				s.PrefixSets[0].Prefixes = append(s.PrefixSets[0].Prefixes, Prefix{Prefix: "fd00::/64"})
+			},
+			func(s *BgpPolicySpec) { s.NeighborSets[0].Neighbors = []string{"tor1"} },
+			func(s *BgpPolicySpec) { s.NeighborSets[0].Name = "eips" },
+			func(s *BgpPolicySpec) { s.CommunitySets[0].Communities = []string{"65001:("} },
+			func(s *BgpPolicySpec) { s.Statements[0].Conditions.CommunitySet.Name = "eips" },
+			func(s *BgpPolicySpec) { s.Statements[1].Conditions.PrefixSet.MatchType = MatchTypeAll },
+			func(s *BgpPolicySpec) { s.Statements[1].Actions.Community.Communities = []string{"^65001:.*$"} },
+			func(s *BgpPolicySpec) { s.Statements[1].Actions.AsPathPrepend.Repeat = 11 },
+		}
+		for _, f := range invalid {
+			clone := policy.DeepCopy()
+			f(&clone.Spec)
+			Expect(clone.ValidateCreate()).Should(HaveOccurred())
+		}
 	})
 })
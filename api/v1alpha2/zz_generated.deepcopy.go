@@ -26,6 +26,31 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Actions) DeepCopyInto(out *Actions) {
+	*out = *in
+	if in.Community != nil {
+		in, out := &in.Community, &out.Community
+		*out = new(CommunityAction)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AsPathPrepend != nil {
+		in, out := &in.AsPathPrepend, &out.AsPathPrepend
+		*out = new(AsPathPrepend)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Actions.
+func (in *Actions) DeepCopy() *Actions {
+	if in == nil {
+		return nil
+	}
+	out := new(Actions)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AddPaths) DeepCopyInto(out *AddPaths) {
 	*out = *in
@@ -111,6 +136,51 @@ func (in *AfiSafiConfig) DeepCopy() *AfiSafiConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Aggregate) DeepCopyInto(out *Aggregate) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Aggregate.
+func (in *Aggregate) DeepCopy() *Aggregate {
+	if in == nil {
+		return nil
+	}
+	out := new(Aggregate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AsPathPrepend) DeepCopyInto(out *AsPathPrepend) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AsPathPrepend.
+func (in *AsPathPrepend) DeepCopy() *AsPathPrepend {
+	if in == nil {
+		return nil
+	}
+	out := new(AsPathPrepend)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Bfd) DeepCopyInto(out *Bfd) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Bfd.
+func (in *Bfd) DeepCopy() *Bfd {
+	if in == nil {
+		return nil
+	}
+	out := new(Bfd)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BgpConf) DeepCopyInto(out *BgpConf) {
 	*out = *in
@@ -195,6 +265,31 @@ func (in *BgpConfSpec) DeepCopyInto(out *BgpConfSpec) {
 		*out = new(GracefulRestart)
 		**out = **in
 	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Mesh != nil {
+		in, out := &in.Mesh, &out.Mesh
+		*out = new(Mesh)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BmpServers != nil {
+		in, out := &in.BmpServers, &out.BmpServers
+		*out = make([]BmpServer, len(*in))
+		copy(*out, *in)
+	}
+	if in.ImportPolicies != nil {
+		in, out := &in.ImportPolicies, &out.ImportPolicies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExportPolicies != nil {
+		in, out := &in.ExportPolicies, &out.ExportPolicies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BgpConfSpec.
@@ -214,7 +309,7 @@ func (in *BgpConfStatus) DeepCopyInto(out *BgpConfStatus) {
 		in, out := &in.NodesConfStatus, &out.NodesConfStatus
 		*out = make(map[string]NodeConfStatus, len(*in))
 		for key, val := range *in {
-			(*out)[key] = val
+			(*out)[key] = *val.DeepCopy()
 		}
 	}
 }
@@ -256,6 +351,148 @@ func (in *BgpPeer) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BgpPeerGroup) DeepCopyInto(out *BgpPeerGroup) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BgpPeerGroup.
+func (in *BgpPeerGroup) DeepCopy() *BgpPeerGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(BgpPeerGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *BgpPeerGroup) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BgpPeerGroupList) DeepCopyInto(out *BgpPeerGroupList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]BgpPeerGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BgpPeerGroupList.
+func (in *BgpPeerGroupList) DeepCopy() *BgpPeerGroupList {
+	if in == nil {
+		return nil
+	}
+	out := new(BgpPeerGroupList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *BgpPeerGroupList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BgpPeerGroupSpec) DeepCopyInto(out *BgpPeerGroupSpec) {
+	*out = *in
+	if in.Conf != nil {
+		in, out := &in.Conf, &out.Conf
+		*out = new(PeerGroupConf)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EbgpMultihop != nil {
+		in, out := &in.EbgpMultihop, &out.EbgpMultihop
+		*out = new(EbgpMultihop)
+		**out = **in
+	}
+	if in.Timers != nil {
+		in, out := &in.Timers, &out.Timers
+		*out = new(Timers)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Transport != nil {
+		in, out := &in.Transport, &out.Transport
+		*out = new(Transport)
+		**out = **in
+	}
+	if in.GracefulRestart != nil {
+		in, out := &in.GracefulRestart, &out.GracefulRestart
+		*out = new(GracefulRestart)
+		**out = **in
+	}
+	if in.AfiSafis != nil {
+		in, out := &in.AfiSafis, &out.AfiSafis
+		*out = make([]*AfiSafi, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(AfiSafi)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	if in.DynamicNeighbors != nil {
+		in, out := &in.DynamicNeighbors, &out.DynamicNeighbors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BgpPeerGroupSpec.
+func (in *BgpPeerGroupSpec) DeepCopy() *BgpPeerGroupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BgpPeerGroupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BgpPeerGroupStatus) DeepCopyInto(out *BgpPeerGroupStatus) {
+	*out = *in
+	if in.NodesPeerGroupStatus != nil {
+		in, out := &in.NodesPeerGroupStatus, &out.NodesPeerGroupStatus
+		*out = make(map[string]NodePeerGroupStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BgpPeerGroupStatus.
+func (in *BgpPeerGroupStatus) DeepCopy() *BgpPeerGroupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BgpPeerGroupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BgpPeerList) DeepCopyInto(out *BgpPeerList) {
 	*out = *in
@@ -294,7 +531,7 @@ func (in *BgpPeerSpec) DeepCopyInto(out *BgpPeerSpec) {
 	if in.Conf != nil {
 		in, out := &in.Conf, &out.Conf
 		*out = new(PeerConf)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.EbgpMultihop != nil {
 		in, out := &in.EbgpMultihop, &out.EbgpMultihop
@@ -327,6 +564,36 @@ func (in *BgpPeerSpec) DeepCopyInto(out *BgpPeerSpec) {
 			}
 		}
 	}
+	if in.Bfd != nil {
+		in, out := &in.Bfd, &out.Bfd
+		*out = new(Bfd)
+		**out = **in
+	}
+	if in.Template != nil {
+		in, out := &in.Template, &out.Template
+		*out = new(PeerTemplate)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExtendedNexthop != nil {
+		in, out := &in.ExtendedNexthop, &out.ExtendedNexthop
+		*out = new(bool)
+		**out = **in
+	}
+	if in.MaxPrefixes != nil {
+		in, out := &in.MaxPrefixes, &out.MaxPrefixes
+		*out = new(MaxPrefixes)
+		**out = **in
+	}
+	if in.ImportPolicies != nil {
+		in, out := &in.ImportPolicies, &out.ImportPolicies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExportPolicies != nil {
+		in, out := &in.ExportPolicies, &out.ExportPolicies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.NodeSelector != nil {
 		in, out := &in.NodeSelector, &out.NodeSelector
 		*out = new(v1.LabelSelector)
@@ -367,41 +634,25 @@ func (in *BgpPeerStatus) DeepCopy() *BgpPeerStatus {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EbgpMultihop) DeepCopyInto(out *EbgpMultihop) {
-	*out = *in
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EbgpMultihop.
-func (in *EbgpMultihop) DeepCopy() *EbgpMultihop {
-	if in == nil {
-		return nil
-	}
-	out := new(EbgpMultihop)
-	in.DeepCopyInto(out)
-	return out
-}
-
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Eip) DeepCopyInto(out *Eip) {
+func (in *BgpPolicy) DeepCopyInto(out *BgpPolicy) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	in.Status.DeepCopyInto(&out.Status)
+	in.Spec.DeepCopyInto(&out.Spec)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Eip.
-func (in *Eip) DeepCopy() *Eip {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BgpPolicy.
+func (in *BgpPolicy) DeepCopy() *BgpPolicy {
 	if in == nil {
 		return nil
 	}
-	out := new(Eip)
+	out := new(BgpPolicy)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *Eip) DeepCopyObject() runtime.Object {
+func (in *BgpPolicy) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -409,31 +660,31 @@ func (in *Eip) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EipList) DeepCopyInto(out *EipList) {
+func (in *BgpPolicyList) DeepCopyInto(out *BgpPolicyList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]Eip, len(*in))
+		*out = make([]BgpPolicy, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EipList.
-func (in *EipList) DeepCopy() *EipList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BgpPolicyList.
+func (in *BgpPolicyList) DeepCopy() *BgpPolicyList {
 	if in == nil {
 		return nil
 	}
-	out := new(EipList)
+	out := new(BgpPolicyList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *EipList) DeepCopyObject() runtime.Object {
+func (in *BgpPolicyList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -441,34 +692,246 @@ func (in *EipList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EipSpec) DeepCopyInto(out *EipSpec) {
+func (in *BgpPolicySpec) DeepCopyInto(out *BgpPolicySpec) {
 	*out = *in
+	if in.PrefixSets != nil {
+		in, out := &in.PrefixSets, &out.PrefixSets
+		*out = make([]PrefixSet, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NeighborSets != nil {
+		in, out := &in.NeighborSets, &out.NeighborSets
+		*out = make([]NeighborSet, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.CommunitySets != nil {
+		in, out := &in.CommunitySets, &out.CommunitySets
+		*out = make([]CommunitySet, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Statements != nil {
+		in, out := &in.Statements, &out.Statements
+		*out = make([]Statement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EipSpec.
-func (in *EipSpec) DeepCopy() *EipSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BgpPolicySpec.
+func (in *BgpPolicySpec) DeepCopy() *BgpPolicySpec {
 	if in == nil {
 		return nil
 	}
-	out := new(EipSpec)
+	out := new(BgpPolicySpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EipStatus) DeepCopyInto(out *EipStatus) {
+func (in *BmpServer) DeepCopyInto(out *BmpServer) {
 	*out = *in
-	if in.Used != nil {
-		in, out := &in.Used, &out.Used
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EipStatus.
-func (in *EipStatus) DeepCopy() *EipStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BmpServer.
+func (in *BmpServer) DeepCopy() *BmpServer {
+	if in == nil {
+		return nil
+	}
+	out := new(BmpServer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CommunityAction) DeepCopyInto(out *CommunityAction) {
+	*out = *in
+	if in.Communities != nil {
+		in, out := &in.Communities, &out.Communities
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CommunityAction.
+func (in *CommunityAction) DeepCopy() *CommunityAction {
+	if in == nil {
+		return nil
+	}
+	out := new(CommunityAction)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CommunitySet) DeepCopyInto(out *CommunitySet) {
+	*out = *in
+	if in.Communities != nil {
+		in, out := &in.Communities, &out.Communities
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CommunitySet.
+func (in *CommunitySet) DeepCopy() *CommunitySet {
+	if in == nil {
+		return nil
+	}
+	out := new(CommunitySet)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Conditions) DeepCopyInto(out *Conditions) {
+	*out = *in
+	if in.PrefixSet != nil {
+		in, out := &in.PrefixSet, &out.PrefixSet
+		*out = new(MatchSet)
+		**out = **in
+	}
+	if in.NeighborSet != nil {
+		in, out := &in.NeighborSet, &out.NeighborSet
+		*out = new(MatchSet)
+		**out = **in
+	}
+	if in.CommunitySet != nil {
+		in, out := &in.CommunitySet, &out.CommunitySet
+		*out = new(MatchSet)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Conditions.
+func (in *Conditions) DeepCopy() *Conditions {
+	if in == nil {
+		return nil
+	}
+	out := new(Conditions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EbgpMultihop) DeepCopyInto(out *EbgpMultihop) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EbgpMultihop.
+func (in *EbgpMultihop) DeepCopy() *EbgpMultihop {
+	if in == nil {
+		return nil
+	}
+	out := new(EbgpMultihop)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Eip) DeepCopyInto(out *Eip) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Eip.
+func (in *Eip) DeepCopy() *Eip {
+	if in == nil {
+		return nil
+	}
+	out := new(Eip)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Eip) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EipList) DeepCopyInto(out *EipList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Eip, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EipList.
+func (in *EipList) DeepCopy() *EipList {
+	if in == nil {
+		return nil
+	}
+	out := new(EipList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EipList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EipSpec) DeepCopyInto(out *EipSpec) {
+	*out = *in
+	if in.PathAttributes != nil {
+		in, out := &in.PathAttributes, &out.PathAttributes
+		*out = new(PathAttributes)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Aggregate != nil {
+		in, out := &in.Aggregate, &out.Aggregate
+		*out = new(Aggregate)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EipSpec.
+func (in *EipSpec) DeepCopy() *EipSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(EipSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EipStatus) DeepCopyInto(out *EipStatus) {
+	*out = *in
+	if in.Used != nil {
+		in, out := &in.Used, &out.Used
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EipStatus.
+func (in *EipStatus) DeepCopy() *EipStatus {
 	if in == nil {
 		return nil
 	}
@@ -507,6 +970,56 @@ func (in *GracefulRestart) DeepCopy() *GracefulRestart {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MatchSet) DeepCopyInto(out *MatchSet) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatchSet.
+func (in *MatchSet) DeepCopy() *MatchSet {
+	if in == nil {
+		return nil
+	}
+	out := new(MatchSet)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaxPrefixes) DeepCopyInto(out *MaxPrefixes) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaxPrefixes.
+func (in *MaxPrefixes) DeepCopy() *MaxPrefixes {
+	if in == nil {
+		return nil
+	}
+	out := new(MaxPrefixes)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Mesh) DeepCopyInto(out *Mesh) {
+	*out = *in
+	if in.RouteReflectorSelector != nil {
+		in, out := &in.RouteReflectorSelector, &out.RouteReflectorSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Mesh.
+func (in *Mesh) DeepCopy() *Mesh {
+	if in == nil {
+		return nil
+	}
+	out := new(Mesh)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Message) DeepCopyInto(out *Message) {
 	*out = *in
@@ -582,9 +1095,55 @@ func (in *MpGracefulRestartConfig) DeepCopy() *MpGracefulRestartConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NeighborSet) DeepCopyInto(out *NeighborSet) {
+	*out = *in
+	if in.Neighbors != nil {
+		in, out := &in.Neighbors, &out.Neighbors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NeighborSet.
+func (in *NeighborSet) DeepCopy() *NeighborSet {
+	if in == nil {
+		return nil
+	}
+	out := new(NeighborSet)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeAnnouncementStatus) DeepCopyInto(out *NodeAnnouncementStatus) {
+	*out = *in
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeAnnouncementStatus.
+func (in *NodeAnnouncementStatus) DeepCopy() *NodeAnnouncementStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeAnnouncementStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NodeConfStatus) DeepCopyInto(out *NodeConfStatus) {
 	*out = *in
+	if in.Conflicts != nil {
+		in, out := &in.Conflicts, &out.Conflicts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeConfStatus.
@@ -597,11 +1156,38 @@ func (in *NodeConfStatus) DeepCopy() *NodeConfStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodePeerGroupStatus) DeepCopyInto(out *NodePeerGroupStatus) {
+	*out = *in
+	if in.Neighbors != nil {
+		in, out := &in.Neighbors, &out.Neighbors
+		*out = make([]NodePeerStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodePeerGroupStatus.
+func (in *NodePeerGroupStatus) DeepCopy() *NodePeerGroupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodePeerGroupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NodePeerStatus) DeepCopyInto(out *NodePeerStatus) {
 	*out = *in
 	in.PeerState.DeepCopyInto(&out.PeerState)
 	out.TimersState = in.TimersState
+	if in.Template != nil {
+		in, out := &in.Template, &out.Template
+		*out = new(ResolvedPeerTemplate)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodePeerStatus.
@@ -614,9 +1200,64 @@ func (in *NodePeerStatus) DeepCopy() *NodePeerStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeValueSource) DeepCopyInto(out *NodeValueSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeValueSource.
+func (in *NodeValueSource) DeepCopy() *NodeValueSource {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeValueSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PathAttributes) DeepCopyInto(out *PathAttributes) {
+	*out = *in
+	if in.Communities != nil {
+		in, out := &in.Communities, &out.Communities
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LargeCommunities != nil {
+		in, out := &in.LargeCommunities, &out.LargeCommunities
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LocalPref != nil {
+		in, out := &in.LocalPref, &out.LocalPref
+		*out = new(uint32)
+		**out = **in
+	}
+	if in.Med != nil {
+		in, out := &in.Med, &out.Med
+		*out = new(uint32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PathAttributes.
+func (in *PathAttributes) DeepCopy() *PathAttributes {
+	if in == nil {
+		return nil
+	}
+	out := new(PathAttributes)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PeerConf) DeepCopyInto(out *PeerConf) {
 	*out = *in
+	if in.PasswordSecretRef != nil {
+		in, out := &in.PasswordSecretRef, &out.PasswordSecretRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PeerConf.
@@ -629,6 +1270,26 @@ func (in *PeerConf) DeepCopy() *PeerConf {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PeerGroupConf) DeepCopyInto(out *PeerGroupConf) {
+	*out = *in
+	if in.PasswordSecretRef != nil {
+		in, out := &in.PasswordSecretRef, &out.PasswordSecretRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PeerGroupConf.
+func (in *PeerGroupConf) DeepCopy() *PeerGroupConf {
+	if in == nil {
+		return nil
+	}
+	out := new(PeerGroupConf)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PeerState) DeepCopyInto(out *PeerState) {
 	*out = *in
@@ -654,6 +1315,71 @@ func (in *PeerState) DeepCopy() *PeerState {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PeerTemplate) DeepCopyInto(out *PeerTemplate) {
+	*out = *in
+	if in.NeighborAddress != nil {
+		in, out := &in.NeighborAddress, &out.NeighborAddress
+		*out = new(NodeValueSource)
+		**out = **in
+	}
+	if in.LocalAddress != nil {
+		in, out := &in.LocalAddress, &out.LocalAddress
+		*out = new(NodeValueSource)
+		**out = **in
+	}
+	if in.PeerAs != nil {
+		in, out := &in.PeerAs, &out.PeerAs
+		*out = new(NodeValueSource)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PeerTemplate.
+func (in *PeerTemplate) DeepCopy() *PeerTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(PeerTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Prefix) DeepCopyInto(out *Prefix) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Prefix.
+func (in *Prefix) DeepCopy() *Prefix {
+	if in == nil {
+		return nil
+	}
+	out := new(Prefix)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrefixSet) DeepCopyInto(out *PrefixSet) {
+	*out = *in
+	if in.Prefixes != nil {
+		in, out := &in.Prefixes, &out.Prefixes
+		*out = make([]Prefix, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrefixSet.
+func (in *PrefixSet) DeepCopy() *PrefixSet {
+	if in == nil {
+		return nil
+	}
+	out := new(PrefixSet)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Queues) DeepCopyInto(out *Queues) {
 	*out = *in
@@ -669,6 +1395,137 @@ func (in *Queues) DeepCopy() *Queues {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResolvedPeerTemplate) DeepCopyInto(out *ResolvedPeerTemplate) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResolvedPeerTemplate.
+func (in *ResolvedPeerTemplate) DeepCopy() *ResolvedPeerTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(ResolvedPeerTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretKeyRef) DeepCopyInto(out *SecretKeyRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretKeyRef.
+func (in *SecretKeyRef) DeepCopy() *SecretKeyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretKeyRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceAnnouncement) DeepCopyInto(out *ServiceAnnouncement) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceAnnouncement.
+func (in *ServiceAnnouncement) DeepCopy() *ServiceAnnouncement {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceAnnouncement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ServiceAnnouncement) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceAnnouncementList) DeepCopyInto(out *ServiceAnnouncementList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ServiceAnnouncement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceAnnouncementList.
+func (in *ServiceAnnouncementList) DeepCopy() *ServiceAnnouncementList {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceAnnouncementList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ServiceAnnouncementList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceAnnouncementStatus) DeepCopyInto(out *ServiceAnnouncementStatus) {
+	*out = *in
+	if in.NodesAnnouncementStatus != nil {
+		in, out := &in.NodesAnnouncementStatus, &out.NodesAnnouncementStatus
+		*out = make(map[string]NodeAnnouncementStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceAnnouncementStatus.
+func (in *ServiceAnnouncementStatus) DeepCopy() *ServiceAnnouncementStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceAnnouncementStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Statement) DeepCopyInto(out *Statement) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = new(Conditions)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Actions.DeepCopyInto(&out.Actions)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Statement.
+func (in *Statement) DeepCopy() *Statement {
+	if in == nil {
+		return nil
+	}
+	out := new(Statement)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Timers) DeepCopyInto(out *Timers) {
 	*out = *in
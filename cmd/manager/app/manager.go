@@ -96,6 +96,11 @@ func Run(c *options.OpenELBManagerOptions) error {
 	}
 
 	bgpServer := bgpd.NewGoBgpd(c.Bgp)
+	// Served next to the metrics, the snapshot of the global rib in MRT format
+	err = mgr.AddMetricsExtraHandler("/debug/mrt", bgpServer.MRTHandler())
+	if err != nil {
+		setupLog.Error(err, "unable to add mrt handler")
+	}
 
 	// Setup all Controllers
 	err = ipam.SetupIPAM(mgr)
@@ -104,6 +109,7 @@ func Run(c *options.OpenELBManagerOptions) error {
 		return err
 	}
 	networkv1alpha2.Eip{}.SetupWebhookWithManager(mgr)
+	networkv1alpha2.BgpPolicy{}.SetupWebhookWithManager(mgr)
 
 	err = bgp.SetupBgpConfReconciler(bgpServer, mgr)
 	if err != nil {
@@ -115,11 +121,31 @@ func Run(c *options.OpenELBManagerOptions) error {
 		setupLog.Error(err, "unable to setup bgppeer")
 	}
 
-	if err = lb.SetupServiceReconciler(mgr); err != nil {
+	err = bgp.SetupBgpPeerGroupReconciler(bgpServer, mgr)
+	if err != nil {
+		setupLog.Error(err, "unable to setup bgppeergroup")
+	}
+
+	err = bgp.SetupBgpPolicyReconciler(bgpServer, mgr)
+	if err != nil {
+		setupLog.Error(err, "unable to setup bgppolicy")
+	}
+
+	err = bgp.SetupMeshReconciler(bgpServer, mgr)
+	if err != nil {
+		setupLog.Error(err, "unable to setup mesh")
+	}
+
+	if err = lb.SetupServiceReconciler(mgr, c.Bgp.RouteSyncPeriod); err != nil {
 		setupLog.Error(err, "unable to setup lb controller")
 		return err
 	}
 
+	if err = lb.SetupAggregateReconciler(mgr); err != nil {
+		setupLog.Error(err, "unable to setup aggregate controller")
+		return err
+	}
+
 	stopCh := ctrl.SetupSignalHandler()
 
 	//For layer2
@@ -127,7 +153,7 @@ func Run(c *options.OpenELBManagerOptions) error {
 	leader.LeaderElector(stopCh, k8sClient, *c.Leader)
 
 	//For gobgp
-	err = speaker.RegisterSpeaker(constant.OpenELBProtocolBGP, bgpServer)
+	err = speaker.RegisterSpeaker(constant.OpenELBProtocolBGP, speaker.NewDamper(bgpServer, c.Speaker))
 	if err != nil {
 		setupLog.Error(err, "unable to register bgp speaker")
 		return err
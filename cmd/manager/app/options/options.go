@@ -4,6 +4,7 @@ import (
 	"github.com/openelb/openelb/pkg/leader-elector"
 	"github.com/openelb/openelb/pkg/log"
 	"github.com/openelb/openelb/pkg/manager"
+	"github.com/openelb/openelb/pkg/speaker"
 	"github.com/openelb/openelb/pkg/speaker/bgp"
 	cliflag "k8s.io/component-base/cli/flag"
 )
@@ -13,6 +14,7 @@ type OpenELBManagerOptions struct {
 	*manager.GenericOptions
 	LogOptions *log.Options
 	Leader     *leader.Options
+	Speaker    *speaker.Options
 }
 
 func NewOpenELBManagerOptions() *OpenELBManagerOptions {
@@ -21,6 +23,7 @@ func NewOpenELBManagerOptions() *OpenELBManagerOptions {
 		GenericOptions: manager.NewGenericOptions(),
 		LogOptions:     log.NewOptions(),
 		Leader:         leader.NewOptions(),
+		Speaker:        speaker.NewOptions(),
 	}
 }
 
@@ -36,6 +39,7 @@ func (s *OpenELBManagerOptions) Flags() cliflag.NamedFlagSets {
 	s.GenericOptions.AddFlags(fss.FlagSet("generic"))
 	s.LogOptions.AddFlags(fss.FlagSet("log"))
 	s.Leader.AddFlags(fss.FlagSet("leader"))
+	s.Speaker.AddFlags(fss.FlagSet("speaker"))
 
 	return fss
 }
@@ -26,6 +26,10 @@ const (
 	NodeProxyFinalizerName            string = "node-proxy.openelb.kubesphere.io/finalizer"
 
 	KubernetesMasterLabel string = "node-role.kubernetes.io/master"
+	// Nodes with this label are never used as nexthops, the value is ignored
+	KubernetesExcludeBalancersLabel string = "node.kubernetes.io/exclude-from-external-load-balancers"
+	// Nodes labeled with "false" are never used as nexthops
+	OpenELBNodeAnnounceLabel string = "node.openelb.kubesphere.io/announce"
 
 	OpenELBEIPAnnotationKey         string = "eip.openelb.kubesphere.io/v1alpha1"
 	OpenELBEIPAnnotationKeyV1Alpha2 string = "eip.openelb.kubesphere.io/v1alpha2"
@@ -33,6 +37,19 @@ const (
 	OpenELBProtocolAnnotationKey    string = "protocol.openelb.kubesphere.io/v1alpha1"
 
 	OpenELBNodeRack string = "openelb.kubesphere.io/rack"
+	// When set to "true" on a Service with externalTrafficPolicy=Local, each nexthop is weighted
+	// by the number of ready endpoints on that node
+	OpenELBWeightedECMPAnnotationKey string = "bgp.openelb.kubesphere.io/weighted-ecmp"
+	// BGP path attributes of a Service, override the pathAttributes of the Eip.
+	// Communities are separated by commas
+	OpenELBCommunitiesAnnotationKey      string = "bgp.openelb.kubesphere.io/communities"
+	OpenELBLargeCommunitiesAnnotationKey string = "bgp.openelb.kubesphere.io/large-communities"
+	OpenELBLocalPrefAnnotationKey        string = "bgp.openelb.kubesphere.io/local-pref"
+	OpenELBMedAnnotationKey              string = "bgp.openelb.kubesphere.io/med"
+	OpenELBAsPathPrependAnnotationKey    string = "bgp.openelb.kubesphere.io/as-path-prepend"
+	// When set to "true" on a Service, its address is withdrawn while it has no ready endpoints.
+	// Overrides the withdrawWithoutEndpoints of the Eip, "false" disables it for a single Service
+	OpenELBWithdrawAnnotationKey string = "lb.openelb.kubesphere.io/withdraw-without-endpoints"
 	// TODO: Disable lable modification using webhook
 	OpenELBCNI string = "openelb.kubesphere.io/cni"
 
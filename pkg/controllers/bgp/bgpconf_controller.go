@@ -18,7 +18,9 @@ package bgp
 
 import (
 	"context"
+	"fmt"
 	"reflect"
+	"sort"
 	"time"
 
 	"github.com/openelb/openelb/api/v1alpha2"
@@ -27,10 +29,12 @@ import (
 	"github.com/openelb/openelb/pkg/util"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/runtime"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
@@ -47,7 +51,11 @@ type BgpConfReconciler struct {
 	client.Client
 	BgpServer *bgp.Bgp
 	record.EventRecorder
+	// Secrets are the Secrets of the openelb namespace, see secretCache
+	Secrets cache.Cache
 	cleaned bool
+	// applied is the name of the BgpConf gobgp runs with on this node
+	applied string
 }
 
 // +kubebuilder:rbac:groups=network.kubesphere.io,resources=bgpconfs,verbs=get;list;watch;create;update;patch;delete
@@ -60,7 +68,8 @@ func (r *BgpConfReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	err := r.Client.Get(ctx, req.NamespacedName, instance)
 	if err != nil {
 		if errors.IsNotFound(err) {
-			return ctrl.Result{}, nil
+			// Deleted by the node which removed the finalizer, another BgpConf may select this node now
+			return ctrl.Result{}, r.applyEffectiveConf()
 		}
 		return ctrl.Result{}, err
 	}
@@ -68,13 +77,13 @@ func (r *BgpConfReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	clone := instance.DeepCopy()
 
 	if util.IsDeletionCandidate(clone, constant.FinalizerName) {
-		err := r.BgpServer.HandleBgpGlobalConfig(clone, "", true)
-		if err != nil {
-			ctrl.Log.Error(err, "cannot delete bgp conf, maybe need to delete manually")
+		controllerutil.RemoveFinalizer(clone, constant.FinalizerName)
+		if err = r.Update(context.Background(), clone); err != nil {
+			return ctrl.Result{}, err
 		}
 
-		controllerutil.RemoveFinalizer(clone, constant.FinalizerName)
-		return ctrl.Result{}, r.Update(context.Background(), clone)
+		// Another BgpConf may select the node now
+		return ctrl.Result{}, r.applyEffectiveConf()
 	}
 
 	if util.NeedToAddFinalizer(clone, constant.FinalizerName) {
@@ -85,28 +94,49 @@ func (r *BgpConfReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		}
 	}
 
+	// The BgpConfs with an invalid nodeSelector select no node
+	if instance.Spec.NodeSelector != nil {
+		if _, err = metav1.LabelSelectorAsSelector(instance.Spec.NodeSelector); err != nil {
+			r.Event(instance, corev1.EventTypeWarning, "InvalidNodeSelector", err.Error())
+		}
+	}
+
+	return ctrl.Result{}, r.applyEffectiveConf()
+}
+
+// applyEffectiveConf applies the BgpConf selected for this node, or stops bgp if there is none.
+func (r *BgpConfReconciler) applyEffectiveConf() error {
+	var confs v1alpha2.BgpConfList
+	err := r.List(context.Background(), &confs)
+	if err != nil {
+		return err
+	}
 	node := &corev1.Node{}
-	rack := ""
 	err = r.Get(context.Background(), types.NamespacedName{Name: util.GetNodeName()}, node)
 	if err != nil {
-		return ctrl.Result{}, err
+		return err
 	}
-	if node.Labels != nil && node.Labels[constant.OpenELBNodeRack] != "" && clone.Spec.AsPerRack != nil {
-		rack = node.Labels[constant.OpenELBNodeRack]
-		as := clone.Spec.AsPerRack[rack]
-		if as > 0 {
-			clone.Spec.As = as
+
+	instance, _ := effectiveConf(confs.Items, node)
+	if instance == nil {
+		if r.applied != "" {
+			ctrl.Log.Info("no bgpconf selects the node, stop bgp", "bgpconf", r.applied)
+			err = r.BgpServer.HandleBgpGlobalConfig(&v1alpha2.BgpConf{}, "", true)
+			if err != nil {
+				ctrl.Log.Error(err, "cannot delete bgp conf, maybe need to delete manually")
+			}
+			r.applied = ""
 		}
-		clone.Spec.RouterId = ""
-	}
-	if clone.Spec.RouterId == "" {
-		clone.Spec.RouterId = util.GetNodeIP(*node).String()
+		r.updateConfStatus()
+		return nil
 	}
 
+	clone, rack := nodeConf(instance, node)
 	err = r.BgpServer.HandleBgpGlobalConfig(clone, rack, false)
 	if err != nil {
-		return ctrl.Result{}, err
+		return err
 	}
+	r.applied = instance.Name
 
 	if clone.Annotations == nil {
 		clone.Annotations = make(map[string]string)
@@ -115,17 +145,59 @@ func (r *BgpConfReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	clone.Annotations[constant.OpenELBAnnotationKey] = time.Now().String()
 	err = r.Client.Update(context.Background(), clone)
 	if err != nil {
-		return ctrl.Result{}, err
+		return err
 	}
 
-	return ctrl.Result{}, r.reconfigPeers()
+	r.updateConfStatus()
+
+	// The peers may refer to the groups
+	if err = r.reconfigPeerGroups(); err != nil {
+		return err
+	}
+
+	return r.reconfigPeers()
+}
+
+// reconfigPeerGroups adds the groups matching the node, in case they were reconciled before bgp
+// was started. The speaker skips the ones it already has unchanged.
+func (r *BgpConfReconciler) reconfigPeerGroups() error {
+	ctx := context.Background()
+
+	var groups v1alpha2.BgpPeerGroupList
+	err := r.List(ctx, &groups)
+	if err != nil {
+		return err
+	}
+	node := &corev1.Node{}
+	err = r.Get(ctx, types.NamespacedName{Name: util.GetNodeName()}, node)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups.Items {
+		if group.DeletionTimestamp != nil {
+			continue
+		}
+		// The BgpPeerGroup controller reports the invalid nodeSelectors
+		match, err := peerGroupMatchNode(&group, node)
+		if err != nil || !match {
+			continue
+		}
+		if err = resolveGroupPassword(r.Secrets, &group); err != nil {
+			return err
+		}
+		if err = r.BgpServer.HandleBgpPeerGroup(&group, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (r *BgpConfReconciler) reconfigPeers() error {
 	ctx := context.Background()
 
-	//Add all the neighbor that exist and match node back in, since
-	//the neighbor was reset when the global configuration was updated earlier.
+	//Add all the neighbor that exist and match node, in case they were reconciled
+	//before bgp was started. The speaker skips the ones it already has unchanged.
 	var peers v1alpha2.BgpPeerList
 	err := r.List(ctx, &peers)
 	if err != nil {
@@ -136,12 +208,35 @@ func (r *BgpConfReconciler) reconfigPeers() error {
 	if err != nil {
 		return err
 	}
+	var confs v1alpha2.BgpConfList
+	err = r.List(ctx, &confs)
+	if err != nil {
+		return err
+	}
+	upstream, err := peersUpstream(confs.Items, node)
+	if err != nil {
+		return err
+	}
 	for _, peer := range peers.Items {
 		match, err := peerMatchNode(&peer, node)
 		if err != nil {
 			return err
 		}
 		if match {
+			// The BgpPeer controller reports the templates that could not be resolved
+			if _, err = resolvePeerTemplate(&peer, node); err != nil {
+				continue
+			}
+			// Only the route reflectors peer upstream
+			if !upstream {
+				if err = r.BgpServer.HandleBgpPeer(&peer, true); err != nil {
+					return err
+				}
+				continue
+			}
+			if err = resolvePassword(r.Secrets, &peer); err != nil {
+				return err
+			}
 			err = r.BgpServer.HandleBgpPeer(&peer, false)
 			if err != nil {
 				return err
@@ -152,14 +247,81 @@ func (r *BgpConfReconciler) reconfigPeers() error {
 	return nil
 }
 
-func shouldReconcile(obj runtime.Object) bool {
-	if conf, ok := obj.(*v1alpha2.BgpConf); ok {
-		if conf.Name == "default" {
-			return true
+// nodeConf returns a copy of conf with the AS and router id of node, and the rack of node.
+func nodeConf(conf *v1alpha2.BgpConf, node *corev1.Node) (*v1alpha2.BgpConf, string) {
+	clone := conf.DeepCopy()
+	rack := ""
+	if node.Labels != nil && node.Labels[constant.OpenELBNodeRack] != "" && clone.Spec.AsPerRack != nil {
+		rack = node.Labels[constant.OpenELBNodeRack]
+		as := clone.Spec.AsPerRack[rack]
+		if as > 0 {
+			clone.Spec.As = as
 		}
+		clone.Spec.RouterId = ""
+	}
+	if clone.Spec.RouterId == "" {
+		clone.Spec.RouterId = util.GetNodeIP(*node).String()
 	}
 
-	return false
+	return clone, rack
+}
+
+func confMatchNode(conf *v1alpha2.BgpConf, node *corev1.Node) (bool, error) {
+	if conf.Spec.NodeSelector == nil {
+		return true, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(conf.Spec.NodeSelector)
+	if err != nil {
+		return false, fmt.Errorf("BgpConf %s spec.NodeSelector invalid, err=%v", conf.Name, err)
+	}
+
+	return selector.Matches(labels.Set(node.GetLabels())), nil
+}
+
+// effectiveConf returns the BgpConf applied to node, and the names of the BgpConfs selecting it
+// with the same precedence if there are several. The ones being deleted or managed by a CNI are ignored.
+func effectiveConf(confs []v1alpha2.BgpConf, node *corev1.Node) (*v1alpha2.BgpConf, []string) {
+	var candidates []*v1alpha2.BgpConf
+	for i := range confs {
+		conf := &confs[i]
+		if conf.DeletionTimestamp != nil || util.DutyOfCNI(nil, conf) {
+			continue
+		}
+		if match, err := confMatchNode(conf, node); err != nil || !match {
+			continue
+		}
+		candidates = append(candidates, conf)
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	precedes := func(a, b *v1alpha2.BgpConf) bool {
+		if a.Spec.Priority != b.Spec.Priority {
+			return a.Spec.Priority > b.Spec.Priority
+		}
+		return a.Spec.NodeSelector != nil && b.Spec.NodeSelector == nil
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if precedes(candidates[i], candidates[j]) || precedes(candidates[j], candidates[i]) {
+			return precedes(candidates[i], candidates[j])
+		}
+		return candidates[i].Name < candidates[j].Name
+	})
+
+	var conflicts []string
+	for _, conf := range candidates[1:] {
+		if precedes(candidates[0], conf) {
+			break
+		}
+		conflicts = append(conflicts, conf.Name)
+	}
+	if len(conflicts) > 0 {
+		conflicts = append([]string{candidates[0].Name}, conflicts...)
+	}
+
+	return candidates[0], conflicts
 }
 
 func (r *BgpConfReconciler) SetupWithManager(mgr ctrl.Manager) error {
@@ -168,21 +330,19 @@ func (r *BgpConfReconciler) SetupWithManager(mgr ctrl.Manager) error {
 			if util.DutyOfCNI(nil, e.Meta) {
 				return false
 			}
-			return shouldReconcile(e.Object)
+			return true
 		},
 		UpdateFunc: func(e event.UpdateEvent) bool {
-			if shouldReconcile(e.ObjectNew) {
-				oldConf := e.ObjectOld.(*v1alpha2.BgpConf)
-				newConf := e.ObjectNew.(*v1alpha2.BgpConf)
-
-				if !util.DutyOfCNI(e.MetaOld, e.MetaNew) {
-					if !reflect.DeepEqual(oldConf.DeletionTimestamp, newConf.DeletionTimestamp) {
-						return true
-					}
-
-					if !reflect.DeepEqual(oldConf.Spec, newConf.Spec) {
-						return true
-					}
+			oldConf := e.ObjectOld.(*v1alpha2.BgpConf)
+			newConf := e.ObjectNew.(*v1alpha2.BgpConf)
+
+			if !util.DutyOfCNI(e.MetaOld, e.MetaNew) {
+				if !reflect.DeepEqual(oldConf.DeletionTimestamp, newConf.DeletionTimestamp) {
+					return true
+				}
+
+				if !reflect.DeepEqual(oldConf.Spec, newConf.Spec) {
+					return true
 				}
 			}
 
@@ -199,24 +359,14 @@ func (r *BgpConfReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		return err
 	}
 
+	// The effective config of this node changes with its labels
 	np := predicate.Funcs{
 		UpdateFunc: func(evt event.UpdateEvent) bool {
-			old := evt.ObjectOld.(*corev1.Node)
-			new := evt.ObjectNew.(*corev1.Node)
-
-			oldHaveLabel := false
-			if old.Labels != nil {
-				_, oldHaveLabel = old.Labels[constant.OpenELBNodeRack]
-			}
-			newHaveLabel := false
-			if new.Labels != nil {
-				_, newHaveLabel = old.Labels[constant.OpenELBNodeRack]
-			}
-			if oldHaveLabel != newHaveLabel {
-				return true
+			if evt.MetaNew.GetName() != util.GetNodeName() {
+				return false
 			}
 
-			return false
+			return !reflect.DeepEqual(evt.MetaOld.GetLabels(), evt.MetaNew.GetLabels())
 		},
 		CreateFunc: func(e event.CreateEvent) bool {
 			return false
@@ -229,10 +379,15 @@ func (r *BgpConfReconciler) SetupWithManager(mgr ctrl.Manager) error {
 }
 
 func SetupBgpConfReconciler(bgpServer *bgp.Bgp, mgr ctrl.Manager) error {
+	secrets, err := secretCache(mgr)
+	if err != nil {
+		return err
+	}
 	bgpConf := BgpConfReconciler{
 		Client:        mgr.GetClient(),
 		BgpServer:     bgpServer,
 		EventRecorder: mgr.GetEventRecorderFor("bgpconf"),
+		Secrets:       secrets,
 	}
 	if err := bgpConf.SetupWithManager(mgr); err != nil {
 		return err
@@ -242,40 +397,95 @@ func SetupBgpConfReconciler(bgpServer *bgp.Bgp, mgr ctrl.Manager) error {
 }
 
 func (r *BgpConfReconciler) CleanBgpConfStatus() error {
-	instance := &v1alpha2.BgpConf{}
-	err := r.Client.Get(context.Background(), client.ObjectKey{Name: "default"}, instance)
+	var confs v1alpha2.BgpConfList
+	err := r.Client.List(context.Background(), &confs)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			return nil
 		}
 		return err
 	}
-	clone := instance.DeepCopy()
-	clone.Status = v1alpha2.BgpConfStatus{}
-	if reflect.DeepEqual(clone.Status, instance.Status) {
-		return nil
+
+	for _, conf := range confs.Items {
+		clone := conf.DeepCopy()
+		clone.Status = v1alpha2.BgpConfStatus{}
+		if reflect.DeepEqual(clone.Status, conf.Status) {
+			continue
+		}
+		err = r.Client.Status().Update(context.Background(), clone)
+		if err != nil {
+			return err
+		}
 	}
-	return r.Client.Status().Update(context.Background(), clone)
+
+	return nil
 }
 
+// updateConfStatus reports the config gobgp runs with on the effective BgpConf of this node,
+// and the conflicts on the BgpConfs selecting it with the same precedence.
 func (r BgpConfReconciler) updateConfStatus() {
-	instance := &v1alpha2.BgpConf{}
-	err := r.Client.Get(context.Background(), client.ObjectKey{Name: "default"}, instance)
+	var confs v1alpha2.BgpConfList
+	err := r.Client.List(context.Background(), &confs)
 	if err != nil {
 		return
 	}
-	clone := instance.DeepCopy()
-	if clone.Status.NodesConfStatus == nil {
-		clone.Status.NodesConfStatus = make(map[string]v1alpha2.NodeConfStatus)
+	node := &corev1.Node{}
+	err = r.Get(context.Background(), types.NamespacedName{Name: util.GetNodeName()}, node)
+	if err != nil {
+		return
 	}
-	result := r.BgpServer.GetBgpConfStatus()
+
 	nodeName := util.GetNodeName()
-	clone.Status.NodesConfStatus[nodeName] = result.Status.NodesConfStatus[nodeName]
+	effective, conflicts := effectiveConf(confs.Items, node)
+	for _, conf := range confs.Items {
+		clone := conf.DeepCopy()
+
+		var status *v1alpha2.NodeConfStatus
+		switch {
+		case effective != nil && conf.Name == effective.Name:
+			result := r.BgpServer.GetBgpConfStatus()
+			running := result.Status.NodesConfStatus[nodeName]
+			status = &running
+		case contains(conflicts, conf.Name):
+			status = &v1alpha2.NodeConfStatus{}
+		}
 
-	if reflect.DeepEqual(clone.Status, instance.Status) {
-		return
+		if status == nil {
+			delete(clone.Status.NodesConfStatus, nodeName)
+		} else {
+			status.Conflicts = without(conflicts, conf.Name)
+			if clone.Status.NodesConfStatus == nil {
+				clone.Status.NodesConfStatus = make(map[string]v1alpha2.NodeConfStatus)
+			}
+			clone.Status.NodesConfStatus[nodeName] = *status
+		}
+
+		if reflect.DeepEqual(clone.Status, conf.Status) {
+			continue
+		}
+		r.Client.Status().Update(context.Background(), clone)
+	}
+}
+
+func contains(s []string, x string) bool {
+	for _, y := range s {
+		if x == y {
+			return true
+		}
 	}
-	r.Client.Status().Update(context.Background(), clone)
+
+	return false
+}
+
+func without(s []string, x string) []string {
+	var result []string
+	for _, y := range s {
+		if x != y {
+			result = append(result, y)
+		}
+	}
+
+	return result
 }
 
 func (r BgpConfReconciler) run(stopCh <-chan struct{}) {
@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/openelb/openelb/api/v1alpha2"
@@ -27,17 +28,24 @@ import (
 	"github.com/openelb/openelb/pkg/metrics"
 	"github.com/openelb/openelb/pkg/speaker/bgp"
 	"github.com/openelb/openelb/pkg/util"
+	api "github.com/osrg/gobgp/api"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // BgpPeerReconciler reconciles a BgpPeer object
@@ -45,6 +53,8 @@ type BgpPeerReconciler struct {
 	client.Client
 	BgpServer *bgp.Bgp
 	record.EventRecorder
+	// Secrets are the Secrets of the openelb namespace, see secretCache
+	Secrets cache.Cache
 }
 
 func peerMatchNode(peer *v1alpha2.BgpPeer, node *corev1.Node) (bool, error) {
@@ -68,6 +78,7 @@ func peerMatchNode(peer *v1alpha2.BgpPeer, node *corev1.Node) (bool, error) {
 
 // +kubebuilder:rbac:groups=network.kubesphere.io,resources=bgppeers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=network.kubesphere.io,resources=bgppeers/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch,namespace=openelb-system
 
 func (r BgpPeerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	log := ctrl.Log.WithValues("request", req.NamespacedName)
@@ -84,14 +95,21 @@ func (r BgpPeerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	}
 
 	//filter peer with nodeSelector
-	if bgpPeer.Spec.NodeSelector != nil {
-		node := &corev1.Node{}
-		err = r.Get(context.Background(), types.NamespacedName{Name: util.GetNodeName()}, node)
-		if err != nil {
+	node := &corev1.Node{}
+	err = r.Get(context.Background(), types.NamespacedName{Name: util.GetNodeName()}, node)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	matchNode, err = peerMatchNode(bgpPeer, node)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if matchNode {
+		confs := &v1alpha2.BgpConfList{}
+		if err = r.List(context.Background(), confs); err != nil {
 			return ctrl.Result{}, err
 		}
-
-		matchNode, err = peerMatchNode(bgpPeer, node)
+		matchNode, err = peersUpstream(confs.Items, node)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
@@ -100,7 +118,11 @@ func (r BgpPeerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	clone := bgpPeer.DeepCopy()
 
 	if util.IsDeletionCandidate(clone, constant.FinalizerName) {
-		err := r.BgpServer.HandleBgpPeer(clone, true)
+		deleted := clone
+		if last := lastResolvedPeer(clone, util.GetNodeName()); last != nil {
+			deleted = last
+		}
+		err := r.BgpServer.HandleBgpPeer(deleted, true)
 		if err != nil {
 			log.Error(err, "cannot delete bgp peer, maybe need to delete manually")
 		}
@@ -118,7 +140,110 @@ func (r BgpPeerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		}
 	}
 
-	return ctrl.Result{}, r.BgpServer.HandleBgpPeer(clone, !matchNode)
+	families, err := r.eipFamilies()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	r.BgpServer.SetEipFamilies(families)
+
+	last := lastResolvedPeer(bgpPeer, util.GetNodeName())
+	if !matchNode {
+		if last != nil {
+			clone = last
+		}
+		return ctrl.Result{}, r.BgpServer.HandleBgpPeer(clone, true)
+	}
+
+	resolved, err := resolvePeerTemplate(clone, node)
+	if last != nil && (err != nil || last.Spec.Conf.NeighborAddress != clone.Spec.Conf.NeighborAddress) {
+		if err := r.BgpServer.HandleBgpPeer(last, true); err != nil {
+			log.Error(err, "cannot delete the bgp peer resolved before", "address", last.Spec.Conf.NeighborAddress)
+		}
+	}
+	if err != nil {
+		r.Event(bgpPeer, corev1.EventTypeWarning, "InvalidPeerTemplate", err.Error())
+		return ctrl.Result{}, r.updateTemplateStatus(bgpPeer.Name, &v1alpha2.ResolvedPeerTemplate{Error: err.Error()})
+	}
+	if resolved != nil {
+		if err = r.updateTemplateStatus(bgpPeer.Name, resolved); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err = resolvePassword(r.Secrets, clone); err != nil {
+		r.Event(bgpPeer, corev1.EventTypeWarning, "InvalidPasswordSecret", err.Error())
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, r.BgpServer.HandleBgpPeer(clone, false)
+}
+
+// updateTemplateStatus reports the peer resolved from the template on this node.
+// The session state is reset with a new neighbor address, it is filled by the next status sync.
+func (r BgpPeerReconciler) updateTemplateStatus(name string, resolved *v1alpha2.ResolvedPeerTemplate) error {
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		peer := &v1alpha2.BgpPeer{}
+		err := r.Get(context.Background(), types.NamespacedName{Name: name}, peer)
+		if err != nil {
+			return err
+		}
+
+		status := peer.Status.NodesPeerStatus[util.GetNodeName()]
+		if reflect.DeepEqual(status.Template, resolved) {
+			return nil
+		}
+		if status.Template == nil || status.Template.NeighborAddress != resolved.NeighborAddress {
+			status = v1alpha2.NodePeerStatus{}
+		}
+		status.Template = resolved
+		if peer.Status.NodesPeerStatus == nil {
+			peer.Status.NodesPeerStatus = make(map[string]v1alpha2.NodePeerStatus)
+		}
+		peer.Status.NodesPeerStatus[util.GetNodeName()] = status
+
+		return r.Status().Update(context.Background(), peer)
+	})
+}
+
+// eipFamilies returns the unicast families of the Eips announced through bgp.
+func (r BgpPeerReconciler) eipFamilies() ([]*v1alpha2.Family, error) {
+	eips := &v1alpha2.EipList{}
+	err := r.List(context.Background(), eips)
+	if err != nil {
+		return nil, err
+	}
+
+	var v4, v6 bool
+	for _, eip := range eips.Items {
+		if eip.Spec.Disable || eip.GetSpeakerName() != constant.OpenELBProtocolBGP {
+			continue
+		}
+		base, _, err := eip.GetSize()
+		if err != nil {
+			continue
+		}
+		if base.To4() != nil {
+			v4 = true
+		} else {
+			v6 = true
+		}
+	}
+
+	var families []*v1alpha2.Family
+	if v4 {
+		families = append(families, &v1alpha2.Family{
+			Afi:  api.Family_AFI_IP.String(),
+			Safi: api.Family_SAFI_UNICAST.String(),
+		})
+	}
+	if v6 {
+		families = append(families, &v1alpha2.Family{
+			Afi:  api.Family_AFI_IP6.String(),
+			Safi: api.Family_SAFI_UNICAST.String(),
+		})
+	}
+
+	return families, nil
 }
 
 func (r BgpPeerReconciler) Start(stopCh <-chan struct{}) error {
@@ -132,22 +257,40 @@ func (r BgpPeerReconciler) Start(stopCh <-chan struct{}) error {
 	return nil
 }
 
-func (r BgpPeerReconciler) updatePeerStatus() {
+// updatePeerStatus returns the peers as resolved on this node.
+func (r BgpPeerReconciler) updatePeerStatus() []v1alpha2.BgpPeer {
 	peers := &v1alpha2.BgpPeerList{}
 	err := r.List(context.Background(), peers)
 	if err != nil {
-		return
+		return nil
+	}
+	node := &corev1.Node{}
+	err = r.Get(context.Background(), types.NamespacedName{Name: util.GetNodeName()}, node)
+	if err != nil {
+		return nil
+	}
+
+	// The speaker knows the templated peers by the addresses resolved on this node
+	resolvedPeers := make([]v1alpha2.BgpPeer, len(peers.Items))
+	templates := make([]*v1alpha2.ResolvedPeerTemplate, len(peers.Items))
+	for i, peer := range peers.Items {
+		clone := peer.DeepCopy()
+		templates[i], err = resolvePeerTemplate(clone, node)
+		if err != nil {
+			templates[i] = &v1alpha2.ResolvedPeerTemplate{Error: err.Error()}
+		}
+		resolvedPeers[i] = *clone
 	}
 
-	status := r.BgpServer.HandleBgpPeerStatus(peers.Items)
+	status := r.BgpServer.HandleBgpPeerStatus(resolvedPeers)
 
 	//update status
-	for _, peer := range peers.Items {
+	for i, peer := range peers.Items {
 		clone := peer.DeepCopy()
 		found := false
 
 		for _, tmp := range status {
-			if clone.Spec.Conf.NeighborAddress == tmp.Spec.Conf.NeighborAddress {
+			if tmp.Name == peer.Name {
 				clone.Status = tmp.Status
 				found = true
 				break
@@ -157,18 +300,57 @@ func (r BgpPeerReconciler) updatePeerStatus() {
 			delete(clone.Status.NodesPeerStatus, util.GetNodeName())
 		}
 
+		if match, _ := peerMatchNode(&peer, node); match && templates[i] != nil && (found || templates[i].Error != "") {
+			if clone.Status.NodesPeerStatus == nil {
+				clone.Status.NodesPeerStatus = make(map[string]v1alpha2.NodePeerStatus)
+			}
+			nodeStatus := clone.Status.NodesPeerStatus[util.GetNodeName()]
+			nodeStatus.Template = templates[i]
+			clone.Status.NodesPeerStatus[util.GetNodeName()] = nodeStatus
+		}
+
 		if !reflect.DeepEqual(clone.Status, peer.Status) {
 			r.Status().Update(context.Background(), clone)
 		}
-		r.BgpServer.UpdatePeerMetrics(&peer, !found)
+		r.BgpServer.UpdatePeerMetrics(&resolvedPeers[i], !found)
 	}
+
+	return resolvedPeers
 }
 
+// handlePeerState updates the status as soon as a session goes up or down and records it on the BgpPeer.
+// The speaker only sends the sessions entering the established state, or leaving it for idle.
+func (r BgpPeerReconciler) handlePeerState(e bgp.PeerStateEvent) {
+	for _, peer := range r.updatePeerStatus() {
+		conf := peer.Spec.Conf
+		if conf == nil {
+			continue
+		}
+		if e.Interface != "" && conf.NeighborInterface != e.Interface {
+			continue
+		}
+		if e.Interface == "" && (conf.NeighborInterface != "" || conf.NeighborAddress != e.Address) {
+			continue
+		}
+
+		eventType := corev1.EventTypeNormal
+		if e.State != api.PeerState_ESTABLISHED.String() {
+			eventType = corev1.EventTypeWarning
+		}
+		r.Eventf(&peer, eventType, strings.Title(strings.ToLower(e.State)),
+			"bgp session with %s on node %s is %s", e.Address, util.GetNodeName(), e.State)
+	}
+}
+
+// run syncs the status on the peer state events, and periodically in case of the events dropped.
 func (r BgpPeerReconciler) run(stopCh <-chan struct{}) {
 	t := time.NewTicker(time.Duration(syncStatusPeriod) * time.Second)
 
 	for {
 		select {
+		case e := <-r.BgpServer.PeerStateEvents():
+			r.handlePeerState(e)
+
 		case <-t.C:
 			r.updatePeerStatus()
 
@@ -179,9 +361,63 @@ func (r BgpPeerReconciler) run(stopCh <-chan struct{}) {
 }
 
 func (r BgpPeerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// The families of the peers without afiSafis follow the Eips
+	ep := predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldEip := e.ObjectOld.(*v1alpha2.Eip)
+			newEip := e.ObjectNew.(*v1alpha2.Eip)
+
+			return oldEip.Spec.Disable != newEip.Spec.Disable
+		},
+	}
+
+	// The passwords of the peers are re-applied when their Secrets rotate
+	sp := predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldSecret := e.ObjectOld.(*corev1.Secret)
+			newSecret := e.ObjectNew.(*corev1.Secret)
+
+			return !reflect.DeepEqual(oldSecret.Data, newSecret.Data)
+		},
+	}
+	secrets, err := secretSource(r.Secrets)
+	if err != nil {
+		return err
+	}
+
+	// The peers follow the mesh mode of the BgpConfs, only the route reflectors peer upstream
+	cp := predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldConf := e.ObjectOld.(*v1alpha2.BgpConf)
+			newConf := e.ObjectNew.(*v1alpha2.BgpConf)
+
+			return !reflect.DeepEqual(oldConf.DeletionTimestamp, newConf.DeletionTimestamp) ||
+				!reflect.DeepEqual(oldConf.Spec.Mesh, newConf.Spec.Mesh) ||
+				!reflect.DeepEqual(oldConf.Spec.NodeSelector, newConf.Spec.NodeSelector) ||
+				oldConf.Spec.Priority != newConf.Spec.Priority
+		},
+	}
+
+	// The templated peers are resolved again when the labels or annotations of this node change
+	np := predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return false
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.MetaNew.GetName() != util.GetNodeName() {
+				return false
+			}
+
+			return !reflect.DeepEqual(e.MetaOld.GetLabels(), e.MetaNew.GetLabels()) ||
+				!reflect.DeepEqual(e.MetaOld.GetAnnotations(), e.MetaNew.GetAnnotations())
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return false
+		},
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1alpha2.BgpPeer{}).
-		WithEventFilter(predicate.Funcs{
+		For(&v1alpha2.BgpPeer{}, builder.WithPredicates(predicate.Funcs{
 			CreateFunc: func(e event.CreateEvent) bool {
 				if util.DutyOfCNI(nil, e.Meta) {
 					return false
@@ -202,14 +438,48 @@ func (r BgpPeerReconciler) SetupWithManager(mgr ctrl.Manager) error {
 
 				return false
 			},
-		}).Complete(r)
+		})).
+		Watches(&source.Kind{Type: &v1alpha2.Eip{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.allPeers),
+		}, builder.WithPredicates(ep)).
+		Watches(secrets, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.peersOfSecret),
+		}, builder.WithPredicates(sp)).
+		Watches(&source.Kind{Type: &corev1.Node{}}, &EnqueueRequestForNode{Client: r.Client, peer: true},
+			builder.WithPredicates(np)).
+		Watches(&source.Kind{Type: &v1alpha2.BgpConf{}}, &EnqueueRequestForNode{Client: r.Client, peer: true},
+			builder.WithPredicates(cp)).
+		Complete(r)
+}
+
+func (r BgpPeerReconciler) allPeers(_ handler.MapObject) []reconcile.Request {
+	peers := &v1alpha2.BgpPeerList{}
+	err := r.List(context.Background(), peers)
+	if err != nil {
+		ctrl.Log.Error(err, "failed to list bgppeers")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, peer := range peers.Items {
+		if len(peer.Spec.AfiSafis) == 0 {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: peer.Name}})
+		}
+	}
+
+	return requests
 }
 
 func SetupBgpPeerReconciler(bgpServer *bgp.Bgp, mgr ctrl.Manager) error {
+	secrets, err := secretCache(mgr)
+	if err != nil {
+		return err
+	}
 	bgpPeer := BgpPeerReconciler{
 		Client:        mgr.GetClient(),
 		BgpServer:     bgpServer,
 		EventRecorder: mgr.GetEventRecorderFor("bgppeer"),
+		Secrets:       secrets,
 	}
 	if err := bgpPeer.SetupWithManager(mgr); err != nil {
 		return err
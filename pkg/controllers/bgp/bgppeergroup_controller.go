@@ -0,0 +1,214 @@
+/*
+Copyright 2022 The Kubesphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bgp
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/openelb/openelb/api/v1alpha2"
+	"github.com/openelb/openelb/pkg/constant"
+	"github.com/openelb/openelb/pkg/speaker/bgp"
+	"github.com/openelb/openelb/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// BgpPeerGroupReconciler reconciles a BgpPeerGroup object
+type BgpPeerGroupReconciler struct {
+	client.Client
+	BgpServer *bgp.Bgp
+	record.EventRecorder
+	// Secrets are the Secrets of the openelb namespace, see secretCache
+	Secrets cache.Cache
+}
+
+func peerGroupMatchNode(group *v1alpha2.BgpPeerGroup, node *corev1.Node) (bool, error) {
+	if group.Spec.NodeSelector == nil {
+		return true, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(group.Spec.NodeSelector)
+	if err != nil {
+		return false, fmt.Errorf("BgpPeerGroup %s spec.NodeSelector invalid, err=%v", group.Name, err)
+	}
+
+	return selector.Matches(labels.Set(node.GetLabels())), nil
+}
+
+// +kubebuilder:rbac:groups=network.kubesphere.io,resources=bgppeergroups,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=network.kubesphere.io,resources=bgppeergroups/status,verbs=get;update;patch
+
+func (r BgpPeerGroupReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.Log.WithValues("request", req.NamespacedName)
+
+	matchNode := true
+
+	group := &v1alpha2.BgpPeerGroup{}
+	err := r.Get(context.TODO(), req.NamespacedName, group)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	//filter group with nodeSelector
+	if group.Spec.NodeSelector != nil {
+		node := &corev1.Node{}
+		err = r.Get(context.Background(), types.NamespacedName{Name: util.GetNodeName()}, node)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		matchNode, err = peerGroupMatchNode(group, node)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	clone := group.DeepCopy()
+
+	if util.IsDeletionCandidate(clone, constant.FinalizerName) {
+		err := r.BgpServer.HandleBgpPeerGroup(clone, true)
+		if err != nil {
+			log.Error(err, "cannot delete bgp peer group, maybe need to delete manually")
+		}
+
+		controllerutil.RemoveFinalizer(clone, constant.FinalizerName)
+		return ctrl.Result{}, r.Update(context.Background(), clone)
+	}
+
+	if util.NeedToAddFinalizer(clone, constant.FinalizerName) {
+		controllerutil.AddFinalizer(clone, constant.FinalizerName)
+		err := r.Update(context.Background(), clone)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err = resolveGroupPassword(r.Secrets, clone); err != nil {
+		r.Event(group, corev1.EventTypeWarning, "InvalidPasswordSecret", err.Error())
+		return ctrl.Result{}, err
+	}
+
+	err = r.BgpServer.HandleBgpPeerGroup(clone, !matchNode)
+	if err != nil {
+		r.Event(group, corev1.EventTypeWarning, "AddPeerGroupFailed", err.Error())
+	}
+
+	return ctrl.Result{}, err
+}
+
+func (r BgpPeerGroupReconciler) Start(stopCh <-chan struct{}) error {
+	go r.run(stopCh)
+
+	return nil
+}
+
+func (r BgpPeerGroupReconciler) updatePeerGroupStatus() {
+	groups := &v1alpha2.BgpPeerGroupList{}
+	err := r.List(context.Background(), groups)
+	if err != nil {
+		return
+	}
+
+	status := r.BgpServer.HandleBgpPeerGroupStatus(groups.Items)
+
+	for i, group := range groups.Items {
+		if !reflect.DeepEqual(status[i].Status, group.Status) {
+			r.Status().Update(context.Background(), status[i])
+		}
+	}
+}
+
+func (r BgpPeerGroupReconciler) run(stopCh <-chan struct{}) {
+	t := time.NewTicker(time.Duration(syncStatusPeriod) * time.Second)
+
+	for {
+		select {
+		case <-t.C:
+			r.updatePeerGroupStatus()
+
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+func (r BgpPeerGroupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// The passwords of the groups are re-applied when their Secrets rotate
+	sp := predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldSecret := e.ObjectOld.(*corev1.Secret)
+			newSecret := e.ObjectNew.(*corev1.Secret)
+
+			return !reflect.DeepEqual(oldSecret.Data, newSecret.Data)
+		},
+	}
+	secrets, err := secretSource(r.Secrets)
+	if err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha2.BgpPeerGroup{}, builder.WithPredicates(predicate.Funcs{
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				oldGroup := e.ObjectOld.(*v1alpha2.BgpPeerGroup)
+				newGroup := e.ObjectNew.(*v1alpha2.BgpPeerGroup)
+
+				return !reflect.DeepEqual(oldGroup.DeletionTimestamp, newGroup.DeletionTimestamp) ||
+					!reflect.DeepEqual(oldGroup.Spec, newGroup.Spec)
+			},
+		})).
+		Watches(secrets, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.groupsOfSecret),
+		}, builder.WithPredicates(sp)).
+		Complete(r)
+}
+
+func SetupBgpPeerGroupReconciler(bgpServer *bgp.Bgp, mgr ctrl.Manager) error {
+	secrets, err := secretCache(mgr)
+	if err != nil {
+		return err
+	}
+	bgpPeerGroup := BgpPeerGroupReconciler{
+		Client:        mgr.GetClient(),
+		BgpServer:     bgpServer,
+		EventRecorder: mgr.GetEventRecorderFor("bgppeergroup"),
+		Secrets:       secrets,
+	}
+	if err := bgpPeerGroup.SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	return mgr.Add(bgpPeerGroup)
+}
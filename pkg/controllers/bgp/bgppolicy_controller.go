@@ -0,0 +1,109 @@
+/*
+Copyright 2022 The Kubesphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bgp
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/openelb/openelb/api/v1alpha2"
+	"github.com/openelb/openelb/pkg/constant"
+	"github.com/openelb/openelb/pkg/speaker/bgp"
+	"github.com/openelb/openelb/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// BgpPolicyReconciler reconciles a BgpPolicy object
+type BgpPolicyReconciler struct {
+	client.Client
+	BgpServer *bgp.Bgp
+	record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=network.kubesphere.io,resources=bgppolicies,verbs=get;list;watch;create;update;patch;delete
+
+func (r BgpPolicyReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.Log.WithValues("request", req.NamespacedName)
+
+	policy := &v1alpha2.BgpPolicy{}
+	err := r.Get(context.TODO(), req.NamespacedName, policy)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	clone := policy.DeepCopy()
+
+	if util.IsDeletionCandidate(clone, constant.FinalizerName) {
+		err := r.BgpServer.HandleBgpPolicy(clone, true)
+		if err != nil {
+			log.Error(err, "cannot delete bgp policy, maybe need to delete manually")
+		}
+
+		controllerutil.RemoveFinalizer(clone, constant.FinalizerName)
+		return ctrl.Result{}, r.Update(context.Background(), clone)
+	}
+
+	if util.NeedToAddFinalizer(clone, constant.FinalizerName) {
+		controllerutil.AddFinalizer(clone, constant.FinalizerName)
+		err := r.Update(context.Background(), clone)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	err = r.BgpServer.HandleBgpPolicy(clone, false)
+	if err != nil {
+		r.Event(policy, corev1.EventTypeWarning, "ApplyPolicyFailed", err.Error())
+	}
+
+	return ctrl.Result{}, err
+}
+
+func (r BgpPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha2.BgpPolicy{}, builder.WithPredicates(predicate.Funcs{
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				oldPolicy := e.ObjectOld.(*v1alpha2.BgpPolicy)
+				newPolicy := e.ObjectNew.(*v1alpha2.BgpPolicy)
+
+				return !reflect.DeepEqual(oldPolicy.DeletionTimestamp, newPolicy.DeletionTimestamp) ||
+					!reflect.DeepEqual(oldPolicy.Spec, newPolicy.Spec)
+			},
+		})).
+		Complete(r)
+}
+
+func SetupBgpPolicyReconciler(bgpServer *bgp.Bgp, mgr ctrl.Manager) error {
+	bgpPolicy := BgpPolicyReconciler{
+		Client:        mgr.GetClient(),
+		BgpServer:     bgpServer,
+		EventRecorder: mgr.GetEventRecorderFor("bgppolicy"),
+	}
+
+	return bgpPolicy.SetupWithManager(mgr)
+}
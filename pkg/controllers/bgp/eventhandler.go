@@ -18,14 +18,14 @@ type EnqueueRequestForNode struct {
 	peer bool
 }
 
-func (e *EnqueueRequestForNode) getDefaultBgpConf() []v1alpha2.BgpConf {
-	var def v1alpha2.BgpConf
+func (e *EnqueueRequestForNode) getBgpConfs() []v1alpha2.BgpConf {
+	var confs v1alpha2.BgpConfList
 
-	if err := e.Get(context.Background(), client.ObjectKey{Name: "default"}, &def); err != nil {
+	if err := e.List(context.Background(), &confs); err != nil {
 		return nil
 	}
 
-	return []v1alpha2.BgpConf{def}
+	return confs.Items
 }
 
 func (e *EnqueueRequestForNode) getBgpPeers() []v1alpha2.BgpPeer {
@@ -53,7 +53,7 @@ func (e *EnqueueRequestForNode) Update(evt event.UpdateEvent, q workqueue.RateLi
 	}
 
 	if !e.peer {
-		for _, svc := range e.getDefaultBgpConf() {
+		for _, svc := range e.getBgpConfs() {
 			q.Add(reconcile.Request{NamespacedName: types.NamespacedName{
 				Name:      svc.GetName(),
 				Namespace: svc.GetNamespace(),
@@ -0,0 +1,195 @@
+/*
+Copyright 2022 The Kubesphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bgp
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/openelb/openelb/api/v1alpha2"
+	"github.com/openelb/openelb/pkg/speaker/bgp"
+	"github.com/openelb/openelb/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// MeshReconciler peers this node with the other speakers as the mesh of its BgpConf requires
+type MeshReconciler struct {
+	client.Client
+	BgpServer *bgp.Bgp
+}
+
+func isRouteReflector(mesh *v1alpha2.Mesh, node *corev1.Node) (bool, error) {
+	if mesh.Mode != v1alpha2.MeshModeRouteReflector {
+		return false, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(mesh.RouteReflectorSelector)
+	if err != nil {
+		return false, fmt.Errorf("spec.mesh.routeReflectorSelector invalid, err=%v", err)
+	}
+
+	return selector.Matches(labels.Set(node.GetLabels())), nil
+}
+
+// peersUpstream reports whether node peers with the BgpPeers, in RouteReflector mode
+// only the route reflectors do.
+func peersUpstream(confs []v1alpha2.BgpConf, node *corev1.Node) (bool, error) {
+	conf, _ := effectiveConf(confs, node)
+	if conf == nil || conf.Spec.Mesh == nil || conf.Spec.Mesh.Mode != v1alpha2.MeshModeRouteReflector {
+		return true, nil
+	}
+
+	return isRouteReflector(conf.Spec.Mesh, node)
+}
+
+// meshPeers returns the speakers self peers with, they are the nodes with the same effective BgpConf.
+func meshPeers(confs []v1alpha2.BgpConf, nodes []corev1.Node, self *corev1.Node) ([]bgp.MeshPeer, error) {
+	conf, _ := effectiveConf(confs, self)
+	if conf == nil || conf.Spec.Mesh == nil {
+		return nil, nil
+	}
+	mesh := conf.Spec.Mesh
+
+	selfReflector, err := isRouteReflector(mesh, self)
+	if err != nil {
+		return nil, err
+	}
+	local, _ := nodeConf(conf, self)
+
+	var peers []bgp.MeshPeer
+	for i := range nodes {
+		node := &nodes[i]
+		if node.Name == self.Name {
+			continue
+		}
+		if effective, _ := effectiveConf(confs, node); effective == nil || effective.Name != conf.Name {
+			continue
+		}
+		ip := util.GetNodeIP(*node)
+		if ip == nil {
+			continue
+		}
+
+		reflector, err := isRouteReflector(mesh, node)
+		if err != nil {
+			return nil, err
+		}
+		// The clients only peer with the route reflectors
+		if mesh.Mode == v1alpha2.MeshModeRouteReflector && !selfReflector && !reflector {
+			continue
+		}
+
+		remote, _ := nodeConf(conf, node)
+		peer := bgp.MeshPeer{
+			Address: ip.String(),
+			As:      remote.Spec.As,
+		}
+		if conf.Spec.ListenPort > 0 {
+			peer.Port = uint32(conf.Spec.ListenPort)
+		}
+		if selfReflector && !reflector && remote.Spec.As == local.Spec.As {
+			peer.ReflectorClient = true
+			peer.ClusterId = mesh.ClusterId
+		}
+		peers = append(peers, peer)
+	}
+
+	return peers, nil
+}
+
+func (r MeshReconciler) Reconcile(_ ctrl.Request) (ctrl.Result, error) {
+	var confs v1alpha2.BgpConfList
+	err := r.List(context.Background(), &confs)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	var nodes corev1.NodeList
+	err = r.List(context.Background(), &nodes)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	for i := range nodes.Items {
+		if nodes.Items[i].Name != util.GetNodeName() {
+			continue
+		}
+
+		peers, err := meshPeers(confs.Items, nodes.Items, &nodes.Items[i])
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, r.BgpServer.HandleMesh(peers)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// self maps every event to this node, the mesh is always computed from all the nodes.
+func (r MeshReconciler) self(_ handler.MapObject) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: util.GetNodeName()}}}
+}
+
+func (r MeshReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	np := predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldNode := e.ObjectOld.(*corev1.Node)
+			newNode := e.ObjectNew.(*corev1.Node)
+
+			return !reflect.DeepEqual(oldNode.Labels, newNode.Labels) ||
+				!reflect.DeepEqual(oldNode.Status.Addresses, newNode.Status.Addresses)
+		},
+	}
+
+	cp := predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldConf := e.ObjectOld.(*v1alpha2.BgpConf)
+			newConf := e.ObjectNew.(*v1alpha2.BgpConf)
+
+			return !reflect.DeepEqual(oldConf.DeletionTimestamp, newConf.DeletionTimestamp) ||
+				!reflect.DeepEqual(oldConf.Spec, newConf.Spec)
+		},
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("MeshController").
+		For(&corev1.Node{}, builder.WithPredicates(np)).
+		Watches(&source.Kind{Type: &v1alpha2.BgpConf{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.self),
+		}, builder.WithPredicates(cp)).
+		Complete(r)
+}
+
+func SetupMeshReconciler(bgpServer *bgp.Bgp, mgr ctrl.Manager) error {
+	mesh := MeshReconciler{
+		Client:    mgr.GetClient(),
+		BgpServer: bgpServer,
+	}
+
+	return mesh.SetupWithManager(mgr)
+}
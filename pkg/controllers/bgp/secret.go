@@ -0,0 +1,174 @@
+package bgp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/openelb/openelb/api/v1alpha2"
+	"github.com/openelb/openelb/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const defaultPasswordKey = "password"
+
+var (
+	secretCachesLock sync.Mutex
+	// secretCaches are shared by the reconcilers of a manager
+	secretCaches = make(map[ctrl.Manager]cache.Cache)
+)
+
+// secretCache returns the cache of the Secrets of the openelb namespace, where the password
+// Secrets have to be, so that the Secrets of the other namespaces are neither listed nor cached.
+func secretCache(mgr ctrl.Manager) (cache.Cache, error) {
+	secretCachesLock.Lock()
+	defer secretCachesLock.Unlock()
+
+	if c, ok := secretCaches[mgr]; ok {
+		return c, nil
+	}
+
+	c, err := cache.New(mgr.GetConfig(), cache.Options{
+		Scheme:    mgr.GetScheme(),
+		Mapper:    mgr.GetRESTMapper(),
+		Namespace: util.EnvNamespace(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = mgr.Add(c); err != nil {
+		return nil, err
+	}
+	secretCaches[mgr] = c
+
+	return c, nil
+}
+
+// secretSource watches the Secrets of the cache returned by secretCache.
+func secretSource(c cache.Cache) (source.Source, error) {
+	informer, err := c.GetInformer(context.Background(), &corev1.Secret{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &source.Informer{Informer: informer}, nil
+}
+
+func passwordSecretKey(ref *v1alpha2.SecretKeyRef) types.NamespacedName {
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = util.EnvNamespace()
+	}
+
+	return types.NamespacedName{Namespace: namespace, Name: ref.Name}
+}
+
+// secretPassword returns the md5 password held in the Secret of ref, read from the
+// Secrets of the openelb namespace.
+func secretPassword(c client.Reader, ref *v1alpha2.SecretKeyRef) (string, error) {
+	key := ref.Key
+	if key == "" {
+		key = defaultPasswordKey
+	}
+	name := passwordSecretKey(ref)
+	if name.Namespace != util.EnvNamespace() {
+		return "", fmt.Errorf("password secret %s is not in namespace %s", name, util.EnvNamespace())
+	}
+
+	secret := &corev1.Secret{}
+	err := c.Get(context.Background(), name, secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to get password secret: %v", err)
+	}
+	password, ok := secret.Data[key]
+	if !ok {
+		return "", fmt.Errorf("password secret %s has no key %s", name, key)
+	}
+
+	return string(password), nil
+}
+
+// resolvePassword sets the md5 password of peer from its passwordSecretRef.
+// peer must be a copy, the password is never written back to the BgpPeer.
+func resolvePassword(c client.Reader, peer *v1alpha2.BgpPeer) error {
+	if peer.Spec.Conf == nil || peer.Spec.Conf.PasswordSecretRef == nil {
+		return nil
+	}
+
+	password, err := secretPassword(c, peer.Spec.Conf.PasswordSecretRef)
+	if err != nil {
+		return fmt.Errorf("BgpPeer %s %v", peer.Name, err)
+	}
+	peer.Spec.Conf.AuthPassword = password
+
+	return nil
+}
+
+// resolveGroupPassword sets the md5 password of group from its passwordSecretRef.
+// group must be a copy, the password is never written back to the BgpPeerGroup.
+func resolveGroupPassword(c client.Reader, group *v1alpha2.BgpPeerGroup) error {
+	if group.Spec.Conf == nil || group.Spec.Conf.PasswordSecretRef == nil {
+		return nil
+	}
+
+	password, err := secretPassword(c, group.Spec.Conf.PasswordSecretRef)
+	if err != nil {
+		return fmt.Errorf("BgpPeerGroup %s %v", group.Name, err)
+	}
+	group.Spec.Conf.AuthPassword = password
+
+	return nil
+}
+
+// peersOfSecret maps a Secret to the BgpPeers whose password it holds, so that they are re-applied on rotation.
+func (r BgpPeerReconciler) peersOfSecret(obj handler.MapObject) []reconcile.Request {
+	peers := &v1alpha2.BgpPeerList{}
+	err := r.List(context.Background(), peers)
+	if err != nil {
+		ctrl.Log.Error(err, "failed to list bgppeers")
+		return nil
+	}
+
+	name := types.NamespacedName{Namespace: obj.Meta.GetNamespace(), Name: obj.Meta.GetName()}
+	var requests []reconcile.Request
+	for _, peer := range peers.Items {
+		if peer.Spec.Conf == nil || peer.Spec.Conf.PasswordSecretRef == nil {
+			continue
+		}
+		if passwordSecretKey(peer.Spec.Conf.PasswordSecretRef) == name {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: peer.Name}})
+		}
+	}
+
+	return requests
+}
+
+// groupsOfSecret maps a Secret to the BgpPeerGroups whose password it holds, so that they are re-applied on rotation.
+func (r BgpPeerGroupReconciler) groupsOfSecret(obj handler.MapObject) []reconcile.Request {
+	groups := &v1alpha2.BgpPeerGroupList{}
+	err := r.List(context.Background(), groups)
+	if err != nil {
+		ctrl.Log.Error(err, "failed to list bgppeergroups")
+		return nil
+	}
+
+	name := types.NamespacedName{Namespace: obj.Meta.GetNamespace(), Name: obj.Meta.GetName()}
+	var requests []reconcile.Request
+	for _, group := range groups.Items {
+		if group.Spec.Conf == nil || group.Spec.Conf.PasswordSecretRef == nil {
+			continue
+		}
+		if passwordSecretKey(group.Spec.Conf.PasswordSecretRef) == name {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: group.Name}})
+		}
+	}
+
+	return requests
+}
@@ -17,8 +17,10 @@ package bgp
 
 import (
 	"context"
+	"net"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
@@ -37,6 +39,7 @@ import (
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/util/retry"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	"sigs.k8s.io/controller-runtime/pkg/envtest/printer"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
@@ -154,6 +157,9 @@ var _ = BeforeSuite(func(done Done) {
 	Expect(err).ToNot(HaveOccurred())
 	Expect(mgr).ToNot(BeNil())
 
+	// The password Secrets are read from the namespace of openelb
+	os.Setenv(constant.EnvOpenELBNamespace, "default")
+
 	// Setup all Controllers
 	bgpServer = bgp.NewGoBgpd(bgp.NewBgpOptions())
 	bgpServer.Start(stopCh)
@@ -161,6 +167,12 @@ var _ = BeforeSuite(func(done Done) {
 	Expect(err).ToNot(HaveOccurred())
 	err = SetupBgpConfReconciler(bgpServer, mgr)
 	Expect(err).ToNot(HaveOccurred())
+	err = SetupBgpPeerGroupReconciler(bgpServer, mgr)
+	Expect(err).ToNot(HaveOccurred())
+	err = SetupBgpPolicyReconciler(bgpServer, mgr)
+	Expect(err).ToNot(HaveOccurred())
+	err = SetupMeshReconciler(bgpServer, mgr)
+	Expect(err).ToNot(HaveOccurred())
 
 	go func() {
 		err := mgr.Start(stopCh)
@@ -438,6 +450,172 @@ var _ = Describe("Test GoBGP Controller", func() {
 				})
 			})
 
+			When("bgpPeer has a passwordSecretRef", func() {
+				secret := &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "peer1-password",
+						Namespace: "default",
+					},
+					Data: map[string][]byte{
+						"password": []byte("secret"),
+					},
+				}
+
+				BeforeEach(func() {
+					Expect(client.Client.Create(context.Background(), secret.DeepCopy())).ToNot(HaveOccurred())
+					clone := bgpPeer.DeepCopy()
+					clone.Spec.Conf.PasswordSecretRef = &v1alpha2.SecretKeyRef{
+						Name:      secret.Name,
+						Namespace: secret.Namespace,
+					}
+					Expect(client.Client.Create(context.Background(), clone)).ToNot(HaveOccurred())
+				})
+
+				AfterEach(func() {
+					Expect(client.Client.Delete(context.Background(), bgpPeer.DeepCopy())).ToNot(HaveOccurred())
+					Expect(client.Client.Delete(context.Background(), secret.DeepCopy())).ToNot(HaveOccurred())
+					Eventually(func() bool {
+						err := client.Client.Get(context.Background(), types.NamespacedName{Name: bgpPeer.Name}, bgpPeer.DeepCopy())
+						return k8serrors.IsNotFound(err)
+					}, 3*time.Second).Should(Equal(true))
+				})
+
+				It("BgpPeer should have status without the password", func() {
+					Eventually(checkBgpPeer(bgpPeer, func(dst *v1alpha2.BgpPeer) bool {
+						status, ok := dst.Status.NodesPeerStatus[util.GetNodeName()]
+						return ok && status.PeerState.AuthPassword == ""
+					}), 35*time.Second).Should(Equal(true))
+				})
+			})
+
+			When("bgpPeer has a template", func() {
+				const torAnnotation = "example.com/tor"
+				gateway := defaultGateway
+
+				BeforeEach(func() {
+					defaultGateway = func() (net.IP, net.IP, error) {
+						return net.ParseIP("192.168.0.254"), net.ParseIP("192.168.0.1"), nil
+					}
+					clone := bgpPeer.DeepCopy()
+					clone.Spec.Conf.NeighborAddress = ""
+					clone.Spec.Template = &v1alpha2.PeerTemplate{
+						NeighborAddress: &v1alpha2.NodeValueSource{Annotation: torAnnotation},
+						LocalAddress:    &v1alpha2.NodeValueSource{DefaultGateway: true},
+					}
+					Expect(client.Client.Create(context.Background(), clone)).ToNot(HaveOccurred())
+				})
+
+				AfterEach(func() {
+					defaultGateway = gateway
+					Expect(client.Client.Delete(context.Background(), bgpPeer.DeepCopy())).ToNot(HaveOccurred())
+					Eventually(func() bool {
+						err := client.Client.Get(context.Background(), types.NamespacedName{Name: bgpPeer.Name}, bgpPeer.DeepCopy())
+						return k8serrors.IsNotFound(err)
+					}, 3*time.Second).Should(Equal(true))
+				})
+
+				It("BgpPeer should be resolved on each node", func() {
+					Eventually(checkBgpPeer(bgpPeer, func(dst *v1alpha2.BgpPeer) bool {
+						status, ok := dst.Status.NodesPeerStatus[util.GetNodeName()]
+						return ok && status.Template != nil && status.Template.Error != ""
+					}), 3*time.Second).Should(Equal(true))
+
+					Expect(retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+						node := &corev1.Node{}
+						err := client.Client.Get(context.Background(), types.NamespacedName{Name: node1.Name}, node)
+						if err != nil {
+							return err
+						}
+						node.Annotations = map[string]string{torAnnotation: "192.168.0.253"}
+						return client.Client.Update(context.Background(), node)
+					})).ToNot(HaveOccurred())
+
+					Eventually(checkBgpPeer(bgpPeer, func(dst *v1alpha2.BgpPeer) bool {
+						status, ok := dst.Status.NodesPeerStatus[util.GetNodeName()]
+						return ok && reflect.DeepEqual(status.Template, &v1alpha2.ResolvedPeerTemplate{
+							NeighborAddress: "192.168.0.253",
+							LocalAddress:    "192.168.0.1",
+						})
+					}), 3*time.Second).Should(Equal(true))
+
+					Eventually(checkBgpPeer(bgpPeer, func(dst *v1alpha2.BgpPeer) bool {
+						status := dst.Status.NodesPeerStatus[util.GetNodeName()]
+						return status.PeerState.NeighborAddress == "192.168.0.253"
+					}), 35*time.Second).Should(Equal(true))
+				})
+			})
+
+			When("bgpPeerGroup has dynamic neighbors", func() {
+				group := &v1alpha2.BgpPeerGroup{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "group1",
+					},
+					Spec: v1alpha2.BgpPeerGroupSpec{
+						Conf: &v1alpha2.PeerGroupConf{
+							PeerAs: 65002,
+						},
+						DynamicNeighbors: []string{"192.168.2.0/24"},
+					},
+				}
+
+				BeforeEach(func() {
+					Expect(client.Client.Create(context.Background(), group.DeepCopy())).ToNot(HaveOccurred())
+				})
+
+				AfterEach(func() {
+					Expect(client.Client.Delete(context.Background(), group.DeepCopy())).ToNot(HaveOccurred())
+					Eventually(func() bool {
+						err := client.Client.Get(context.Background(), types.NamespacedName{Name: group.Name}, group.DeepCopy())
+						return k8serrors.IsNotFound(err)
+					}, 3*time.Second).Should(Equal(true))
+				})
+
+				It("BgpPeerGroup should have Finalizer", func() {
+					Eventually(func() bool {
+						clone := group.DeepCopy()
+						client.Client.Get(context.Background(), types.NamespacedName{Name: clone.Name}, clone)
+						return util.ContainsString(clone.Finalizers, constant.FinalizerName)
+					}, 3*time.Second).Should(Equal(true))
+				})
+			})
+
+			When("bgpPolicy is created", func() {
+				policy := &v1alpha2.BgpPolicy{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "policy1",
+					},
+					Spec: v1alpha2.BgpPolicySpec{
+						Statements: []v1alpha2.Statement{
+							{
+								Actions: v1alpha2.Actions{
+									RouteAction: v1alpha2.RouteActionReject,
+								},
+							},
+						},
+					},
+				}
+
+				BeforeEach(func() {
+					Expect(client.Client.Create(context.Background(), policy.DeepCopy())).ToNot(HaveOccurred())
+				})
+
+				AfterEach(func() {
+					Expect(client.Client.Delete(context.Background(), policy.DeepCopy())).ToNot(HaveOccurred())
+					Eventually(func() bool {
+						err := client.Client.Get(context.Background(), types.NamespacedName{Name: policy.Name}, policy.DeepCopy())
+						return k8serrors.IsNotFound(err)
+					}, 3*time.Second).Should(Equal(true))
+				})
+
+				It("BgpPolicy should have Finalizer", func() {
+					Eventually(func() bool {
+						clone := policy.DeepCopy()
+						client.Client.Get(context.Background(), types.NamespacedName{Name: clone.Name}, clone)
+						return util.ContainsString(clone.Finalizers, constant.FinalizerName)
+					}, 3*time.Second).Should(Equal(true))
+				})
+			})
+
 			When("bgpPeer has cni annotation", func() {
 				BeforeEach(func() {
 					clone := bgpPeer.DeepCopy()
@@ -471,6 +649,162 @@ var _ = Describe("Test GoBGP Controller", func() {
 			})
 		})
 	})
+
+	Context("BgpConfs select the node", func() {
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "edge1",
+				Labels: map[string]string{"role": "edge"},
+			},
+		}
+		newConf := func(name string, priority int32, role string) v1alpha2.BgpConf {
+			conf := v1alpha2.BgpConf{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Spec:       v1alpha2.BgpConfSpec{Priority: priority},
+			}
+			if role != "" {
+				conf.Spec.NodeSelector = &metav1.LabelSelector{
+					MatchLabels: map[string]string{"role": role},
+				}
+			}
+			return conf
+		}
+
+		It("The one with a nodeSelector wins over the default", func() {
+			effective, conflicts := effectiveConf([]v1alpha2.BgpConf{
+				newConf("default", 0, ""),
+				newConf("edge", 0, "edge"),
+				newConf("core", 10, "core"),
+			}, node)
+			Expect(effective.Name).Should(Equal("edge"))
+			Expect(conflicts).Should(BeEmpty())
+		})
+
+		It("The one with the highest priority wins", func() {
+			effective, conflicts := effectiveConf([]v1alpha2.BgpConf{
+				newConf("edge", 0, "edge"),
+				newConf("default", 10, ""),
+			}, node)
+			Expect(effective.Name).Should(Equal("default"))
+			Expect(conflicts).Should(BeEmpty())
+		})
+
+		It("The first one by name wins on the same precedence", func() {
+			effective, conflicts := effectiveConf([]v1alpha2.BgpConf{
+				newConf("edge2", 0, "edge"),
+				newConf("edge1", 0, "edge"),
+				newConf("default", 0, ""),
+			}, node)
+			Expect(effective.Name).Should(Equal("edge1"))
+			Expect(conflicts).Should(Equal([]string{"edge1", "edge2"}))
+		})
+
+		It("No one selects the node", func() {
+			effective, _ := effectiveConf([]v1alpha2.BgpConf{
+				newConf("core", 0, "core"),
+			}, node)
+			Expect(effective).Should(BeNil())
+		})
+	})
+
+	Context("Password secrets", func() {
+		secret := func(namespace string) *corev1.Secret {
+			return &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "peer-password",
+					Namespace: namespace,
+				},
+				Data: map[string][]byte{
+					"password": []byte("secret"),
+				},
+			}
+		}
+		c := fake.NewFakeClient(secret(util.EnvNamespace()), secret("other"))
+
+		It("Should read the password of the key", func() {
+			password, err := secretPassword(c, &v1alpha2.SecretKeyRef{Name: "peer-password"})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(password).Should(Equal("secret"))
+
+			_, err = secretPassword(c, &v1alpha2.SecretKeyRef{Name: "peer-password", Key: "none"})
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("Should only read the Secrets of the namespace of openelb", func() {
+			_, err := secretPassword(c, &v1alpha2.SecretKeyRef{Name: "peer-password", Namespace: "other"})
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("BgpConf has a mesh", func() {
+		newNode := func(name, address string, reflector bool) corev1.Node {
+			node := corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{}},
+				Status: corev1.NodeStatus{
+					Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: address}},
+				},
+			}
+			if reflector {
+				node.Labels["route-reflector"] = "true"
+			}
+			return node
+		}
+		nodes := []corev1.Node{
+			newNode("rr1", "10.0.0.1", true),
+			newNode("rr2", "10.0.0.2", true),
+			newNode("n3", "10.0.0.3", false),
+			newNode("n4", "10.0.0.4", false),
+		}
+		newConf := func(mode string) []v1alpha2.BgpConf {
+			return []v1alpha2.BgpConf{{
+				ObjectMeta: metav1.ObjectMeta{Name: "default"},
+				Spec: v1alpha2.BgpConfSpec{
+					As:         65001,
+					ListenPort: 17900,
+					Mesh: &v1alpha2.Mesh{
+						Mode: mode,
+						RouteReflectorSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"route-reflector": "true"},
+						},
+					},
+				},
+			}}
+		}
+		addresses := func(peers []bgp.MeshPeer) []string {
+			var result []string
+			for _, peer := range peers {
+				result = append(result, peer.Address)
+			}
+			return result
+		}
+
+		It("Every speaker peers with all the other ones in FullMesh mode", func() {
+			confs := newConf(v1alpha2.MeshModeFullMesh)
+			peers, err := meshPeers(confs, nodes, &nodes[2])
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(addresses(peers)).Should(Equal([]string{"10.0.0.1", "10.0.0.2", "10.0.0.4"}))
+			Expect(peers[0].As).Should(Equal(uint32(65001)))
+			Expect(peers[0].Port).Should(Equal(uint32(17900)))
+			Expect(peers[0].ReflectorClient).Should(BeFalse())
+			Expect(peersUpstream(confs, &nodes[2])).Should(BeTrue())
+		})
+
+		It("The clients only peer with the route reflectors in RouteReflector mode", func() {
+			confs := newConf(v1alpha2.MeshModeRouteReflector)
+			peers, err := meshPeers(confs, nodes, &nodes[2])
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(addresses(peers)).Should(Equal([]string{"10.0.0.1", "10.0.0.2"}))
+			Expect(peers[0].ReflectorClient).Should(BeFalse())
+			Expect(peersUpstream(confs, &nodes[2])).Should(BeFalse())
+
+			peers, err = meshPeers(confs, nodes, &nodes[0])
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(addresses(peers)).Should(Equal([]string{"10.0.0.2", "10.0.0.3", "10.0.0.4"}))
+			Expect(peers[0].ReflectorClient).Should(BeFalse())
+			Expect(peers[1].ReflectorClient).Should(BeTrue())
+			Expect(peersUpstream(confs, &nodes[0])).Should(BeTrue())
+		})
+	})
 })
 
 func checkBgpConf(bgpConf *v1alpha2.BgpConf, fn func(dst *v1alpha2.BgpConf) bool) func() bool {
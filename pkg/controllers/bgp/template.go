@@ -0,0 +1,150 @@
+package bgp
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/openelb/openelb/api/v1alpha2"
+	"github.com/vishvananda/netlink"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// defaultGateway returns the default gateway of the node and the address of the node on the route to it,
+// the IPv4 one if there is. It is a variable so that tests could run without the routes of a node.
+var defaultGateway = func() (gw net.IP, src net.IP, err error) {
+	for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		routes, err := netlink.RouteList(nil, family)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, route := range routes {
+			if route.Dst != nil || route.Gw == nil {
+				continue
+			}
+
+			src := route.Src
+			if src == nil {
+				routes, err := netlink.RouteGet(route.Gw)
+				if err != nil || len(routes) == 0 {
+					return nil, nil, fmt.Errorf("no route to default gateway %s, err=%v", route.Gw, err)
+				}
+				src = routes[0].Src
+			}
+
+			return route.Gw, src, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("no default gateway")
+}
+
+func nodeValue(source *v1alpha2.NodeValueSource, node *corev1.Node) (string, error) {
+	switch {
+	case source.Label != "":
+		value, ok := node.Labels[source.Label]
+		if !ok {
+			return "", fmt.Errorf("node %s has no label %s", node.Name, source.Label)
+		}
+		return value, nil
+	case source.Annotation != "":
+		value, ok := node.Annotations[source.Annotation]
+		if !ok {
+			return "", fmt.Errorf("node %s has no annotation %s", node.Name, source.Annotation)
+		}
+		return value, nil
+	}
+
+	return "", fmt.Errorf("no label or annotation to take the value from")
+}
+
+func nodeAddress(source *v1alpha2.NodeValueSource, node *corev1.Node, local bool) (string, error) {
+	if source.DefaultGateway {
+		gw, src, err := defaultGateway()
+		if err != nil {
+			return "", err
+		}
+		if local {
+			return src.String(), nil
+		}
+		return gw.String(), nil
+	}
+
+	value, err := nodeValue(source, node)
+	if err != nil {
+		return "", err
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return "", fmt.Errorf("invalid address %q", value)
+	}
+
+	return ip.String(), nil
+}
+
+// resolvePeerTemplate sets the fields of peer taken from node by spec.template, nil is returned
+// if peer has no template. peer must be a copy, the resolved fields are only reported in the status.
+func resolvePeerTemplate(peer *v1alpha2.BgpPeer, node *corev1.Node) (*v1alpha2.ResolvedPeerTemplate, error) {
+	template := peer.Spec.Template
+	if template == nil {
+		return nil, nil
+	}
+
+	if peer.Spec.Conf == nil {
+		peer.Spec.Conf = &v1alpha2.PeerConf{}
+	}
+
+	resolved := &v1alpha2.ResolvedPeerTemplate{}
+	if template.NeighborAddress != nil {
+		address, err := nodeAddress(template.NeighborAddress, node, false)
+		if err != nil {
+			return nil, fmt.Errorf("BgpPeer %s failed to resolve neighborAddress: %v", peer.Name, err)
+		}
+		peer.Spec.Conf.NeighborAddress = address
+		resolved.NeighborAddress = address
+	}
+
+	if template.LocalAddress != nil {
+		address, err := nodeAddress(template.LocalAddress, node, true)
+		if err != nil {
+			return nil, fmt.Errorf("BgpPeer %s failed to resolve localAddress: %v", peer.Name, err)
+		}
+		if peer.Spec.Transport == nil {
+			peer.Spec.Transport = &v1alpha2.Transport{}
+		}
+		peer.Spec.Transport.LocalAddress = address
+		resolved.LocalAddress = address
+	}
+
+	if template.PeerAs != nil {
+		value, err := nodeValue(template.PeerAs, node)
+		if err != nil {
+			return nil, fmt.Errorf("BgpPeer %s failed to resolve peerAs: %v", peer.Name, err)
+		}
+		as, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("BgpPeer %s failed to resolve peerAs: invalid as %q", peer.Name, value)
+		}
+		peer.Spec.Conf.PeerAs = uint32(as)
+		resolved.PeerAs = uint32(as)
+	}
+
+	return resolved, nil
+}
+
+// lastResolvedPeer sets the neighbor address of peer to the one resolved on the node before,
+// so that the session could be deleted after the template changed or stopped resolving.
+func lastResolvedPeer(peer *v1alpha2.BgpPeer, node string) *v1alpha2.BgpPeer {
+	status, ok := peer.Status.NodesPeerStatus[node]
+	if peer.Spec.Template == nil || !ok || status.Template == nil || status.Template.NeighborAddress == "" {
+		return nil
+	}
+
+	clone := peer.DeepCopy()
+	if clone.Spec.Conf == nil {
+		clone.Spec.Conf = &v1alpha2.PeerConf{}
+	}
+	clone.Spec.Conf.NeighborAddress = status.Template.NeighborAddress
+
+	return clone
+}
@@ -0,0 +1,191 @@
+package lb
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	"github.com/openelb/openelb/api/v1alpha2"
+	"github.com/openelb/openelb/pkg/constant"
+	"github.com/openelb/openelb/pkg/speaker"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// AggregateReconciler announces the prefix covering an Eip with spec.aggregate from every eligible node
+type AggregateReconciler struct {
+	client.Client
+	log logr.Logger
+}
+
+func (r *AggregateReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	log := r.log.WithValues("eip", req.Name)
+
+	sp := speaker.GetSpeaker(constant.OpenELBProtocolBGP)
+	if sp == nil {
+		return ctrl.Result{}, fmt.Errorf("speaker %s not registered", constant.OpenELBProtocolBGP)
+	}
+
+	eip := &v1alpha2.Eip{}
+	err := r.Get(context.TODO(), types.NamespacedName{Name: req.Name}, eip)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, r.withdrawOrphans(sp)
+		}
+		return ctrl.Result{}, err
+	}
+
+	// The old aggregate of the Eip is withdrawn as an orphan
+	if !aggregated(eip) {
+		return ctrl.Result{}, r.withdrawOrphans(sp)
+	}
+
+	prefix, err := eip.GetAggregate()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	nodeList := &corev1.NodeList{}
+	err = r.List(context.TODO(), nodeList)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	nodes := make([]corev1.Node, 0)
+	for _, node := range nodeList.Items {
+		if nodeEligible(&node) {
+			nodes = append(nodes, node)
+		}
+	}
+	log.Info("setBalancer for aggregate", "prefix", prefix, "nodes", len(nodes))
+	if err = sp.SetBalancer(prefix, nodes, &speaker.BalancerOptions{PathAttributes: eip.Spec.PathAttributes}); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, r.withdrawOrphans(sp)
+}
+
+// aggregated reports whether the aggregate of eip is announced.
+func aggregated(eip *v1alpha2.Eip) bool {
+	return eip.DeletionTimestamp == nil && !eip.Spec.Disable && eip.Spec.Aggregate != nil &&
+		eip.GetSpeakerName() == constant.OpenELBProtocolBGP
+}
+
+// withdrawOrphans withdraws the aggregates the speaker announces for no Eip, those of the Eips
+// deleted or changed, even while the manager was down. They are read from the speaker,
+// the only prefixes it announces besides the host routes of the Services.
+func (r *AggregateReconciler) withdrawOrphans(sp speaker.Speaker) error {
+	l, ok := sp.(speaker.Lister)
+	if !ok {
+		return nil
+	}
+
+	eips := &v1alpha2.EipList{}
+	if err := r.List(context.TODO(), eips); err != nil {
+		return err
+	}
+	desired := make(map[string]bool)
+	for _, eip := range eips.Items {
+		if !aggregated(&eip) {
+			continue
+		}
+		if prefix, err := eip.GetAggregate(); err == nil {
+			desired[prefix] = true
+		}
+	}
+
+	for _, prefix := range l.Balancers() {
+		_, cidr, err := net.ParseCIDR(prefix)
+		if err != nil || desired[cidr.String()] {
+			continue
+		}
+		if ones, bits := cidr.Mask.Size(); ones == bits {
+			continue
+		}
+
+		r.log.Info("delBalancer for aggregate", "prefix", prefix)
+		if err = sp.DelBalancer(prefix); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// hostRoute reports whether svc is still announced with a host route besides the aggregate of eip.
+func hostRoute(eip *v1alpha2.Eip, svc *corev1.Service) bool {
+	switch eip.GetHostRoutes() {
+	case v1alpha2.HostRoutesNone:
+		return false
+	case v1alpha2.HostRoutesLocal:
+		return svc.Spec.ExternalTrafficPolicy == corev1.ServiceExternalTrafficPolicyTypeLocal
+	}
+
+	return true
+}
+
+func (r *AggregateReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	ep := predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			old := e.ObjectOld.(*v1alpha2.Eip)
+			new := e.ObjectNew.(*v1alpha2.Eip)
+
+			return !reflect.DeepEqual(old.Spec, new.Spec) ||
+				!reflect.DeepEqual(old.DeletionTimestamp, new.DeletionTimestamp)
+		},
+	}
+
+	np := predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if nodeEligible(e.ObjectOld) != nodeEligible(e.ObjectNew) {
+				return true
+			}
+			return nodeAddrChange(e.ObjectOld, e.ObjectNew)
+		},
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha2.Eip{}, builder.WithPredicates(ep)).
+		Watches(&source.Kind{Type: &corev1.Node{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.aggregatedEips),
+		}, builder.WithPredicates(np)).
+		Named("AggregateController").
+		Complete(r)
+}
+
+// aggregatedEips maps a node event to the Eips announcing an aggregate.
+func (r *AggregateReconciler) aggregatedEips(_ handler.MapObject) []reconcile.Request {
+	eips := &v1alpha2.EipList{}
+	if err := r.List(context.TODO(), eips); err != nil {
+		r.log.Error(err, "failed to list eips")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, eip := range eips.Items {
+		if eip.Spec.Aggregate != nil {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: eip.Name}})
+		}
+	}
+
+	return requests
+}
+
+func SetupAggregateReconciler(mgr ctrl.Manager) error {
+	r := &AggregateReconciler{
+		Client: mgr.GetClient(),
+		log:    ctrl.Log.WithName("Aggregate"),
+	}
+
+	return r.SetupWithManager(mgr)
+}
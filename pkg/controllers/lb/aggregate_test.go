@@ -0,0 +1,52 @@
+package lb
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/openelb/openelb/api/v1alpha2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("AggregateReconciler", func() {
+	It("Should withdraw the aggregates announced for no Eip", func() {
+		scheme := runtime.NewScheme()
+		Expect(corev1.AddToScheme(scheme)).ShouldNot(HaveOccurred())
+		Expect(v1alpha2.AddToScheme(scheme)).ShouldNot(HaveOccurred())
+		aggregated := &v1alpha2.Eip{
+			ObjectMeta: metav1.ObjectMeta{Name: "aggregated"},
+			Spec: v1alpha2.EipSpec{
+				Address:   "10.1.0.0/24",
+				Aggregate: &v1alpha2.Aggregate{},
+			},
+		}
+		r := &AggregateReconciler{
+			Client: fake.NewFakeClientWithScheme(scheme, aggregated),
+			log:    ctrl.Log.WithName("Aggregate"),
+		}
+		defer func() {
+			for _, ip := range []string{"10.1.0.0/24", "10.1.0.1", "10.2.0.0/24", "10.3.0.0/16"} {
+				bgpFakeSpeak.DelBalancer(ip)
+			}
+		}()
+
+		// Left by an Eip deleted and by the old address of the Eip while the manager was down
+		Expect(bgpFakeSpeak.SetBalancer("10.2.0.0/24", nil, nil)).ShouldNot(HaveOccurred())
+		Expect(bgpFakeSpeak.SetBalancer("10.3.0.0/16", nil, nil)).ShouldNot(HaveOccurred())
+		Expect(bgpFakeSpeak.SetBalancer("10.1.0.1", nil, nil)).ShouldNot(HaveOccurred())
+
+		_, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: aggregated.Name}})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(bgpFakeSpeak.Balancers()).Should(ContainElements("10.1.0.0/24", "10.1.0.1"))
+		Expect(bgpFakeSpeak.Balancers()).ShouldNot(ContainElement("10.2.0.0/24"))
+		Expect(bgpFakeSpeak.Balancers()).ShouldNot(ContainElement("10.3.0.0/16"))
+
+		_, err = r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: "deleted"}})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(bgpFakeSpeak.Balancers()).Should(ContainElements("10.1.0.0/24", "10.1.0.1"))
+	})
+})
@@ -0,0 +1,125 @@
+package lb
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	networkv1alpha2 "github.com/openelb/openelb/api/v1alpha2"
+	"github.com/openelb/openelb/pkg/controllers/ipam"
+	"github.com/openelb/openelb/pkg/speaker"
+	"github.com/openelb/openelb/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// +kubebuilder:rbac:groups=network.kubesphere.io,resources=serviceannouncements,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=network.kubesphere.io,resources=serviceannouncements/status,verbs=get;update;patch
+
+// recordAnnouncement is called right after SetBalancer/DelBalancer, it returns spErr unchanged.
+// Failing to write the ServiceAnnouncement is only logged, so it never blocks the service.
+func (r *ServiceReconciler) recordAnnouncement(svc *corev1.Service, result ipam.IPAMResult, announced bool, nodes []corev1.Node, spErr error) error {
+	return r.recordPendingAnnouncement(svc, result, announced, false, nodes, spErr)
+}
+
+// recordPendingAnnouncement is recordAnnouncement for the SetBalancer calls the speaker may defer.
+func (r *ServiceReconciler) recordPendingAnnouncement(svc *corev1.Service, result ipam.IPAMResult, announced, pending bool, nodes []corev1.Node, spErr error) error {
+	if err := r.updateServiceAnnouncement(svc, result, announced, pending, nodes, spErr); err != nil {
+		r.log.Error(err, "failed to update ServiceAnnouncement", "service", svc.Namespace+"/"+svc.Name)
+	}
+
+	return spErr
+}
+
+// updateServiceAnnouncement records the result of the speaker on this node.
+// nodes are the nodes passed to the speaker, and are ignored if the address is withdrawn.
+func (r *ServiceReconciler) updateServiceAnnouncement(svc *corev1.Service, result ipam.IPAMResult, announced, pending bool, nodes []corev1.Node, spErr error) error {
+	nodeStatus := networkv1alpha2.NodeAnnouncementStatus{
+		Announced: announced && !pending && spErr == nil,
+		Pending:   pending && spErr == nil,
+	}
+	if announced {
+		for _, node := range nodes {
+			nodeStatus.Nodes = append(nodeStatus.Nodes, node.Name)
+		}
+	}
+	if spErr != nil {
+		nodeStatus.Error = spErr.Error()
+	}
+
+	key := types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}
+	if svc.DeletionTimestamp != nil {
+		// The garbage collector may already be done with the service, never create one it would leak
+		return r.deleteServiceAnnouncement(key)
+	}
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		sa := &networkv1alpha2.ServiceAnnouncement{}
+		err := r.Get(context.Background(), key, sa)
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				return err
+			}
+			sa = &networkv1alpha2.ServiceAnnouncement{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      svc.Name,
+					Namespace: svc.Namespace,
+				},
+			}
+			// Deleted together with the service by the garbage collector
+			if err = controllerutil.SetControllerReference(svc, sa, r.scheme); err != nil {
+				return err
+			}
+			if err = r.Create(context.Background(), sa); err != nil {
+				return err
+			}
+		}
+
+		clone := sa.DeepCopy()
+		if result.Addr != "" {
+			clone.Status.Eip = result.Eip
+			clone.Status.Address = result.Addr
+			clone.Status.Protocol = result.Protocol
+		}
+		if clone.Status.NodesAnnouncementStatus == nil {
+			clone.Status.NodesAnnouncementStatus = make(map[string]networkv1alpha2.NodeAnnouncementStatus)
+		}
+		nodeName := util.GetNodeName()
+		old, ok := clone.Status.NodesAnnouncementStatus[nodeName]
+		nodeStatus.LastTransitionTime = old.LastTransitionTime
+		if !ok || !reflect.DeepEqual(old, nodeStatus) {
+			nodeStatus.LastTransitionTime = metav1.Now()
+		}
+		clone.Status.NodesAnnouncementStatus[nodeName] = nodeStatus
+
+		if reflect.DeepEqual(clone.Status, sa.Status) {
+			return nil
+		}
+		return r.Status().Update(context.Background(), clone)
+	})
+}
+
+// deleteServiceAnnouncement deletes the ServiceAnnouncement of a service being deleted.
+func (r *ServiceReconciler) deleteServiceAnnouncement(key types.NamespacedName) error {
+	sa := &networkv1alpha2.ServiceAnnouncement{}
+	sa.Namespace = key.Namespace
+	sa.Name = key.Name
+	err := r.Delete(context.Background(), sa)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+
+	return err
+}
+
+// pendingDelay returns how long until the speaker applies the deferred changes of ip, 0 if there are none.
+func pendingDelay(sp speaker.Speaker, ip string) time.Duration {
+	if deferrer, ok := sp.(speaker.Deferrer); ok {
+		return deferrer.Pending(ip)
+	}
+
+	return 0
+}
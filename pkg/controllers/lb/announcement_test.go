@@ -0,0 +1,70 @@
+package lb
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/openelb/openelb/api/v1alpha2"
+	"github.com/openelb/openelb/pkg/constant"
+	"github.com/openelb/openelb/pkg/controllers/ipam"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("ServiceAnnouncement of a Service being deleted", func() {
+	var (
+		scheme *runtime.Scheme
+		svc    *corev1.Service
+		key    types.NamespacedName
+		result = ipam.IPAMResult{Addr: "10.1.0.1", Eip: "eip", Protocol: constant.OpenELBProtocolBGP}
+	)
+
+	newReconciler := func(objs ...runtime.Object) *ServiceReconciler {
+		return &ServiceReconciler{
+			Client: fake.NewFakeClientWithScheme(scheme, objs...),
+			log:    ctrl.Log.WithName("Manager"),
+			scheme: scheme,
+		}
+	}
+
+	BeforeEach(func() {
+		scheme = runtime.NewScheme()
+		Expect(corev1.AddToScheme(scheme)).ShouldNot(HaveOccurred())
+		Expect(v1alpha2.AddToScheme(scheme)).ShouldNot(HaveOccurred())
+		now := metav1.Now()
+		svc = &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "deleted",
+				Namespace:         "default",
+				DeletionTimestamp: &now,
+			},
+		}
+		key = types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}
+	})
+
+	It("Should not be created", func() {
+		r := newReconciler()
+		spErr := errors.New("speaker error")
+
+		Expect(r.recordAnnouncement(svc, result, false, nil, spErr)).Should(Equal(spErr))
+		err := r.Get(context.Background(), key, &v1alpha2.ServiceAnnouncement{})
+		Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+	})
+
+	It("Should be deleted", func() {
+		r := newReconciler(&v1alpha2.ServiceAnnouncement{
+			ObjectMeta: metav1.ObjectMeta{Name: svc.Name, Namespace: svc.Namespace},
+		})
+
+		Expect(r.recordAnnouncement(svc, result, false, nil, nil)).ShouldNot(HaveOccurred())
+		err := r.Get(context.Background(), key, &v1alpha2.ServiceAnnouncement{})
+		Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+	})
+})
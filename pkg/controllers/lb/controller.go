@@ -22,12 +22,14 @@ import (
 	"math/rand"
 	"net/http"
 	"reflect"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/openelb/openelb/api/v1alpha2"
 	networkv1alpha2 "github.com/openelb/openelb/api/v1alpha2"
 	"github.com/openelb/openelb/pkg/constant"
 	"github.com/openelb/openelb/pkg/controllers/ipam"
+	"github.com/openelb/openelb/pkg/speaker"
 	"github.com/openelb/openelb/pkg/util"
 	"github.com/openelb/openelb/pkg/validate"
 	appsv1 "k8s.io/api/apps/v1"
@@ -64,8 +66,11 @@ import (
 // ServiceReconciler reconciles a Service object
 type ServiceReconciler struct {
 	client.Client
-	log logr.Logger
+	log    logr.Logger
+	scheme *runtime.Scheme
 	record.EventRecorder
+	// ResyncPeriod is the period of reconciling all the OpenELB Services, 0 disables it
+	ResyncPeriod time.Duration
 }
 
 func (r *ServiceReconciler) shouldReconcileEP(e metav1.Object) bool {
@@ -149,6 +154,27 @@ func (r *ServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		return err
 	}
 
+	eipp := predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return false
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return false
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			old := e.ObjectOld.(*v1alpha2.Eip)
+			new := e.ObjectNew.(*v1alpha2.Eip)
+
+			return old.Spec.WithdrawWithoutEndpoints != new.Spec.WithdrawWithoutEndpoints ||
+				!reflect.DeepEqual(old.Spec.PathAttributes, new.Spec.PathAttributes) ||
+				old.GetHostRoutes() != new.GetHostRoutes()
+		},
+	}
+	err = ctl.Watch(&source.Kind{Type: &v1alpha2.Eip{}}, &EnqueueRequestForNode{Client: r.Client}, eipp)
+	if err != nil {
+		return err
+	}
+
 	np := predicate.Funcs{
 		UpdateFunc: func(e event.UpdateEvent) bool {
 			if nodeReady(e.ObjectOld) != nodeReady(e.ObjectNew) {
@@ -157,20 +183,42 @@ func (r *ServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 			if nodeAddrChange(e.ObjectOld, e.ObjectNew) {
 				return true
 			}
+			if nodeExcluded(e.ObjectOld) != nodeExcluded(e.ObjectNew) {
+				return true
+			}
 			return false
 		},
 		CreateFunc: func(e event.CreateEvent) bool {
 			return false
 		},
 		DeleteFunc: func(e event.DeleteEvent) bool {
-			return false
+			return true
 		},
 	}
+	err = mgr.GetFieldIndexer().IndexField(context.TODO(), &corev1.Endpoints{}, endpointsNodeNameIndex, endpointsNodeNames)
+	if err != nil {
+		return err
+	}
+	err = mgr.GetFieldIndexer().IndexField(context.TODO(), &corev1.Service{}, serviceTrafficPolicyIndex, serviceTrafficPolicy)
+	if err != nil {
+		return err
+	}
 	err = ctl.Watch(&source.Kind{Type: &corev1.Node{}}, &EnqueueRequestForNode{Client: r.Client}, np)
 	if err != nil {
 		return err
 	}
 
+	if r.ResyncPeriod > 0 {
+		resync := newResync(r.ResyncPeriod)
+		if err = mgr.Add(resync); err != nil {
+			return err
+		}
+		err = ctl.Watch(&source.Channel{Source: resync.events}, &EnqueueRequestForNode{Client: r.Client})
+		if err != nil {
+			return err
+		}
+	}
+
 	// If there's any Service be deployed by OpenELB NodeProxy, controller will create Deployment or DaemonSet for Proxy Pod
 	// If the status of such Deployment or DaemonSet changed, all OpenELB NodeProxy should be reconciled
 	dedsp := predicate.Funcs{
@@ -200,7 +248,14 @@ func (r *ServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 }
 
 func (r *ServiceReconciler) callSetLoadBalancer(result ipam.IPAMResult, svc *corev1.Service) error {
-	nodes, err := r.getServiceNodes(svc)
+	eip := &v1alpha2.Eip{}
+	err := r.Get(context.TODO(), types.NamespacedName{Name: result.Eip}, eip)
+	if err != nil {
+		return err
+	}
+
+	withdraw := withdrawWithoutEndpoints(eip, svc)
+	nodes, weights, err := r.getServiceNodes(svc, withdraw)
 	if err != nil {
 		return err
 	}
@@ -210,7 +265,8 @@ func (r *ServiceReconciler) callSetLoadBalancer(result ipam.IPAMResult, svc *cor
 	var announceNodes []corev1.Node
 	if result.Protocol == constant.OpenELBProtocolLayer2 {
 		if len(nodes) == 0 {
-			return result.Sp.DelBalancer(svcIP)
+			err = result.Sp.DelBalancer(svcIP)
+			return r.recordAnnouncement(svc, result, false, nil, err)
 		}
 
 		index := rand.Int() % len(nodes)
@@ -242,11 +298,31 @@ func (r *ServiceReconciler) callSetLoadBalancer(result ipam.IPAMResult, svc *cor
 	} else {
 		announceNodes = append(announceNodes, nodes...)
 	}
+	if result.Protocol == constant.OpenELBProtocolBGP && !hostRoute(eip, svc) {
+		// Covered by the aggregate of the Eip
+		err = result.Sp.DelBalancer(svcIP)
+		return r.recordAnnouncement(svc, result, true, nil, err)
+	}
+	options := &speaker.BalancerOptions{}
+	if result.Protocol == constant.OpenELBProtocolBGP {
+		options.Weights = weights
+		options.PathAttributes, err = servicePathAttributes(eip, svc)
+		if err != nil {
+			return err
+		}
+	}
 	if result.Protocol == constant.OpenELBProtocolVip {
 		vip := fmt.Sprintf("%s:%s", svcIP, svc.Namespace+"/"+svc.Name)
-		return result.Sp.SetBalancer(vip, nil)
+		if withdraw && len(nodes) == 0 {
+			err = result.Sp.DelBalancer(vip)
+			return r.recordAnnouncement(svc, result, false, nil, err)
+		}
+		err = result.Sp.SetBalancer(vip, nil, nil)
+		return r.recordAnnouncement(svc, result, true, nil, err)
 	}
-	return result.Sp.SetBalancer(svcIP, announceNodes)
+	err = result.Sp.SetBalancer(svcIP, announceNodes, options)
+	pending := err == nil && pendingDelay(result.Sp, svcIP) > 0
+	return r.recordPendingAnnouncement(svc, result, len(announceNodes) > 0, pending, announceNodes, err)
 }
 
 func (r *ServiceReconciler) callDelLoadBalancer(result ipam.IPAMResult, svc *corev1.Service) error {
@@ -258,11 +334,14 @@ func (r *ServiceReconciler) callDelLoadBalancer(result ipam.IPAMResult, svc *cor
 				return err
 			}
 		}
+		var err error
 		if result.Protocol == constant.OpenELBProtocolVip {
 			vip := fmt.Sprintf("%s:%s", result.Addr, svc.Namespace+"/"+svc.Name)
-			return result.Sp.DelBalancer(vip)
+			err = result.Sp.DelBalancer(vip)
+		} else {
+			err = result.Sp.DelBalancer(result.Addr)
 		}
-		return result.Sp.DelBalancer(result.Addr)
+		return r.recordAnnouncement(svc, result, false, nil, err)
 	}
 	return nil
 }
@@ -332,6 +411,11 @@ func (r *ServiceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		if err != nil {
 			return ctrl.Result{}, err
 		}
+
+		// Record the announcement once the speaker applied the deferred changes
+		if delay := pendingDelay(result.Sp, result.Addr); delay > 0 {
+			return ctrl.Result{RequeueAfter: delay}, r.updateServiceEipInfo(result, svc)
+		}
 	}
 
 	return ctrl.Result{}, r.updateServiceEipInfo(result, svc)
@@ -415,41 +499,67 @@ func (r *ServiceReconciler) constructIPAMArgs(svc *corev1.Service) ipam.IPAMArgs
 	return args
 }
 
+// withdrawWithoutEndpoints reports whether the address of svc should be withdrawn while it has
+// no ready endpoints, the annotation of the service takes precedence over the Eip.
+func withdrawWithoutEndpoints(eip *v1alpha2.Eip, svc *corev1.Service) bool {
+	if ok, withdraw := validate.HasOpenELBWithdrawAnnotation(svc.Annotations); ok {
+		return withdraw
+	}
+
+	return eip.Spec.WithdrawWithoutEndpoints
+}
+
 // The caller should check if the slice is empty.
-func (r *ServiceReconciler) getServiceNodes(svc *corev1.Service) ([]corev1.Node, error) {
+// If withdraw is true, no nodes are returned while the service has no ready endpoints.
+// The weights by node name are only set for weighted ECMP, see validate.HasOpenELBWeightedECMPAnnotation.
+func (r *ServiceReconciler) getServiceNodes(svc *corev1.Service, withdraw bool) ([]corev1.Node, map[string]uint32, error) {
 	//1. filter endpoints
 	endpoints := &corev1.Endpoints{}
 	err := r.Get(context.TODO(), types.NamespacedName{Namespace: svc.GetNamespace(), Name: svc.GetName()}, endpoints)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	active := make(map[string]bool)
+	// number of ready endpoints on each node
+	active := make(map[string]int)
+	ready := 0
 	for _, subnet := range endpoints.Subsets {
+		ready += len(subnet.Addresses)
 		for _, addr := range subnet.Addresses {
 			if addr.NodeName == nil {
 				continue
 			}
-			active[*addr.NodeName] = true
+			active[*addr.NodeName]++
 		}
 	}
 
+	resultNodes := make([]corev1.Node, 0)
+	if withdraw && ready == 0 {
+		return resultNodes, nil, nil
+	}
+
 	//2. get next hops
 	nodeList := &corev1.NodeList{}
 	err = r.List(context.TODO(), nodeList)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	resultNodes := make([]corev1.Node, 0)
 	if svc.Spec.ExternalTrafficPolicy == corev1.ServiceExternalTrafficPolicyTypeLocal && len(active) > 0 {
+		var weights map[string]uint32
+		if validate.HasOpenELBWeightedECMPAnnotation(svc.Annotations) {
+			weights = make(map[string]uint32)
+		}
 		for _, node := range nodeList.Items {
-			if active[node.Name] {
+			if active[node.Name] > 0 && !nodeExcluded(&node) {
+				if weights != nil {
+					weights[node.Name] = uint32(active[node.Name])
+				}
 				resultNodes = append(resultNodes, node)
 			}
 		}
 
-		return resultNodes, nil
+		return resultNodes, weights, nil
 	}
 
 	if svc.Spec.ExternalTrafficPolicy == corev1.ServiceExternalTrafficPolicyTypeLocal && len(active) == 0 {
@@ -460,19 +570,21 @@ func (r *ServiceReconciler) getServiceNodes(svc *corev1.Service) ([]corev1.Node,
 	}
 
 	for _, node := range nodeList.Items {
-		if nodeReady(&node) {
+		if nodeReady(&node) && !nodeExcluded(&node) {
 			resultNodes = append(resultNodes, node)
 		}
 	}
 
-	return resultNodes, nil
+	return resultNodes, nil, nil
 }
 
-func SetupServiceReconciler(mgr ctrl.Manager) error {
+func SetupServiceReconciler(mgr ctrl.Manager, resyncPeriod time.Duration) error {
 	lb := &ServiceReconciler{
 		Client:        mgr.GetClient(),
 		log:           ctrl.Log.WithName("Manager"),
+		scheme:        mgr.GetScheme(),
 		EventRecorder: mgr.GetEventRecorderFor("Manager"),
+		ResyncPeriod:  resyncPeriod,
 	}
 	err := lb.SetupWithManager(mgr)
 	return err
@@ -2,7 +2,11 @@ package lb
 
 import (
 	"context"
+	"reflect"
+	"strings"
 
+	"github.com/openelb/openelb/pkg/constant"
+	"github.com/openelb/openelb/pkg/metrics"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -15,6 +19,42 @@ import (
 
 var nodeEnqueueLog = ctrl.Log.WithName("eventhandler").WithName("EnqueueRequestForNode")
 
+const (
+	// Index of Endpoints by the nodes their ready addresses are on
+	endpointsNodeNameIndex = "subsets.addresses.nodeName"
+	// Index of Services by externalTrafficPolicy, empty policy is indexed as Cluster
+	serviceTrafficPolicyIndex = "spec.externalTrafficPolicy"
+)
+
+func endpointsNodeNames(rawObj runtime.Object) []string {
+	ep := rawObj.(*corev1.Endpoints)
+
+	var result []string
+	found := make(map[string]bool)
+	for _, subnet := range ep.Subsets {
+		for _, addr := range subnet.Addresses {
+			if addr.NodeName == nil || found[*addr.NodeName] {
+				continue
+			}
+			found[*addr.NodeName] = true
+			result = append(result, *addr.NodeName)
+		}
+	}
+
+	return result
+}
+
+func serviceTrafficPolicy(rawObj runtime.Object) []string {
+	svc := rawObj.(*corev1.Service)
+
+	if svc.Spec.ExternalTrafficPolicy == corev1.ServiceExternalTrafficPolicyTypeLocal {
+		return []string{string(corev1.ServiceExternalTrafficPolicyTypeLocal)}
+	}
+	return []string{string(corev1.ServiceExternalTrafficPolicyTypeCluster)}
+}
+
+// EnqueueRequestForNode enqueues the OpenELB Services affected by a Node event.
+// For any other object, e.g. BgpConf, all OpenELB Services are enqueued.
 type EnqueueRequestForNode struct {
 	client.Client
 }
@@ -37,6 +77,96 @@ func (e *EnqueueRequestForNode) getServices() []corev1.Service {
 	return result
 }
 
+// getNodeServices returns the OpenELB Services that use the node as a nexthop.
+// Services with endpoints on the node are looked up through the endpoints index,
+// Services with externalTrafficPolicy=Cluster are only returned if cluster is true,
+// and so are the ones with externalTrafficPolicy=Local without ready endpoints on any node,
+// which fall back to all nodes.
+func (e *EnqueueRequestForNode) getNodeServices(nodeName string, cluster bool) []corev1.Service {
+	var result []corev1.Service
+	found := make(map[types.NamespacedName]bool)
+
+	if cluster {
+		var svcs corev1.ServiceList
+		err := e.List(context.Background(), &svcs, client.MatchingFields{
+			serviceTrafficPolicyIndex: string(corev1.ServiceExternalTrafficPolicyTypeCluster),
+		})
+		if err != nil {
+			nodeEnqueueLog.Error(err, "Failed to list services", "node", nodeName)
+		}
+		for _, svc := range svcs.Items {
+			if IsOpenELBService(&svc) {
+				found[types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}] = true
+				result = append(result, svc)
+			}
+		}
+
+		var locals corev1.ServiceList
+		err = e.List(context.Background(), &locals, client.MatchingFields{
+			serviceTrafficPolicyIndex: string(corev1.ServiceExternalTrafficPolicyTypeLocal),
+		})
+		if err != nil {
+			nodeEnqueueLog.Error(err, "Failed to list services", "node", nodeName)
+		}
+		for _, svc := range locals.Items {
+			key := types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}
+			if IsOpenELBService(&svc) && !e.hasNodeEndpoints(key) {
+				found[key] = true
+				result = append(result, svc)
+			}
+		}
+	}
+
+	var eps corev1.EndpointsList
+	err := e.List(context.Background(), &eps, client.MatchingFields{endpointsNodeNameIndex: nodeName})
+	if err != nil {
+		nodeEnqueueLog.Error(err, "Failed to list endpoints", "node", nodeName)
+		return result
+	}
+	for _, ep := range eps.Items {
+		key := types.NamespacedName{Namespace: ep.Namespace, Name: ep.Name}
+		if found[key] {
+			continue
+		}
+
+		var svc corev1.Service
+		if err := e.Get(context.Background(), key, &svc); err != nil {
+			continue
+		}
+		if IsOpenELBService(&svc) {
+			found[key] = true
+			result = append(result, svc)
+		}
+	}
+
+	return result
+}
+
+// hasNodeEndpoints returns true if the Endpoints of the service have ready addresses on a node.
+func (e *EnqueueRequestForNode) hasNodeEndpoints(key types.NamespacedName) bool {
+	var ep corev1.Endpoints
+	if err := e.Get(context.Background(), key, &ep); err != nil {
+		return false
+	}
+
+	return len(endpointsNodeNames(&ep)) > 0
+}
+
+func (e *EnqueueRequestForNode) enqueue(obj runtime.Object, svcs []corev1.Service, q workqueue.RateLimitingInterface) {
+	for _, svc := range svcs {
+		q.Add(reconcile.Request{NamespacedName: types.NamespacedName{
+			Name:      svc.GetName(),
+			Namespace: svc.GetNamespace(),
+		}})
+	}
+
+	kind := "Node"
+	if _, ok := obj.(*corev1.Node); !ok {
+		kind = reflect.TypeOf(obj).Elem().Name()
+	}
+	metrics.UpdateEnqueuedServicesMetrics(kind, len(svcs))
+}
+
 // Create implements EventHandler
 func (e *EnqueueRequestForNode) Create(evt event.CreateEvent, q workqueue.RateLimitingInterface) {
 	if evt.Meta == nil {
@@ -44,12 +174,17 @@ func (e *EnqueueRequestForNode) Create(evt event.CreateEvent, q workqueue.RateLi
 		return
 	}
 
-	for _, svc := range e.getServices() {
-		q.Add(reconcile.Request{NamespacedName: types.NamespacedName{
-			Name:      svc.GetName(),
-			Namespace: svc.GetNamespace(),
-		}})
+	if _, ok := evt.Object.(*corev1.Node); !ok {
+		e.enqueue(evt.Object, e.getServices(), q)
+		return
 	}
+
+	e.enqueue(evt.Object, e.getNodeServices(evt.Meta.GetName(), nodeEligible(evt.Object)), q)
+}
+
+// nodeEligible returns true if the node is used as a nexthop by ETP=Cluster services.
+func nodeEligible(obj runtime.Object) bool {
+	return nodeReady(obj) && !nodeExcluded(obj)
 }
 
 func nodeReady(obj runtime.Object) bool {
@@ -70,6 +205,27 @@ func nodeReady(obj runtime.Object) bool {
 	return ready
 }
 
+// nodeExcluded returns true if the node should not be used as a nexthop,
+// either because it is cordoned or it has been labeled to be excluded.
+func nodeExcluded(obj runtime.Object) bool {
+	node := obj.(*corev1.Node)
+
+	if node.Spec.Unschedulable {
+		return true
+	}
+
+	if node.Labels != nil {
+		if _, ok := node.Labels[constant.KubernetesExcludeBalancersLabel]; ok {
+			return true
+		}
+		if strings.ToLower(node.Labels[constant.OpenELBNodeAnnounceLabel]) == "false" {
+			return true
+		}
+	}
+
+	return false
+}
+
 // When Node addr changed, system should update all OpenELB services
 func nodeAddrChange(oldObj runtime.Object, newObj runtime.Object) bool {
 	addrChange := false
@@ -108,14 +264,20 @@ func (e *EnqueueRequestForNode) Update(evt event.UpdateEvent, q workqueue.RateLi
 
 	if evt.MetaNew == nil {
 		nodeEnqueueLog.Error(nil, "UpdateEvent received with no new metadata", "event", evt)
+		return
 	}
 
-	for _, svc := range e.getServices() {
-		q.Add(reconcile.Request{NamespacedName: types.NamespacedName{
-			Name:      svc.GetName(),
-			Namespace: svc.GetNamespace(),
-		}})
+	if _, ok := evt.ObjectNew.(*corev1.Node); !ok {
+		e.enqueue(evt.ObjectNew, e.getServices(), q)
+		return
+	}
+
+	// A node that was and still is ineligible isn't a nexthop of any ETP=Cluster service
+	cluster := nodeEligible(evt.ObjectNew)
+	if evt.ObjectOld != nil {
+		cluster = cluster || nodeEligible(evt.ObjectOld)
 	}
+	e.enqueue(evt.ObjectNew, e.getNodeServices(evt.MetaNew.GetName(), cluster), q)
 }
 
 // Delete implements EventHandler
@@ -124,17 +286,23 @@ func (e *EnqueueRequestForNode) Delete(evt event.DeleteEvent, q workqueue.RateLi
 		nodeEnqueueLog.Error(nil, "DeleteEvent received with no metadata", "event", evt)
 		return
 	}
-	for _, svc := range e.getServices() {
-		q.Add(reconcile.Request{NamespacedName: types.NamespacedName{
-			Name:      svc.GetName(),
-			Namespace: svc.GetNamespace(),
-		}})
+
+	if _, ok := evt.Object.(*corev1.Node); !ok {
+		e.enqueue(evt.Object, e.getServices(), q)
+		return
 	}
+
+	e.enqueue(evt.Object, e.getNodeServices(evt.Meta.GetName(), nodeEligible(evt.Object)), q)
 }
 
-// Generic implements EventHandler
+// Generic implements EventHandler, the events of the resync enqueue all OpenELB Services.
 func (e *EnqueueRequestForNode) Generic(evt event.GenericEvent, q workqueue.RateLimitingInterface) {
+	if evt.Meta == nil {
+		nodeEnqueueLog.Error(nil, "GenericEvent received with no metadata", "event", evt)
+		return
+	}
 
+	e.enqueue(evt.Object, e.getServices(), q)
 }
 
 var deAndDsEnqueueLog = ctrl.Log.WithName("eventhandler").WithName("EnqueueRequestForDeAndDs")
@@ -0,0 +1,91 @@
+package lb
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/openelb/openelb/pkg/constant"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("EnqueueRequestForNode", func() {
+	It("Should index endpoints by the nodes of ready addresses", func() {
+		ep := endpoints.DeepCopy()
+		ep.Subsets = append(ep.Subsets, corev1.EndpointSubset{
+			Addresses: []corev1.EndpointAddress{
+				{
+					IP:       "192.168.0.3",
+					NodeName: &node1.Name,
+				},
+			},
+			NotReadyAddresses: []corev1.EndpointAddress{
+				{
+					IP:       "192.168.0.4",
+					NodeName: &node2.Name,
+				},
+			},
+		})
+
+		Expect(endpointsNodeNames(ep)).Should(ConsistOf(node1.Name))
+	})
+
+	It("Should index services with empty externalTrafficPolicy as Cluster", func() {
+		clone := svc.DeepCopy()
+		Expect(serviceTrafficPolicy(clone)).Should(ConsistOf(string(corev1.ServiceExternalTrafficPolicyTypeCluster)))
+
+		clone.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeLocal
+		Expect(serviceTrafficPolicy(clone)).Should(ConsistOf(string(corev1.ServiceExternalTrafficPolicyTypeLocal)))
+	})
+
+	It("Should not use excluded nodes as nexthops", func() {
+		node := node1.DeepCopy()
+		Expect(nodeEligible(node)).Should(BeTrue())
+
+		node.Spec.Unschedulable = true
+		Expect(nodeEligible(node)).Should(BeFalse())
+
+		node = node1.DeepCopy()
+		node.Labels = map[string]string{constant.KubernetesExcludeBalancersLabel: ""}
+		Expect(nodeEligible(node)).Should(BeFalse())
+
+		node.Labels = map[string]string{constant.OpenELBNodeAnnounceLabel: "false"}
+		Expect(nodeEligible(node)).Should(BeFalse())
+
+		node.Labels = map[string]string{constant.OpenELBNodeAnnounceLabel: "true"}
+		Expect(nodeEligible(node)).Should(BeTrue())
+	})
+
+	It("Should treat endpoints without ready addresses on a node as no node endpoints", func() {
+		ep := endpoints.DeepCopy()
+		ep.Subsets = []corev1.EndpointSubset{
+			{
+				NotReadyAddresses: []corev1.EndpointAddress{
+					{
+						IP:       "192.168.0.4",
+						NodeName: &node2.Name,
+					},
+				},
+			},
+		}
+		e := &EnqueueRequestForNode{Client: fake.NewFakeClient(ep)}
+		key := types.NamespacedName{Namespace: ep.Namespace, Name: ep.Name}
+		Expect(e.hasNodeEndpoints(key)).Should(BeFalse())
+
+		ep.Subsets[0].Addresses = ep.Subsets[0].NotReadyAddresses
+		e = &EnqueueRequestForNode{Client: fake.NewFakeClient(ep)}
+		Expect(e.hasNodeEndpoints(key)).Should(BeTrue())
+	})
+
+	It("Should send the resync events every period", func() {
+		stopCh := make(chan struct{})
+		defer close(stopCh)
+		r := newResync(10 * time.Millisecond)
+		go r.Start(stopCh)
+
+		Eventually(r.events).Should(Receive())
+		Eventually(r.events).Should(Receive())
+	})
+})
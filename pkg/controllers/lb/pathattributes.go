@@ -0,0 +1,89 @@
+package lb
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/openelb/openelb/api/v1alpha2"
+	"github.com/openelb/openelb/pkg/constant"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// servicePathAttributes merges the path attributes of the eip with the annotations of svc,
+// nil means the routes carry no optional attributes.
+func servicePathAttributes(eip *v1alpha2.Eip, svc *corev1.Service) (*v1alpha2.PathAttributes, error) {
+	attrs := &v1alpha2.PathAttributes{}
+	if eip.Spec.PathAttributes != nil {
+		attrs = eip.Spec.PathAttributes.DeepCopy()
+	}
+
+	annotations := svc.Annotations
+	if value, ok := annotations[constant.OpenELBCommunitiesAnnotationKey]; ok {
+		attrs.Communities = splitAnnotation(value)
+	}
+	if value, ok := annotations[constant.OpenELBLargeCommunitiesAnnotationKey]; ok {
+		attrs.LargeCommunities = splitAnnotation(value)
+	}
+	if value, ok := annotations[constant.OpenELBLocalPrefAnnotationKey]; ok {
+		localPref, err := parseUint32Annotation(constant.OpenELBLocalPrefAnnotationKey, value)
+		if err != nil {
+			return nil, err
+		}
+		attrs.LocalPref = localPref
+	}
+	if value, ok := annotations[constant.OpenELBMedAnnotationKey]; ok {
+		med, err := parseUint32Annotation(constant.OpenELBMedAnnotationKey, value)
+		if err != nil {
+			return nil, err
+		}
+		attrs.Med = med
+	}
+	if value, ok := annotations[constant.OpenELBAsPathPrependAnnotationKey]; ok {
+		prepend, err := parseUint32Annotation(constant.OpenELBAsPathPrependAnnotationKey, value)
+		if err != nil {
+			return nil, err
+		}
+		attrs.AsPathPrepend = 0
+		if prepend != nil {
+			attrs.AsPathPrepend = *prepend
+		}
+	}
+
+	if _, err := attrs.ToGoBgpPathAttributes(0); err != nil {
+		return nil, err
+	}
+	if reflect.DeepEqual(attrs, &v1alpha2.PathAttributes{}) {
+		return nil, nil
+	}
+
+	return attrs, nil
+}
+
+func splitAnnotation(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, constant.IPSeparator) {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
+// parseUint32Annotation returns nil for an empty value, which clears the attribute of the Eip.
+func parseUint32Annotation(key, value string) (*uint32, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	v, err := strconv.ParseUint(strings.TrimSpace(value), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid annotation %s: %v", key, err)
+	}
+	result := uint32(v)
+
+	return &result, nil
+}
@@ -0,0 +1,45 @@
+package lb
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// resync sends an event every period to enqueue all the OpenELB Services, so the speakers are set
+// the routes desired by the Services again. A reconcile lost, e.g. the manager crashed between
+// announcing the routes and recording it, or failed before calling the speaker, is done again,
+// and the route sync of the bgp speaker compares the global rib with those routes.
+type resync struct {
+	period time.Duration
+	events chan event.GenericEvent
+}
+
+func newResync(period time.Duration) *resync {
+	return &resync{
+		period: period,
+		events: make(chan event.GenericEvent),
+	}
+}
+
+// Start implements manager.Runnable
+func (r *resync) Start(stopCh <-chan struct{}) error {
+	ticker := time.NewTicker(r.period)
+	defer ticker.Stop()
+
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "resync"}}
+	for {
+		select {
+		case <-stopCh:
+			return nil
+		case <-ticker.C:
+			select {
+			case r.events <- event.GenericEvent{Meta: svc, Object: svc}:
+			case <-stopCh:
+				return nil
+			}
+		}
+	}
+}
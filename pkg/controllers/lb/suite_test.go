@@ -209,7 +209,10 @@ var _ = BeforeSuite(func(done Done) {
 	err = ipam.SetupIPAM(mgr)
 	Expect(err).ToNot(HaveOccurred())
 
-	err = SetupServiceReconciler(mgr)
+	err = SetupServiceReconciler(mgr, 0)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = SetupAggregateReconciler(mgr)
 	Expect(err).ToNot(HaveOccurred())
 
 	go func() {
@@ -367,6 +370,18 @@ var _ = Describe("OpenELB LoadBalancer Service", func() {
 		}), 3*time.Second).Should(Equal(true))
 	})
 
+	It("Should record the announcement of the service", func() {
+		Eventually(func() bool {
+			sa := &networkv1alpha2.ServiceAnnouncement{}
+			err := client.Client.Get(context.Background(), types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}, sa)
+			if err != nil {
+				return false
+			}
+			status, ok := sa.Status.NodesAnnouncementStatus[util.GetNodeName()]
+			return ok && status.Announced && sa.Status.Eip == eip.Name && len(status.Nodes) == 2
+		}, 3*time.Second).Should(BeTrue())
+	})
+
 	When("Endpoint is empty", func() {
 		BeforeEach(func() {
 			updateEndpoints(endpoints, func(dst *corev1.Endpoints) {
@@ -397,6 +412,15 @@ var _ = Describe("OpenELB LoadBalancer Service", func() {
 					})
 			}), 3*time.Second).Should(Equal(true))
 		})
+
+		It("the nexthops should be empty if the service withdraws without endpoints", func() {
+			updateSvc(svc, func(dst *corev1.Service) {
+				dst.Annotations[constant.OpenELBWithdrawAnnotationKey] = "true"
+			})
+			Eventually(checkSvc(svc, func(dst *corev1.Service) bool {
+				return bgpFakeSpeak.Equal(dst.Status.LoadBalancer.Ingress[0].IP, nil)
+			}), 3*time.Second).Should(Equal(true))
+		})
 	})
 
 	Context("ExternalTrafficPolicy == ServiceExternalTrafficPolicyTypeLocal", func() {
@@ -450,6 +474,62 @@ var _ = Describe("OpenELB LoadBalancer Service", func() {
 		})
 	})
 
+	When("Node is excluded from load balancers", func() {
+		BeforeEach(func() {
+			updateNode(node2, func(dst *corev1.Node) {
+				if dst.Labels == nil {
+					dst.Labels = make(map[string]string)
+				}
+				dst.Labels[constant.KubernetesExcludeBalancersLabel] = ""
+			})
+		})
+
+		AfterEach(func() {
+			updateNode(node2, func(dst *corev1.Node) {
+				delete(dst.Labels, constant.KubernetesExcludeBalancersLabel)
+			})
+		})
+
+		It("the excluded node should be withdrawn from nexthops", func() {
+			Eventually(checkSvc(svc, func(dst *corev1.Service) bool {
+				return bgpFakeSpeak.Equal(dst.Status.LoadBalancer.Ingress[0].IP,
+					[]string{
+						node1.Name,
+					})
+			}), 3*time.Second).Should(Equal(true))
+		})
+
+		It("cordoned or not announced node should be withdrawn from nexthops", func() {
+			updateNode(node1, func(dst *corev1.Node) {
+				dst.Spec.Unschedulable = true
+			})
+			Eventually(checkSvc(svc, func(dst *corev1.Service) bool {
+				return bgpFakeSpeak.Equal(dst.Status.LoadBalancer.Ingress[0].IP, nil)
+			}), 3*time.Second).Should(Equal(true))
+
+			updateNode(node1, func(dst *corev1.Node) {
+				dst.Spec.Unschedulable = false
+				if dst.Labels == nil {
+					dst.Labels = make(map[string]string)
+				}
+				dst.Labels[constant.OpenELBNodeAnnounceLabel] = "false"
+			})
+			Eventually(checkSvc(svc, func(dst *corev1.Service) bool {
+				return bgpFakeSpeak.Equal(dst.Status.LoadBalancer.Ingress[0].IP, nil)
+			}), 3*time.Second).Should(Equal(true))
+
+			updateNode(node1, func(dst *corev1.Node) {
+				delete(dst.Labels, constant.OpenELBNodeAnnounceLabel)
+			})
+			Eventually(checkSvc(svc, func(dst *corev1.Service) bool {
+				return bgpFakeSpeak.Equal(dst.Status.LoadBalancer.Ingress[0].IP,
+					[]string{
+						node1.Name,
+					})
+			}), 3*time.Second).Should(Equal(true))
+		})
+	})
+
 	When("Eip has label "+constant.OpenELBCNI, func() {
 		BeforeEach(func() {
 			updateSvc(svc, func(dst *corev1.Service) {
@@ -485,6 +565,18 @@ var _ = Describe("OpenELB LoadBalancer Service", func() {
 			}), 3*time.Second).Should(Equal(true))
 		})
 
+		It("layer2 service should stop answering ARP if it withdraws without endpoints", func() {
+			updateSvc(svc, func(dst *corev1.Service) {
+				dst.Annotations[constant.OpenELBWithdrawAnnotationKey] = "true"
+			})
+			updateEndpoints(endpoints, func(dst *corev1.Endpoints) {
+				dst.Subsets = nil
+			})
+			Eventually(checkSvc(svc, func(dst *corev1.Service) bool {
+				return layer2FakeSpeak.Equal(dst.Status.LoadBalancer.Ingress[0].IP, nil)
+			}), 3*time.Second).Should(Equal(true))
+		})
+
 		Context("ExternalTrafficPolicy == ServiceExternalTrafficPolicyTypeLocal", func() {
 			BeforeEach(func() {
 				updateSvc(svc, func(dst *corev1.Service) {
@@ -574,3 +666,18 @@ func updateEndpoints(origin *corev1.Endpoints, fn func(dst *corev1.Endpoints)) {
 		return client.Client.Update(context.Background(), clone)
 	})
 }
+
+func updateNode(origin *corev1.Node, fn func(dst *corev1.Node)) {
+	clone := origin.DeepCopy()
+
+	retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		err := client.Client.Get(context.Background(), types.NamespacedName{
+			Name: clone.Name,
+		}, clone)
+		if err != nil {
+			return err
+		}
+		fn(clone)
+		return client.Client.Update(context.Background(), clone)
+	})
+}
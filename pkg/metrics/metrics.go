@@ -95,6 +95,34 @@ var (
 			"peerIP",
 			"nodeName",
 		})
+	sessionFlapsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "session_flaps_total",
+			Help: "The number of times BGP Sessions left the established state.",
+		},
+		[]string{
+			"peerIP",
+			"nodeName",
+		})
+	routeDriftTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "route_drift_total",
+			Help: "The number of paths in the global rib corrected by the periodic route sync.",
+		},
+		[]string{
+			"nodeName",
+			"kind",
+		})
+
+	// Service controller
+	enqueuedServicesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "enqueued_services_total",
+			Help: "The number of services enqueued because of node or bgpconf events.",
+		},
+		[]string{
+			"kind",
+		})
 )
 
 func init() {
@@ -113,6 +141,11 @@ func init() {
 	metrics.Registry.MustRegister(updatesTotal)
 	metrics.Registry.MustRegister(announcedPrefixesTotal)
 	metrics.Registry.MustRegister(pendingPrefixesTotal)
+	metrics.Registry.MustRegister(sessionFlapsTotal)
+	metrics.Registry.MustRegister(routeDriftTotal)
+
+	// Service controller
+	metrics.Registry.MustRegister(enqueuedServicesTotal)
 }
 
 func UpdateEipMetrics(eipName string, total, used, svcCount float64) {
@@ -155,6 +188,7 @@ func InitBGPPeerMetrics(peerIP, node string) {
 	updatesTotal.WithLabelValues(peerIP, node).Add(0)
 	announcedPrefixesTotal.WithLabelValues(peerIP, node).Add(0)
 	pendingPrefixesTotal.WithLabelValues(peerIP, node).Add(0)
+	sessionFlapsTotal.WithLabelValues(peerIP, node).Add(0)
 }
 
 func UpdateBGPSessionMetrics(peerIP, node string, state, updateTotal float64) {
@@ -172,4 +206,20 @@ func DeleteBGPPeerMetrics(peerIP, node string) {
 	updatesTotal.DeleteLabelValues(peerIP, node)
 	announcedPrefixesTotal.DeleteLabelValues(peerIP, node)
 	pendingPrefixesTotal.DeleteLabelValues(peerIP, node)
+	sessionFlapsTotal.DeleteLabelValues(peerIP, node)
+}
+
+// UpdateBGPSessionFlapMetrics counts a BGP Session leaving the established state.
+func UpdateBGPSessionFlapMetrics(peerIP, node string) {
+	sessionFlapsTotal.WithLabelValues(peerIP, node).Inc()
+}
+
+func UpdateEnqueuedServicesMetrics(kind string, count int) {
+	enqueuedServicesTotal.WithLabelValues(kind).Add(float64(count))
+}
+
+// UpdateRouteDriftMetrics counts the missing paths added and the orphan paths withdrawn by one route sync.
+func UpdateRouteDriftMetrics(nodeName string, missing, orphan int) {
+	routeDriftTotal.WithLabelValues(nodeName, "missing").Add(float64(missing))
+	routeDriftTotal.WithLabelValues(nodeName, "orphan").Add(float64(orphan))
 }
@@ -34,3 +34,53 @@ func DeletePortForwardOfBGP(iptableExec iptables.IptablesIface, routerIP, localI
 }
 
 const BgpNatChain = "PREROUTING-OPENELB"
+
+var jumpToBgpNatChain = []string{"-j", BgpNatChain}
+
+// NewChainOfBGP creates BgpNatChain empty, dropping the rules left by a previous run, and jumps to it from PREROUTING.
+func NewChainOfBGP(iptableExec iptables.IptablesIface) error {
+	// ClearChain creates the chain if it does not exist
+	err := iptableExec.ClearChain("nat", BgpNatChain)
+	if err != nil {
+		return err
+	}
+
+	ok, err := iptableExec.Exists("nat", "PREROUTING", jumpToBgpNatChain...)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return iptableExec.Insert("nat", "PREROUTING", 1, jumpToBgpNatChain...)
+	}
+
+	return nil
+}
+
+// DeleteChainOfBGP removes the jump to BgpNatChain and the chain with its rules.
+func DeleteChainOfBGP(iptableExec iptables.IptablesIface) error {
+	ok, err := iptableExec.Exists("nat", "PREROUTING", jumpToBgpNatChain...)
+	if err != nil {
+		return err
+	}
+	if ok {
+		if err = iptableExec.Delete("nat", "PREROUTING", jumpToBgpNatChain...); err != nil {
+			return err
+		}
+	}
+
+	chains, err := iptableExec.ListChains("nat")
+	if err != nil {
+		return err
+	}
+	for _, chain := range chains {
+		if chain != BgpNatChain {
+			continue
+		}
+		if err = iptableExec.ClearChain("nat", BgpNatChain); err != nil {
+			return err
+		}
+		return iptableExec.DeleteChain("nat", BgpNatChain)
+	}
+
+	return nil
+}
@@ -4,8 +4,11 @@ import (
 	"fmt"
 
 	coreosiptables "github.com/coreos/go-iptables/iptables"
+	"github.com/openelb/openelb/pkg/nettool/nftables"
 )
 
+var _ IptablesIface = &nftables.NFTables{}
+
 // IptablesIface wrapper package coreos/iptables
 type IptablesIface interface {
 	Exists(table, chain string, rulespec ...string) (bool, error)
@@ -32,9 +35,25 @@ func (r IptablesRule) String() string {
 }
 
 func NewIPTables() IptablesIface {
-	ipt, err := coreosiptables.New()
+	ipt, err := New()
 	if err != nil {
 		panic(err)
 	}
 	return ipt
 }
+
+// New is NewIPTables returning the error, for the callers that could run without iptables.
+// The nodes without iptables get the nftables backend if they have nft.
+func New() (IptablesIface, error) {
+	ipt, err := coreosiptables.New()
+	if err == nil {
+		return ipt, nil
+	}
+
+	nft, nftErr := nftables.New()
+	if nftErr != nil {
+		return nil, fmt.Errorf("neither iptables nor nft is usable: %v, %v", err, nftErr)
+	}
+
+	return nft, nil
+}
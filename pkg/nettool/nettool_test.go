@@ -4,10 +4,40 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	. "github.com/openelb/openelb/pkg/nettool"
+	"github.com/openelb/openelb/pkg/nettool/iptables"
 )
 
 var _ = Describe("Nettool", func() {
 	It("Should generate right iptables rule", func() {
 		Expect(GenerateCretiriaAndAction("10.10.12.1", "10.10.12.2", 17900)).To(ConsistOf("-s", "10.10.12.1", "-p", "tcp", "--dport", "179", "-j", "DNAT", "--to-destination", "10.10.12.2:17900"))
 	})
+
+	It("Should manage the chain of the port forwards", func() {
+		ipt := iptables.NewFakeIPTables()
+		rule := GenerateCretiriaAndAction("10.10.12.1", "10.10.12.2", 17900)
+
+		By("A rule left by a previous run is dropped")
+		Expect(ipt.Append("nat", BgpNatChain, "-s", "10.10.12.3", "-j", "DNAT")).ShouldNot(HaveOccurred())
+		Expect(NewChainOfBGP(ipt)).ShouldNot(HaveOccurred())
+		Expect(NewChainOfBGP(ipt)).ShouldNot(HaveOccurred())
+		Expect(ipt.Data["nat"][BgpNatChain]).Should(BeEmpty())
+		Expect(ipt.Data["nat"]["PREROUTING"]).Should(HaveLen(1))
+		Expect(ipt.Exists("nat", "PREROUTING", "-j", BgpNatChain)).Should(BeTrue())
+
+		By("The rules are added once")
+		Expect(AddPortForwardOfBGP(ipt, "10.10.12.1", "10.10.12.2", 17900)).ShouldNot(HaveOccurred())
+		Expect(AddPortForwardOfBGP(ipt, "10.10.12.1", "10.10.12.2", 17900)).ShouldNot(HaveOccurred())
+		Expect(ipt.Data["nat"][BgpNatChain]).Should(HaveLen(1))
+		Expect(ipt.Exists("nat", BgpNatChain, rule...)).Should(BeTrue())
+
+		Expect(DeletePortForwardOfBGP(ipt, "10.10.12.1", "10.10.12.2", 17900)).ShouldNot(HaveOccurred())
+		Expect(ipt.Data["nat"][BgpNatChain]).Should(BeEmpty())
+
+		By("Deleting the chain")
+		Expect(AddPortForwardOfBGP(ipt, "10.10.12.1", "10.10.12.2", 17900)).ShouldNot(HaveOccurred())
+		Expect(DeleteChainOfBGP(ipt)).ShouldNot(HaveOccurred())
+		Expect(ipt.Data["nat"]).ShouldNot(HaveKey(BgpNatChain))
+		Expect(ipt.Data["nat"]["PREROUTING"]).Should(BeEmpty())
+		Expect(DeleteChainOfBGP(ipt)).ShouldNot(HaveOccurred())
+	})
 })
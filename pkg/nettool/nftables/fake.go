@@ -0,0 +1,125 @@
+package nftables
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FakeNFTables is an in-memory Conn for tests, like FakeIPTables is for iptables.
+// As nft, it refuses to delete the chains with rules or jumped to.
+type FakeNFTables struct {
+	Chains map[string]map[string]Chain
+	Data   map[string]map[string][]Rule
+	handle uint64
+}
+
+func NewFakeNFTables() *FakeNFTables {
+	return &FakeNFTables{
+		Chains: make(map[string]map[string]Chain),
+		Data:   make(map[string]map[string][]Rule),
+	}
+}
+
+func (f *FakeNFTables) exists(table, chain string) error {
+	if _, ok := f.Chains[table][chain]; !ok {
+		return fmt.Errorf("chain %s of table %s: No such file or directory", chain, table)
+	}
+
+	return nil
+}
+
+func (f *FakeNFTables) AddChain(table string, chain Chain) error {
+	if _, ok := f.Chains[table]; !ok {
+		f.Chains[table] = make(map[string]Chain)
+		f.Data[table] = make(map[string][]Rule)
+	}
+	if _, ok := f.Chains[table][chain.Name]; !ok {
+		f.Chains[table][chain.Name] = chain
+		f.Data[table][chain.Name] = make([]Rule, 0)
+	}
+
+	return nil
+}
+
+func (f *FakeNFTables) FlushChain(table, chain string) error {
+	if err := f.exists(table, chain); err != nil {
+		return err
+	}
+	f.Data[table][chain] = make([]Rule, 0)
+
+	return nil
+}
+
+func (f *FakeNFTables) DeleteChain(table, chain string) error {
+	if err := f.exists(table, chain); err != nil {
+		return err
+	}
+	if len(f.Data[table][chain]) > 0 {
+		return fmt.Errorf("chain %s of table %s: Device or resource busy", chain, table)
+	}
+	for _, rules := range f.Data[table] {
+		for _, rule := range rules {
+			if strings.HasSuffix(" "+rule.Expr, " jump "+chain) {
+				return fmt.Errorf("chain %s of table %s: Device or resource busy", chain, table)
+			}
+		}
+	}
+
+	delete(f.Chains[table], chain)
+	delete(f.Data[table], chain)
+	return nil
+}
+
+func (f *FakeNFTables) ListChains(table string) ([]Chain, error) {
+	result := make([]Chain, 0, len(f.Chains[table]))
+	for _, chain := range f.Chains[table] {
+		result = append(result, chain)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+func (f *FakeNFTables) AddRule(table, chain string, rule Rule, before uint64) error {
+	if err := f.exists(table, chain); err != nil {
+		return err
+	}
+
+	f.handle++
+	rule.Handle = f.handle
+	rules := f.Data[table][chain]
+	if before == 0 {
+		f.Data[table][chain] = append(rules, rule)
+		return nil
+	}
+
+	for index := range rules {
+		if rules[index].Handle == before {
+			result := append([]Rule{}, rules[:index]...)
+			result = append(result, rule)
+			f.Data[table][chain] = append(result, rules[index:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("rule with handle %d of chain %s: No such file or directory", before, chain)
+}
+
+func (f *FakeNFTables) DeleteRule(table, chain string, handle uint64) error {
+	rules := f.Data[table][chain]
+	for index := range rules {
+		if rules[index].Handle == handle {
+			f.Data[table][chain] = append(rules[:index:index], rules[index+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("rule with handle %d of chain %s: No such file or directory", handle, chain)
+}
+
+func (f *FakeNFTables) ListRules(table, chain string) ([]Rule, error) {
+	return append([]Rule{}, f.Data[table][chain]...), nil
+}
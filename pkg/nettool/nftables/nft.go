@@ -0,0 +1,150 @@
+package nftables
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// execConn is the Conn running the nft command on the tables of the ip family.
+type execConn struct {
+	path string
+}
+
+// New returns the nftables backend running nft, an error if nft is not installed.
+func New() (*NFTables, error) {
+	path, err := exec.LookPath("nft")
+	if err != nil {
+		return nil, err
+	}
+
+	return NewNFTables(&execConn{path: path}), nil
+}
+
+func (c *execConn) run(stdin string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(c.path, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("nft %s failed: %v, %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// script runs the commands in one transaction.
+func (c *execConn) script(commands ...string) error {
+	_, err := c.run(strings.Join(commands, "\n")+"\n", "-f", "-")
+	return err
+}
+
+func (c *execConn) AddChain(table string, chain Chain) error {
+	add := fmt.Sprintf("add chain ip %s %s", table, chain.Name)
+	if chain.Hook != "" {
+		add += fmt.Sprintf(" { type %s hook %s priority %d ; }", chain.Type, chain.Hook, chain.Priority)
+	}
+
+	return c.script("add table ip "+table, add)
+}
+
+func (c *execConn) FlushChain(table, chain string) error {
+	return c.script(fmt.Sprintf("flush chain ip %s %s", table, chain))
+}
+
+func (c *execConn) DeleteChain(table, chain string) error {
+	return c.script(fmt.Sprintf("delete chain ip %s %s", table, chain))
+}
+
+func (c *execConn) AddRule(table, chain string, rule Rule, before uint64) error {
+	if before == 0 {
+		return c.script(fmt.Sprintf("add rule ip %s %s %s comment %q", table, chain, rule.Expr, rule.Comment))
+	}
+
+	return c.script(fmt.Sprintf("insert rule ip %s %s position %d %s comment %q", table, chain, before, rule.Expr, rule.Comment))
+}
+
+func (c *execConn) DeleteRule(table, chain string, handle uint64) error {
+	return c.script(fmt.Sprintf("delete rule ip %s %s handle %d", table, chain, handle))
+}
+
+// listing is the output of nft -j list table, only the fields read are decoded.
+type listing struct {
+	Nftables []struct {
+		Chain *struct {
+			Name string `json:"name"`
+			Type string `json:"type"`
+			Hook string `json:"hook"`
+			Prio int    `json:"prio"`
+		} `json:"chain"`
+		Rule *struct {
+			Chain   string `json:"chain"`
+			Handle  uint64 `json:"handle"`
+			Comment string `json:"comment"`
+		} `json:"rule"`
+	} `json:"nftables"`
+}
+
+// list returns the listing of the table, empty if the table does not exist.
+func (c *execConn) list(table string) (*listing, error) {
+	out, err := c.run("", "-j", "list", "table", "ip", table)
+	if err != nil {
+		if strings.Contains(err.Error(), "No such file or directory") {
+			return &listing{}, nil
+		}
+		return nil, err
+	}
+
+	result := &listing{}
+	if err = json.Unmarshal(out, result); err != nil {
+		return nil, fmt.Errorf("failed to parse the rules of table %s, %v", table, err)
+	}
+
+	return result, nil
+}
+
+func (c *execConn) ListChains(table string) ([]Chain, error) {
+	l, err := c.list(table)
+	if err != nil {
+		return nil, err
+	}
+
+	var chains []Chain
+	for _, object := range l.Nftables {
+		if object.Chain == nil {
+			continue
+		}
+		chains = append(chains, Chain{
+			Name:     object.Chain.Name,
+			Type:     object.Chain.Type,
+			Hook:     object.Chain.Hook,
+			Priority: object.Chain.Prio,
+		})
+	}
+
+	return chains, nil
+}
+
+// ListRules returns the rules of the chain without their expressions, nft lists them in its own form.
+func (c *execConn) ListRules(table, chain string) ([]Rule, error) {
+	l, err := c.list(table)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []Rule
+	for _, object := range l.Nftables {
+		if object.Rule == nil || object.Rule.Chain != chain {
+			continue
+		}
+		rules = append(rules, Rule{
+			Handle:  object.Rule.Handle,
+			Comment: object.Rule.Comment,
+		})
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,262 @@
+package nftables
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// tablePrefix prefixes the nftables tables holding the rules of the iptables tables, so that they
+// are apart from the tables of iptables-nft and the other components.
+const tablePrefix = "openelb_"
+
+// maxCommentLen is the longest comment nft takes
+const maxCommentLen = 128
+
+// validName matches the chain names nft takes unquoted
+var validName = regexp.MustCompile(`^[a-zA-Z_.][a-zA-Z0-9/_.-]*$`)
+
+// Chain is a chain of a nftables table, Type, Hook and Priority are only set for the base chains.
+type Chain struct {
+	Name     string
+	Type     string
+	Hook     string
+	Priority int
+}
+
+// Rule is a rule of a nftables chain. Comment holds the iptables rulespec the rule is translated from,
+// the rules are looked up by it as nft lists Expr in its own form.
+type Rule struct {
+	Handle  uint64
+	Expr    string
+	Comment string
+}
+
+// Conn is the part of nftables the backend needs, the tables are created when their first chain is.
+type Conn interface {
+	// AddChain creates the chain and its table, nothing is done if it exists.
+	AddChain(table string, chain Chain) error
+	FlushChain(table, chain string) error
+	DeleteChain(table, chain string) error
+	// ListChains returns no chains for a missing table.
+	ListChains(table string) ([]Chain, error)
+	// AddRule inserts rule before the rule with the handle before, 0 appends it.
+	AddRule(table, chain string, rule Rule, before uint64) error
+	DeleteRule(table, chain string, handle uint64) error
+	// ListRules returns no rules for a missing chain.
+	ListRules(table, chain string) ([]Rule, error)
+}
+
+// baseChains are the chains of the iptables tables hooked into netfilter.
+var baseChains = map[string]map[string]Chain{
+	"nat": {
+		"PREROUTING":  {Type: "nat", Hook: "prerouting", Priority: -100},
+		"INPUT":       {Type: "nat", Hook: "input", Priority: 100},
+		"OUTPUT":      {Type: "nat", Hook: "output", Priority: -100},
+		"POSTROUTING": {Type: "nat", Hook: "postrouting", Priority: 100},
+	},
+	"filter": {
+		"INPUT":   {Type: "filter", Hook: "input", Priority: 0},
+		"FORWARD": {Type: "filter", Hook: "forward", Priority: 0},
+		"OUTPUT":  {Type: "filter", Hook: "output", Priority: 0},
+	},
+	"mangle": {
+		"PREROUTING":  {Type: "filter", Hook: "prerouting", Priority: -150},
+		"INPUT":       {Type: "filter", Hook: "input", Priority: -150},
+		"FORWARD":     {Type: "filter", Hook: "forward", Priority: -150},
+		"OUTPUT":      {Type: "route", Hook: "output", Priority: -150},
+		"POSTROUTING": {Type: "filter", Hook: "postrouting", Priority: -150},
+	},
+}
+
+// NFTables implements iptables.IptablesIface with nftables, for the nodes without iptables.
+// The rules of an iptables table are kept in the IPv4 nftables table prefixed with openelb_.
+type NFTables struct {
+	conn Conn
+}
+
+func NewNFTables(conn Conn) *NFTables {
+	return &NFTables{conn: conn}
+}
+
+func toTable(table string) (string, error) {
+	if _, ok := baseChains[table]; !ok {
+		return "", fmt.Errorf("table %s not supported", table)
+	}
+
+	return tablePrefix + table, nil
+}
+
+// ensureChain creates the chain, a base chain if iptables has it built in.
+func (n *NFTables) ensureChain(table, chain string) (string, error) {
+	t, err := toTable(table)
+	if err != nil {
+		return "", err
+	}
+	if !validName.MatchString(chain) {
+		return "", fmt.Errorf("invalid chain name %q", chain)
+	}
+
+	c, ok := baseChains[table][chain]
+	if !ok {
+		c = Chain{}
+	}
+	c.Name = chain
+
+	return t, n.conn.AddChain(t, c)
+}
+
+func comment(rulespec []string) string {
+	return strings.Join(rulespec, " ")
+}
+
+// find returns the rule translated from rulespec, nil if not found.
+func (n *NFTables) find(table, chain string, rulespec []string) (*Rule, error) {
+	rules, err := n.conn.ListRules(table, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	c := comment(rulespec)
+	for i := range rules {
+		if rules[i].Comment == c {
+			return &rules[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (n *NFTables) Exists(table, chain string, rulespec ...string) (bool, error) {
+	t, err := toTable(table)
+	if err != nil {
+		return false, err
+	}
+
+	rule, err := n.find(t, chain, rulespec)
+	return rule != nil, err
+}
+
+func (n *NFTables) add(table, chain string, pos int, rulespec []string) error {
+	expr, err := translate(rulespec)
+	if err != nil {
+		return err
+	}
+	c := comment(rulespec)
+	if len(c) > maxCommentLen || strings.Contains(c, `"`) {
+		return fmt.Errorf("rule %q could not be kept in a comment of nft", c)
+	}
+	t, err := n.ensureChain(table, chain)
+	if err != nil {
+		return err
+	}
+
+	var before uint64
+	if pos > 0 {
+		rules, err := n.conn.ListRules(t, chain)
+		if err != nil {
+			return err
+		}
+		if pos <= len(rules) {
+			before = rules[pos-1].Handle
+		}
+	}
+
+	return n.conn.AddRule(t, chain, Rule{Expr: expr, Comment: c}, before)
+}
+
+// Insert inserts the rule at pos, starting from 1 as in iptables.
+func (n *NFTables) Insert(table, chain string, pos int, rulespec ...string) error {
+	if pos < 1 {
+		return fmt.Errorf("invalid rule position %d", pos)
+	}
+
+	return n.add(table, chain, pos, rulespec)
+}
+
+func (n *NFTables) Append(table, chain string, rulespec ...string) error {
+	return n.add(table, chain, 0, rulespec)
+}
+
+func (n *NFTables) Delete(table, chain string, rulespec ...string) error {
+	t, err := toTable(table)
+	if err != nil {
+		return err
+	}
+
+	rule, err := n.find(t, chain, rulespec)
+	if err != nil {
+		return err
+	}
+	if rule == nil {
+		return fmt.Errorf("rule %q does not exist in chain %s", comment(rulespec), chain)
+	}
+
+	return n.conn.DeleteRule(t, chain, rule.Handle)
+}
+
+// List returns the rules of the chain in the form of iptables -S.
+func (n *NFTables) List(table, chain string) ([]string, error) {
+	t, err := toTable(table)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := n.conn.ListRules(t, chain)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		result = append(result, fmt.Sprintf("-A %s %s", chain, rule.Comment))
+	}
+
+	return result, nil
+}
+
+func (n *NFTables) NewChain(table, chain string) error {
+	_, err := n.ensureChain(table, chain)
+	return err
+}
+
+// ClearChain flushes the chain, it is created if it does not exist.
+func (n *NFTables) ClearChain(table, chain string) error {
+	t, err := n.ensureChain(table, chain)
+	if err != nil {
+		return err
+	}
+
+	return n.conn.FlushChain(t, chain)
+}
+
+func (n *NFTables) DeleteChain(table, chain string) error {
+	t, err := toTable(table)
+	if err != nil {
+		return err
+	}
+
+	return n.conn.DeleteChain(t, chain)
+}
+
+func (n *NFTables) ListChains(table string) ([]string, error) {
+	t, err := toTable(table)
+	if err != nil {
+		return nil, err
+	}
+
+	chains, err := n.conn.ListChains(t)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(chains))
+	for _, chain := range chains {
+		result = append(result, chain.Name)
+	}
+
+	return result, nil
+}
+
+// HasRandomFully is always true, nft has fully-random since 0.9.0 and older releases are not supported.
+func (n *NFTables) HasRandomFully() bool {
+	return true
+}
@@ -0,0 +1,13 @@
+package nftables_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestNftables(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Nftables Suite")
+}
@@ -0,0 +1,93 @@
+package nftables_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/openelb/openelb/pkg/nettool"
+	. "github.com/openelb/openelb/pkg/nettool/nftables"
+)
+
+var _ = Describe("Nftables", func() {
+	var (
+		fake *FakeNFTables
+		nft  *NFTables
+	)
+
+	BeforeEach(func() {
+		fake = NewFakeNFTables()
+		nft = NewNFTables(fake)
+	})
+
+	exprOf := func(rulespec ...string) (string, error) {
+		if err := nft.Append("filter", "TEST", rulespec...); err != nil {
+			return "", err
+		}
+		rules := fake.Data["openelb_filter"]["TEST"]
+		return rules[len(rules)-1].Expr, nil
+	}
+
+	It("Should translate the rules", func() {
+		Expect(exprOf(nettool.GenerateCretiriaAndAction("10.10.12.1", "10.10.12.2", 17900)...)).
+			Should(Equal("ip saddr 10.10.12.1 tcp dport 179 dnat to 10.10.12.2:17900"))
+		Expect(exprOf("!", "-s", "10.10.0.0/16", "-o", "eth0", "-j", "MASQUERADE", "--random-fully")).
+			Should(Equal(`ip saddr != 10.10.0.0/16 oifname "eth0" masquerade fully-random`))
+		Expect(exprOf("-p", "udp", "-i", "eth1", "-j", "ACCEPT")).
+			Should(Equal(`meta l4proto udp iifname "eth1" accept`))
+		Expect(exprOf("-d", "10.10.12.3", "-j", nettool.BgpNatChain)).
+			Should(Equal("ip daddr 10.10.12.3 jump " + nettool.BgpNatChain))
+
+		_, err := exprOf("-m", "conntrack", "--ctstate", "NEW")
+		Expect(err).Should(HaveOccurred())
+		_, err = exprOf("--dport", "179", "-j", "ACCEPT")
+		Expect(err).Should(HaveOccurred())
+		_, err = exprOf("-j", "DNAT")
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("Should look up the rules by their rulespec", func() {
+		Expect(nft.Append("nat", "TEST", "-s", "10.10.12.1", "-j", "ACCEPT")).ShouldNot(HaveOccurred())
+		Expect(nft.Append("nat", "TEST", "-s", "10.10.12.2", "-j", "ACCEPT")).ShouldNot(HaveOccurred())
+		Expect(nft.Insert("nat", "TEST", 2, "-s", "10.10.12.3", "-j", "ACCEPT")).ShouldNot(HaveOccurred())
+		Expect(nft.List("nat", "TEST")).Should(Equal([]string{
+			"-A TEST -s 10.10.12.1 -j ACCEPT",
+			"-A TEST -s 10.10.12.3 -j ACCEPT",
+			"-A TEST -s 10.10.12.2 -j ACCEPT",
+		}))
+
+		Expect(nft.Exists("nat", "TEST", "-s", "10.10.12.3", "-j", "ACCEPT")).Should(BeTrue())
+		Expect(nft.Delete("nat", "TEST", "-s", "10.10.12.3", "-j", "ACCEPT")).ShouldNot(HaveOccurred())
+		Expect(nft.Exists("nat", "TEST", "-s", "10.10.12.3", "-j", "ACCEPT")).Should(BeFalse())
+		Expect(nft.Delete("nat", "TEST", "-s", "10.10.12.3", "-j", "ACCEPT")).Should(HaveOccurred())
+
+		By("Looking up a missing chain")
+		Expect(nft.Exists("nat", "MISSING", "-j", "ACCEPT")).Should(BeFalse())
+		Expect(fake.Chains["openelb_nat"]).ShouldNot(HaveKey("MISSING"))
+	})
+
+	It("Should run the port forwards of bgp", func() {
+		Expect(nettool.NewChainOfBGP(nft)).ShouldNot(HaveOccurred())
+		Expect(fake.Chains["openelb_nat"]["PREROUTING"]).Should(Equal(Chain{
+			Name:     "PREROUTING",
+			Type:     "nat",
+			Hook:     "prerouting",
+			Priority: -100,
+		}))
+		Expect(fake.Data["openelb_nat"]["PREROUTING"]).Should(HaveLen(1))
+
+		Expect(nettool.AddPortForwardOfBGP(nft, "10.10.12.1", "10.10.12.2", 17900)).ShouldNot(HaveOccurred())
+		Expect(nettool.AddPortForwardOfBGP(nft, "10.10.12.1", "10.10.12.2", 17900)).ShouldNot(HaveOccurred())
+		Expect(fake.Data["openelb_nat"][nettool.BgpNatChain]).Should(HaveLen(1))
+
+		By("The chain in use could not be deleted")
+		Expect(nft.DeleteChain("nat", nettool.BgpNatChain)).Should(HaveOccurred())
+
+		Expect(nettool.DeleteChainOfBGP(nft)).ShouldNot(HaveOccurred())
+		Expect(fake.Chains["openelb_nat"]).ShouldNot(HaveKey(nettool.BgpNatChain))
+		Expect(fake.Data["openelb_nat"]["PREROUTING"]).Should(BeEmpty())
+	})
+
+	It("Should refuse the tables and chains nft could not take", func() {
+		Expect(nft.NewChain("raw", "TEST")).Should(HaveOccurred())
+		Expect(nft.NewChain("nat", "TEST CHAIN")).Should(HaveOccurred())
+	})
+})
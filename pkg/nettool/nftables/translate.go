@@ -0,0 +1,123 @@
+package nftables
+
+import (
+	"fmt"
+	"strings"
+)
+
+// translate returns the nft expression of an iptables rulespec. Only the matches and targets
+// of the rules OpenELB adds are supported, the others are refused.
+func translate(rulespec []string) (string, error) {
+	var (
+		exprs     []string
+		proto     string
+		protoExpr = -1
+		hasPort   bool
+		target    string
+		toDest    string
+		toSource  string
+		random    bool
+		negate    bool
+	)
+
+	for i := 0; i < len(rulespec); i++ {
+		opt := rulespec[i]
+		if opt == "!" {
+			negate = true
+			continue
+		}
+		if opt == "--random-fully" {
+			random = true
+			continue
+		}
+
+		if i+1 >= len(rulespec) {
+			return "", fmt.Errorf("option %s has no value", opt)
+		}
+		i++
+		value := rulespec[i]
+
+		op := ""
+		if negate {
+			op = "!= "
+			switch opt {
+			case "-s", "--source", "-d", "--destination", "-i", "--in-interface", "-o", "--out-interface":
+			default:
+				return "", fmt.Errorf("option %s could not be negated", opt)
+			}
+			negate = false
+		}
+
+		switch opt {
+		case "-s", "--source":
+			exprs = append(exprs, "ip saddr "+op+value)
+		case "-d", "--destination":
+			exprs = append(exprs, "ip daddr "+op+value)
+		case "-i", "--in-interface":
+			exprs = append(exprs, fmt.Sprintf("iifname %s%q", op, value))
+		case "-o", "--out-interface":
+			exprs = append(exprs, fmt.Sprintf("oifname %s%q", op, value))
+		case "-p", "--protocol":
+			proto = value
+			protoExpr = len(exprs)
+			exprs = append(exprs, "meta l4proto "+value)
+		case "--dport", "--destination-port", "--sport", "--source-port":
+			if proto != "tcp" && proto != "udp" {
+				return "", fmt.Errorf("option %s needs protocol tcp or udp", opt)
+			}
+			field := "dport"
+			if opt == "--sport" || opt == "--source-port" {
+				field = "sport"
+			}
+			exprs = append(exprs, fmt.Sprintf("%s %s %s", proto, field, value))
+			hasPort = true
+		case "-m", "--match":
+			// The matches of the protocols are implied by the ports
+			if value != "tcp" && value != "udp" {
+				return "", fmt.Errorf("match %s not supported", value)
+			}
+		case "-j", "--jump":
+			target = value
+		case "--to-destination":
+			toDest = value
+		case "--to-source":
+			toSource = value
+		default:
+			return "", fmt.Errorf("option %s not supported", opt)
+		}
+	}
+
+	// The port matches imply the protocol
+	if hasPort {
+		exprs = append(exprs[:protoExpr], exprs[protoExpr+1:]...)
+	}
+
+	switch target {
+	case "":
+	case "ACCEPT", "DROP", "RETURN":
+		exprs = append(exprs, strings.ToLower(target))
+	case "DNAT":
+		if toDest == "" {
+			return "", fmt.Errorf("target DNAT needs --to-destination")
+		}
+		exprs = append(exprs, "dnat to "+toDest)
+	case "SNAT":
+		if toSource == "" {
+			return "", fmt.Errorf("target SNAT needs --to-source")
+		}
+		exprs = append(exprs, "snat to "+toSource)
+	case "MASQUERADE":
+		if random {
+			exprs = append(exprs, "masquerade fully-random")
+		} else {
+			exprs = append(exprs, "masquerade")
+		}
+	default:
+		if !validName.MatchString(target) {
+			return "", fmt.Errorf("target %s not supported", target)
+		}
+		exprs = append(exprs, "jump "+target)
+	}
+
+	return strings.Join(exprs, " "), nil
+}
@@ -0,0 +1,206 @@
+package bfd
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/ipv4"
+)
+
+// transitions records the state changes reported by a session.
+type transitions struct {
+	lock   sync.Mutex
+	states []State
+}
+
+func (t *transitions) onChange(_, new State) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.states = append(t.states, new)
+}
+
+func (t *transitions) get() []State {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return append([]State(nil), t.states...)
+}
+
+var _ = Describe("BFD", func() {
+	Context("Control packet", func() {
+		It("Should encode and decode", func() {
+			p := &controlPacket{
+				diag:                  diagNeighborDown,
+				state:                 StateUp,
+				poll:                  true,
+				detectMult:            3,
+				myDiscriminator:       1,
+				yourDiscriminator:     2,
+				desiredMinTxInterval:  300000,
+				requiredMinRxInterval: 300000,
+			}
+			b := p.marshal()
+			Expect(b).Should(HaveLen(controlPacketLen))
+
+			result, err := parseControlPacket(b)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result).Should(Equal(p))
+		})
+
+		It("Should reject invalid packets", func() {
+			p := &controlPacket{state: StateUp, detectMult: 3, myDiscriminator: 1}
+			_, err := parseControlPacket(p.marshal())
+			Expect(err).Should(HaveOccurred())
+
+			p.yourDiscriminator = 2
+			b := p.marshal()
+			b[0] = 2 << 5
+			_, err = parseControlPacket(b)
+			Expect(err).Should(HaveOccurred())
+
+			_, err = parseControlPacket(b[:20])
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("Sessions over loopback", func() {
+		var (
+			a, b   *Manager
+			stopCh chan struct{}
+			config = Config{
+				DesiredMinTxInterval:  50 * time.Millisecond,
+				RequiredMinRxInterval: 50 * time.Millisecond,
+				DetectMultiplier:      3,
+			}
+		)
+
+		BeforeEach(func() {
+			a = NewManager(23784, 23785)
+			b = NewManager(23785, 23784)
+			stopCh = make(chan struct{})
+			Expect(a.Start(stopCh)).ShouldNot(HaveOccurred())
+			Expect(b.Start(stopCh)).ShouldNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			close(stopCh)
+			// Wait for the listeners to be closed
+			time.Sleep(100 * time.Millisecond)
+		})
+
+		state := func(m *Manager) func() State {
+			return func() State {
+				s, _ := m.State("127.0.0.1")
+				return s
+			}
+		}
+
+		It("Should detect the failure of the peer", func() {
+			ta := &transitions{}
+			Expect(a.AddSession("127.0.0.1", config, ta.onChange)).ShouldNot(HaveOccurred())
+			Expect(b.AddSession("127.0.0.1", config, nil)).ShouldNot(HaveOccurred())
+
+			Eventually(state(a), 5*time.Second).Should(Equal(StateUp))
+			Eventually(state(b), 5*time.Second).Should(Equal(StateUp))
+
+			By("Stop the peer without telling")
+			b.lock.Lock()
+			s := b.sessions["127.0.0.1"]
+			delete(b.sessions, "127.0.0.1")
+			delete(b.discrs, s.localDiscr)
+			b.lock.Unlock()
+			s.stop(false)
+
+			down := time.Now()
+			Eventually(state(a), time.Second, 10*time.Millisecond).Should(Equal(StateDown))
+			Expect(time.Since(down)).Should(BeNumerically("<", 500*time.Millisecond))
+			Eventually(func() []State {
+				states := ta.get()
+				if len(states) < 2 {
+					return states
+				}
+				return states[len(states)-2:]
+			}).Should(Equal([]State{StateUp, StateDown}))
+		})
+
+		It("Should go down when the peer deletes the session", func() {
+			Expect(a.AddSession("127.0.0.1", config, nil)).ShouldNot(HaveOccurred())
+			Expect(b.AddSession("127.0.0.1", config, nil)).ShouldNot(HaveOccurred())
+			Eventually(state(a), 5*time.Second).Should(Equal(StateUp))
+
+			b.DeleteSession("127.0.0.1")
+			_, ok := b.State("127.0.0.1")
+			Expect(ok).Should(BeFalse())
+			Eventually(state(a), 100*time.Millisecond, 10*time.Millisecond).Should(Equal(StateDown))
+			a.lock.Lock()
+			s := a.sessions["127.0.0.1"]
+			a.lock.Unlock()
+			s.lock.Lock()
+			defer s.lock.Unlock()
+			Expect(s.diag).Should(Equal(diagNeighborDown))
+		})
+
+		It("Should drop the packets received with a TTL less than 255", func() {
+			Expect(a.AddSession("127.0.0.1", config, nil)).ShouldNot(HaveOccurred())
+
+			conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 23784})
+			Expect(err).ShouldNot(HaveOccurred())
+			defer conn.Close()
+			p := &controlPacket{
+				state:                 StateDown,
+				detectMult:            3,
+				myDiscriminator:       1,
+				desiredMinTxInterval:  50000,
+				requiredMinRxInterval: 50000,
+			}
+
+			By("A packet forwarded by a router")
+			Expect(ipv4.NewConn(conn).SetTTL(254)).ShouldNot(HaveOccurred())
+			_, err = conn.Write(p.marshal())
+			Expect(err).ShouldNot(HaveOccurred())
+			Consistently(state(a), 200*time.Millisecond).Should(Equal(StateDown))
+
+			By("A packet from the directly connected peer")
+			Expect(ipv4.NewConn(conn).SetTTL(255)).ShouldNot(HaveOccurred())
+			_, err = conn.Write(p.marshal())
+			Expect(err).ShouldNot(HaveOccurred())
+			Eventually(state(a), time.Second).Should(Equal(StateInit))
+		})
+
+		It("Should drop the packets with the discriminator of a session of another peer", func() {
+			Expect(a.AddSession("127.0.0.1", config, nil)).ShouldNot(HaveOccurred())
+			a.lock.Lock()
+			discr := a.sessions["127.0.0.1"].localDiscr
+			a.lock.Unlock()
+			p := &controlPacket{
+				state:                 StateDown,
+				detectMult:            3,
+				myDiscriminator:       1,
+				yourDiscriminator:     discr,
+				desiredMinTxInterval:  50000,
+				requiredMinRxInterval: 50000,
+			}
+			send := func(source string) {
+				conn, err := net.DialUDP("udp4", &net.UDPAddr{IP: net.ParseIP(source)},
+					&net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 23784})
+				Expect(err).ShouldNot(HaveOccurred())
+				defer conn.Close()
+				Expect(ipv4.NewConn(conn).SetTTL(255)).ShouldNot(HaveOccurred())
+				_, err = conn.Write(p.marshal())
+				Expect(err).ShouldNot(HaveOccurred())
+			}
+
+			By("A packet from another address")
+			send("127.0.0.2")
+			Consistently(state(a), 200*time.Millisecond).Should(Equal(StateDown))
+
+			By("A packet from the peer")
+			send("127.0.0.1")
+			Eventually(state(a), time.Second).Should(Equal(StateInit))
+		})
+	})
+})
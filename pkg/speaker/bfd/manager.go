@@ -0,0 +1,251 @@
+package bfd
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Manager runs the single hop BFD sessions of the speaker, RFC 5881.
+// Received packets are dispatched by Your Discriminator, or by the source address
+// before the peer learned our discriminator, and dropped if their source is not the peer.
+type Manager struct {
+	listenPort int
+	peerPort   int
+	log        logr.Logger
+
+	lock     sync.Mutex
+	sessions map[string]*session
+	discrs   map[uint32]*session
+}
+
+// NewManager receives the control packets on listenPort and sends them to peerPort of the peers,
+// both are DefaultPort except in tests.
+func NewManager(listenPort, peerPort int) *Manager {
+	return &Manager{
+		listenPort: listenPort,
+		peerPort:   peerPort,
+		log:        ctrl.Log.WithName("bfd"),
+		sessions:   make(map[string]*session),
+		discrs:     make(map[uint32]*session),
+	}
+}
+
+func (m *Manager) Start(stopCh <-chan struct{}) error {
+	conn4, err := m.listen("udp4")
+	if err != nil {
+		return err
+	}
+	conns := []*net.UDPConn{conn4}
+	// The host may have no v6 at all
+	if conn6, err := m.listen("udp6"); err != nil {
+		m.log.Info("bfd over ipv6 disabled", "err", err.Error())
+	} else {
+		conns = append(conns, conn6)
+	}
+
+	go func() {
+		<-stopCh
+		for _, conn := range conns {
+			conn.Close()
+		}
+
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		for peer, s := range m.sessions {
+			s.stop(true)
+			delete(m.sessions, peer)
+			delete(m.discrs, s.localDiscr)
+		}
+	}()
+
+	return nil
+}
+
+// listen receives the control packets of network, udp4 or udp6, with their TTL or hop limit.
+func (m *Manager) listen(network string) (*net.UDPConn, error) {
+	conn, err := net.ListenUDP(network, &net.UDPAddr{Port: m.listenPort})
+	if err != nil {
+		return nil, err
+	}
+
+	var read func(buf []byte) (int, net.IP, int, error)
+	if network == "udp4" {
+		p := ipv4.NewPacketConn(conn)
+		err = p.SetControlMessage(ipv4.FlagTTL, true)
+		read = func(buf []byte) (int, net.IP, int, error) {
+			n, cm, addr, err := p.ReadFrom(buf)
+			if err != nil || cm == nil {
+				return n, nil, 0, err
+			}
+			return n, addr.(*net.UDPAddr).IP, cm.TTL, nil
+		}
+	} else {
+		p := ipv6.NewPacketConn(conn)
+		err = p.SetControlMessage(ipv6.FlagHopLimit, true)
+		read = func(buf []byte) (int, net.IP, int, error) {
+			n, cm, addr, err := p.ReadFrom(buf)
+			if err != nil || cm == nil {
+				return n, nil, 0, err
+			}
+			return n, addr.(*net.UDPAddr).IP, cm.HopLimit, nil
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	go m.receive(read)
+	return conn, nil
+}
+
+// receive dispatches the control packets read by read, which returns the source
+// address and the TTL or hop limit of each packet.
+func (m *Manager) receive(read func(buf []byte) (n int, ip net.IP, ttl int, err error)) {
+	buf := make([]byte, 1500)
+	for {
+		n, ip, ttl, err := read(buf)
+		if err != nil {
+			m.log.Info("stop receiving bfd control packets", "err", err.Error())
+			return
+		}
+		if ip == nil {
+			continue
+		}
+		// RFC 5881 5, a single hop packet is sent with TTL 255, so any less was forwarded
+		if ttl != 255 {
+			m.log.V(1).Info("drop bfd control packet", "peer", ip.String(), "ttl", ttl)
+			continue
+		}
+
+		p, err := parseControlPacket(buf[:n])
+		if err != nil {
+			m.log.V(1).Info("drop bfd control packet", "peer", ip.String(), "err", err.Error())
+			continue
+		}
+
+		m.lock.Lock()
+		s := m.sessions[ip.String()]
+		if p.yourDiscriminator != 0 {
+			s = m.discrs[p.yourDiscriminator]
+		}
+		m.lock.Unlock()
+		if s == nil {
+			continue
+		}
+		// Your Discriminator is easily guessed, only the peer of the session may act on it
+		if !s.peer.IP.Equal(ip) {
+			m.log.V(1).Info("drop bfd control packet", "peer", ip.String(), "session", s.peer.IP.String())
+			continue
+		}
+
+		s.receive(p)
+	}
+}
+
+// AddSession starts a session with peer, or updates its timers if there is one.
+// onChange is called on every state change of the session.
+func (m *Manager) AddSession(peer string, config Config, onChange func(old, new State)) error {
+	ip := net.ParseIP(peer)
+	if ip == nil {
+		return fmt.Errorf("invalid bfd peer %s", peer)
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if s, ok := m.sessions[ip.String()]; ok {
+		s.update(config)
+		return nil
+	}
+
+	conn, err := listenSourcePort(ip)
+	if err != nil {
+		return err
+	}
+
+	discr := rand.Uint32()
+	for _, ok := m.discrs[discr]; discr == 0 || ok; _, ok = m.discrs[discr] {
+		discr = rand.Uint32()
+	}
+
+	s := newSession(&net.UDPAddr{IP: ip, Port: m.peerPort}, conn, discr, config, onChange,
+		m.log.WithValues("peer", ip.String()))
+	m.sessions[ip.String()] = s
+	m.discrs[discr] = s
+	go s.run()
+
+	return nil
+}
+
+// DeleteSession tells peer that the session is administratively down and stops it.
+func (m *Manager) DeleteSession(peer string) {
+	ip := net.ParseIP(peer)
+	if ip == nil {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	s, ok := m.sessions[ip.String()]
+	if !ok {
+		return
+	}
+	delete(m.sessions, ip.String())
+	delete(m.discrs, s.localDiscr)
+	s.stop(true)
+}
+
+// State returns the state of the session with peer, false if there is none.
+func (m *Manager) State(peer string) (State, bool) {
+	ip := net.ParseIP(peer)
+	if ip == nil {
+		return StateAdminDown, false
+	}
+
+	m.lock.Lock()
+	s, ok := m.sessions[ip.String()]
+	m.lock.Unlock()
+	if !ok {
+		return StateAdminDown, false
+	}
+
+	return s.getState(), true
+}
+
+// listenSourcePort binds a source port in the range of RFC 5881 4, sending with TTL 255.
+func listenSourcePort(ip net.IP) (*net.UDPConn, error) {
+	network := "udp4"
+	if ip.To4() == nil {
+		network = "udp6"
+	}
+
+	for i := 0; i < 100; i++ {
+		conn, err := net.ListenUDP(network, &net.UDPAddr{Port: 49152 + rand.Intn(16384)})
+		if err != nil {
+			continue
+		}
+
+		if network == "udp4" {
+			err = ipv4.NewConn(conn).SetTTL(255)
+		} else {
+			err = ipv6.NewConn(conn).SetHopLimit(255)
+		}
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		return conn, nil
+	}
+
+	return nil, fmt.Errorf("no free source port for bfd")
+}
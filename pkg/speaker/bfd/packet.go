@@ -0,0 +1,127 @@
+package bfd
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// State is the session state of RFC 5880 4.1.
+type State uint8
+
+const (
+	StateAdminDown State = iota
+	StateDown
+	StateInit
+	StateUp
+)
+
+func (s State) String() string {
+	switch s {
+	case StateAdminDown:
+		return "AdminDown"
+	case StateDown:
+		return "Down"
+	case StateInit:
+		return "Init"
+	case StateUp:
+		return "Up"
+	}
+
+	return fmt.Sprintf("Unknown(%d)", uint8(s))
+}
+
+// Diagnostic codes of RFC 5880 4.1.
+const (
+	diagNone                 uint8 = 0
+	diagControlDetectExpired uint8 = 1
+	diagNeighborDown         uint8 = 3
+	diagAdminDown            uint8 = 7
+)
+
+const (
+	version          = 1
+	controlPacketLen = 24
+
+	flagPoll       = 0x20
+	flagFinal      = 0x10
+	flagAuth       = 0x04
+	flagMultipoint = 0x01
+)
+
+// controlPacket is a BFD control packet without authentication, the intervals are in microseconds.
+type controlPacket struct {
+	diag                      uint8
+	state                     State
+	poll                      bool
+	final                     bool
+	detectMult                uint8
+	myDiscriminator           uint32
+	yourDiscriminator         uint32
+	desiredMinTxInterval      uint32
+	requiredMinRxInterval     uint32
+	requiredMinEchoRxInterval uint32
+}
+
+func (p *controlPacket) marshal() []byte {
+	b := make([]byte, controlPacketLen)
+	b[0] = version<<5 | p.diag&0x1f
+	b[1] = uint8(p.state) << 6
+	if p.poll {
+		b[1] |= flagPoll
+	}
+	if p.final {
+		b[1] |= flagFinal
+	}
+	b[2] = p.detectMult
+	b[3] = controlPacketLen
+	binary.BigEndian.PutUint32(b[4:], p.myDiscriminator)
+	binary.BigEndian.PutUint32(b[8:], p.yourDiscriminator)
+	binary.BigEndian.PutUint32(b[12:], p.desiredMinTxInterval)
+	binary.BigEndian.PutUint32(b[16:], p.requiredMinRxInterval)
+	binary.BigEndian.PutUint32(b[20:], p.requiredMinEchoRxInterval)
+
+	return b
+}
+
+// parseControlPacket decodes b and checks it as RFC 5880 6.8.6 requires before the packet is processed.
+func parseControlPacket(b []byte) (*controlPacket, error) {
+	if len(b) < controlPacketLen {
+		return nil, fmt.Errorf("packet too short: %d", len(b))
+	}
+	if b[0]>>5 != version {
+		return nil, fmt.Errorf("unsupported version %d", b[0]>>5)
+	}
+	if int(b[3]) < controlPacketLen || int(b[3]) > len(b) {
+		return nil, fmt.Errorf("invalid length %d", b[3])
+	}
+	if b[1]&flagAuth != 0 {
+		return nil, fmt.Errorf("authentication not supported")
+	}
+	if b[1]&flagMultipoint != 0 {
+		return nil, fmt.Errorf("multipoint not supported")
+	}
+
+	p := &controlPacket{
+		diag:                      b[0] & 0x1f,
+		state:                     State(b[1] >> 6),
+		poll:                      b[1]&flagPoll != 0,
+		final:                     b[1]&flagFinal != 0,
+		detectMult:                b[2],
+		myDiscriminator:           binary.BigEndian.Uint32(b[4:]),
+		yourDiscriminator:         binary.BigEndian.Uint32(b[8:]),
+		desiredMinTxInterval:      binary.BigEndian.Uint32(b[12:]),
+		requiredMinRxInterval:     binary.BigEndian.Uint32(b[16:]),
+		requiredMinEchoRxInterval: binary.BigEndian.Uint32(b[20:]),
+	}
+	if p.detectMult == 0 {
+		return nil, fmt.Errorf("detect multiplier is zero")
+	}
+	if p.myDiscriminator == 0 {
+		return nil, fmt.Errorf("my discriminator is zero")
+	}
+	if p.yourDiscriminator == 0 && p.state != StateDown && p.state != StateAdminDown {
+		return nil, fmt.Errorf("your discriminator is zero in state %s", p.state)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,308 @@
+package bfd
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+const (
+	// DefaultPort is the destination port of single hop BFD control packets, RFC 5881 4.
+	DefaultPort = 3784
+
+	defaultInterval         = 300 * time.Millisecond
+	defaultDetectMultiplier = 3
+	// slowTxInterval is the least interval of sending while the session is not up, RFC 5880 6.8.3.
+	slowTxInterval = time.Second
+)
+
+// Config is the timers of a session, the zero values take the defaults.
+type Config struct {
+	DesiredMinTxInterval  time.Duration
+	RequiredMinRxInterval time.Duration
+	DetectMultiplier      uint8
+}
+
+func (c Config) withDefaults() Config {
+	if c.DesiredMinTxInterval <= 0 {
+		c.DesiredMinTxInterval = defaultInterval
+	}
+	if c.RequiredMinRxInterval <= 0 {
+		c.RequiredMinRxInterval = defaultInterval
+	}
+	if c.DetectMultiplier == 0 {
+		c.DetectMultiplier = defaultDetectMultiplier
+	}
+
+	return c
+}
+
+func toMicroseconds(d time.Duration) uint32 {
+	return uint32(d / time.Microsecond)
+}
+
+func fromMicroseconds(us uint32) time.Duration {
+	return time.Duration(us) * time.Microsecond
+}
+
+// session is the asynchronous mode of RFC 5880 with one neighbor, the echo function is not supported.
+type session struct {
+	peer     *net.UDPAddr
+	conn     *net.UDPConn
+	log      logr.Logger
+	onChange func(old, new State)
+
+	stopCh chan struct{}
+	kick   chan struct{}
+
+	lock                       sync.Mutex
+	config                     Config
+	localDiscr                 uint32
+	remoteDiscr                uint32
+	state                      State
+	diag                       uint8
+	remoteDesiredMinTxInterval time.Duration
+	remoteMinRxInterval        time.Duration
+	remoteDetectMult           uint8
+	lastRx                     time.Time
+	detectTimer                *time.Timer
+	// polling is true until the peer acknowledged the change of our intervals, RFC 5880 6.5
+	polling bool
+}
+
+func newSession(peer *net.UDPAddr, conn *net.UDPConn, discr uint32, config Config, onChange func(old, new State), log logr.Logger) *session {
+	return &session{
+		peer:       peer,
+		conn:       conn,
+		log:        log,
+		onChange:   onChange,
+		stopCh:     make(chan struct{}),
+		kick:       make(chan struct{}, 1),
+		config:     config.withDefaults(),
+		localDiscr: discr,
+		state:      StateDown,
+	}
+}
+
+func (s *session) getState() State {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.state
+}
+
+func (s *session) update(config Config) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	config = config.withDefaults()
+	if config != s.config {
+		s.config = config
+		s.polling = true
+		s.sendNow()
+	}
+}
+
+// run sends the periodic control packets until the session is stopped.
+func (s *session) run() {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-s.kick:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		case <-timer.C:
+		}
+
+		s.send(false)
+		timer.Reset(s.txInterval())
+	}
+}
+
+// stop ends the session, telling the peer with AdminDown unless it should look like a failure.
+func (s *session) stop(adminDown bool) {
+	close(s.stopCh)
+
+	s.lock.Lock()
+	if s.detectTimer != nil {
+		s.detectTimer.Stop()
+	}
+	s.state = StateAdminDown
+	s.diag = diagAdminDown
+	s.lock.Unlock()
+
+	if adminDown {
+		s.send(false)
+	}
+	s.conn.Close()
+}
+
+// sendNow must be called with the lock held.
+func (s *session) sendNow() {
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
+
+// advertisedTxInterval must be called with the lock held.
+func (s *session) advertisedTxInterval() time.Duration {
+	if s.state != StateUp && s.config.DesiredMinTxInterval < slowTxInterval {
+		return slowTxInterval
+	}
+
+	return s.config.DesiredMinTxInterval
+}
+
+// txInterval applies the jitter of RFC 5880 6.8.7 to the negotiated interval.
+func (s *session) txInterval() time.Duration {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	interval := s.advertisedTxInterval()
+	if s.remoteMinRxInterval > interval {
+		interval = s.remoteMinRxInterval
+	}
+	jitter := 0.75 + rand.Float64()*0.25
+	if s.config.DetectMultiplier == 1 {
+		jitter = 0.75 + rand.Float64()*0.15
+	}
+
+	return time.Duration(float64(interval) * jitter)
+}
+
+func (s *session) send(final bool) {
+	s.lock.Lock()
+	p := &controlPacket{
+		diag:                  s.diag,
+		state:                 s.state,
+		poll:                  s.polling && !final,
+		final:                 final,
+		detectMult:            s.config.DetectMultiplier,
+		myDiscriminator:       s.localDiscr,
+		yourDiscriminator:     s.remoteDiscr,
+		desiredMinTxInterval:  toMicroseconds(s.advertisedTxInterval()),
+		requiredMinRxInterval: toMicroseconds(s.config.RequiredMinRxInterval),
+	}
+	s.lock.Unlock()
+
+	if _, err := s.conn.WriteToUDP(p.marshal(), s.peer); err != nil {
+		s.log.V(1).Info("failed to send bfd control packet", "peer", s.peer, "err", err.Error())
+	}
+}
+
+// receive runs the state machine of RFC 5880 6.8.6 on a valid packet of the peer.
+func (s *session) receive(p *controlPacket) {
+	s.lock.Lock()
+	if s.state == StateAdminDown {
+		s.lock.Unlock()
+		return
+	}
+
+	if p.final {
+		s.polling = false
+	}
+	s.remoteDiscr = p.myDiscriminator
+	s.remoteDesiredMinTxInterval = fromMicroseconds(p.desiredMinTxInterval)
+	s.remoteMinRxInterval = fromMicroseconds(p.requiredMinRxInterval)
+	s.remoteDetectMult = p.detectMult
+
+	old := s.state
+	switch {
+	case p.state == StateAdminDown:
+		if s.state != StateDown {
+			s.setState(StateDown, diagNeighborDown)
+		}
+	case s.state == StateDown:
+		if p.state == StateDown {
+			s.setState(StateInit, diagNone)
+		} else if p.state == StateInit {
+			s.setState(StateUp, diagNone)
+		}
+	case s.state == StateInit:
+		if p.state == StateInit || p.state == StateUp {
+			s.setState(StateUp, diagNone)
+		}
+	case s.state == StateUp:
+		if p.state == StateDown {
+			s.setState(StateDown, diagNeighborDown)
+		}
+	}
+
+	if p.state == StateAdminDown {
+		if s.detectTimer != nil {
+			s.detectTimer.Stop()
+		}
+	} else {
+		s.lastRx = time.Now()
+		if s.detectTimer == nil {
+			s.detectTimer = time.AfterFunc(s.detectTime(), s.detectExpired)
+		} else {
+			s.detectTimer.Reset(s.detectTime())
+		}
+	}
+	new := s.state
+	s.lock.Unlock()
+
+	if p.poll {
+		s.send(true)
+	}
+	s.changed(old, new)
+}
+
+// detectTime must be called with the lock held.
+func (s *session) detectTime() time.Duration {
+	interval := s.config.RequiredMinRxInterval
+	if s.remoteDesiredMinTxInterval > interval {
+		interval = s.remoteDesiredMinTxInterval
+	}
+
+	return time.Duration(s.remoteDetectMult) * interval
+}
+
+func (s *session) detectExpired() {
+	s.lock.Lock()
+	old := s.state
+	// The timer could fire right before being reset by a new packet
+	if time.Since(s.lastRx) >= s.detectTime() && (s.state == StateInit || s.state == StateUp) {
+		s.setState(StateDown, diagControlDetectExpired)
+		s.remoteDiscr = 0
+	}
+	new := s.state
+	s.lock.Unlock()
+
+	s.changed(old, new)
+}
+
+// setState must be called with the lock held.
+func (s *session) setState(state State, diag uint8) {
+	if (s.state == StateUp) != (state == StateUp) {
+		// The advertised interval changes between the slow and the configured one
+		s.polling = true
+	}
+	s.state = state
+	s.diag = diag
+	s.sendNow()
+}
+
+func (s *session) changed(old, new State) {
+	if old == new {
+		return
+	}
+
+	s.log.Info("bfd session state changed", "peer", s.peer.IP.String(), "old", old.String(), "new", new.String())
+	if s.onChange != nil {
+		go s.onChange(old, new)
+	}
+}
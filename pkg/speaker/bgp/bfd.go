@@ -0,0 +1,78 @@
+package bgp
+
+import (
+	"fmt"
+	"time"
+
+	bgpapi "github.com/openelb/openelb/api/v1alpha2"
+	"github.com/openelb/openelb/pkg/speaker/bfd"
+	api "github.com/osrg/gobgp/api"
+	"golang.org/x/net/context"
+)
+
+func toBfdConfig(c *bgpapi.Bfd) bfd.Config {
+	return bfd.Config{
+		DesiredMinTxInterval:  time.Duration(c.DesiredMinTxInterval) * time.Millisecond,
+		RequiredMinRxInterval: time.Duration(c.RequiredMinRxInterval) * time.Millisecond,
+		DetectMultiplier:      uint8(c.DetectMultiplier),
+	}
+}
+
+// handleBfd starts, updates or stops the bfd session with the neighbor.
+func (b *Bgp) handleBfd(neighbor *bgpapi.BgpPeer, delete bool) error {
+	address := neighbor.Spec.Conf.NeighborAddress
+	if delete || neighbor.Spec.Bfd == nil {
+		if b.bfd != nil {
+			b.bfd.DeleteSession(address)
+		}
+		return nil
+	}
+
+	if b.bfd == nil {
+		return fmt.Errorf("bfd is disabled, enable it with --bfd-port")
+	}
+	if neighbor.Spec.Conf.NeighborInterface != "" {
+		return fmt.Errorf("bfd is not supported over interfaces")
+	}
+	if neighbor.Spec.EbgpMultihop != nil && neighbor.Spec.EbgpMultihop.Enabled {
+		// The sessions are single hop, their packets are dropped unless received with TTL 255
+		return fmt.Errorf("bfd is not supported over multihop sessions")
+	}
+	if neighbor.Spec.Bfd.DetectMultiplier > 255 {
+		return fmt.Errorf("field Spec.Bfd.DetectMultiplier invalid")
+	}
+
+	return b.bfd.AddSession(address, toBfdConfig(neighbor.Spec.Bfd), func(old, new bfd.State) {
+		b.onBfdChange(address, old, new)
+	})
+}
+
+// onBfdChange resets the bgp session as soon as bfd detects the peer down,
+// instead of waiting for the hold timer.
+func (b *Bgp) onBfdChange(address string, old, new bfd.State) {
+	if old != bfd.StateUp || new == bfd.StateUp {
+		return
+	}
+
+	b.log.Info("bfd down, reset bgp peer", "peer", address)
+	err := b.bgpServer.ResetPeer(context.Background(), &api.ResetPeerRequest{
+		Address:       address,
+		Communication: "BFD down",
+	})
+	if err != nil {
+		b.log.Error(err, "failed to reset bgp peer", "peer", address)
+	}
+}
+
+// bfdState returns the state of the bfd session with address for NodePeerStatus.
+func (b *Bgp) bfdState(address string) string {
+	if b.bfd == nil {
+		return ""
+	}
+	state, ok := b.bfd.State(address)
+	if !ok {
+		return ""
+	}
+
+	return state.String()
+}
@@ -1,11 +1,40 @@
 package bgp
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	bgpapi "github.com/openelb/openelb/api/v1alpha2"
+	"github.com/openelb/openelb/pkg/constant"
+	"github.com/openelb/openelb/pkg/nettool"
+	"github.com/openelb/openelb/pkg/nettool/iptables"
+	"github.com/openelb/openelb/pkg/speaker"
+	"github.com/openelb/openelb/pkg/speaker/bfd"
+	"github.com/openelb/openelb/pkg/util"
+	api "github.com/osrg/gobgp/api"
+	bgppacket "github.com/osrg/gobgp/pkg/packet/bgp"
+	"github.com/osrg/gobgp/pkg/packet/bmp"
+	"github.com/osrg/gobgp/pkg/packet/mrt"
+	"github.com/osrg/gobgp/pkg/server"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 	"testing"
 )
 
@@ -137,15 +166,15 @@ var _ = Describe("BGP test", func() {
 				nexthops := []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"}
 
 				By("Init bgp should be empty")
-				err, toAdd, toDelete := b.retriveRoutes(ip, 32, nexthops)
+				err, toAdd, toDelete := b.retriveRoutes(ip, 32, toAPIPaths(ip, 32, nexthops, 65003, nil, nil))
 				Expect(err).ShouldNot(HaveOccurred())
 				Expect(len(toAdd)).Should(Equal(3))
 				Expect(len(toDelete)).Should(Equal(0))
 
 				By("Add nexthops to bgp")
-				err = b.setBalancer(ip, nexthops)
+				err = b.setBalancer(ip, nexthops, nil, nil)
 				Expect(err).ShouldNot(HaveOccurred())
-				err, toAdd, toDelete = b.retriveRoutes(ip, 32, nexthops)
+				err, toAdd, toDelete = b.retriveRoutes(ip, 32, toAPIPaths(ip, 32, nexthops, 65003, nil, nil))
 				Expect(err).ShouldNot(HaveOccurred())
 				Expect(len(toAdd)).Should(Equal(0))
 				Expect(len(toDelete)).Should(Equal(0))
@@ -153,9 +182,9 @@ var _ = Describe("BGP test", func() {
 				By("Append a nexthop to bgp")
 				nexthops = append(nexthops, "4.4.4.4")
 				Expect(len(nexthops)).Should(Equal(4))
-				err = b.setBalancer(ip, nexthops)
+				err = b.setBalancer(ip, nexthops, nil, nil)
 				Expect(err).ShouldNot(HaveOccurred())
-				err, toAdd, toDelete = b.retriveRoutes(ip, 32, nexthops)
+				err, toAdd, toDelete = b.retriveRoutes(ip, 32, toAPIPaths(ip, 32, nexthops, 65003, nil, nil))
 				Expect(err).ShouldNot(HaveOccurred())
 				Expect(len(toAdd)).Should(Equal(0))
 				Expect(len(toDelete)).Should(Equal(0))
@@ -163,20 +192,1491 @@ var _ = Describe("BGP test", func() {
 				By("Delete two nexthops from bgp")
 				nexthops = nexthops[:len(nexthops)-2]
 				Expect(len(nexthops)).Should(Equal(2))
-				err = b.setBalancer(ip, nexthops)
+				err = b.setBalancer(ip, nexthops, nil, nil)
 				Expect(err).ShouldNot(HaveOccurred())
-				err, toAdd, toDelete = b.retriveRoutes(ip, 32, nexthops)
+				err, toAdd, toDelete = b.retriveRoutes(ip, 32, toAPIPaths(ip, 32, nexthops, 65003, nil, nil))
 				Expect(err).ShouldNot(HaveOccurred())
 				Expect(len(toAdd)).Should(Equal(0))
 				Expect(len(toDelete)).Should(Equal(0))
 
 				By("Delete all nexthops from bgp")
 				Expect(b.DelBalancer(ip)).ShouldNot(HaveOccurred())
-				err, toAdd, toDelete = b.retriveRoutes(ip, 32, nexthops)
+				err, toAdd, toDelete = b.retriveRoutes(ip, 32, toAPIPaths(ip, 32, nexthops, 65003, nil, nil))
 				Expect(err).ShouldNot(HaveOccurred())
 				Expect(len(toAdd)).Should(Equal(2))
 				Expect(len(toDelete)).Should(Equal(0))
 			})
+
+			It("Should replace routes when nexthop weights change", func() {
+				ip := "100.100.100.101"
+				nexthops := []string{"1.1.1.1", "2.2.2.2"}
+				weights := map[string]uint32{"1.1.1.1": 1, "2.2.2.2": 3}
+
+				By("Add weighted nexthops to bgp")
+				Expect(b.setBalancer(ip, nexthops, weights, nil)).ShouldNot(HaveOccurred())
+				err, toAdd, toDelete := b.retriveRoutes(ip, 32, toAPIPaths(ip, 32, nexthops, 65003, weights, nil))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(toAdd).Should(BeEmpty())
+				Expect(toDelete).Should(BeEmpty())
+
+				By("Change the weight of one nexthop")
+				weights = map[string]uint32{"1.1.1.1": 2, "2.2.2.2": 3}
+				err, toAdd, toDelete = b.retriveRoutes(ip, 32, toAPIPaths(ip, 32, nexthops, 65003, weights, nil))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(toAdd).Should(ConsistOf("1.1.1.1"))
+				Expect(toDelete).Should(BeEmpty())
+
+				Expect(b.setBalancer(ip, nexthops, weights, nil)).ShouldNot(HaveOccurred())
+				err, toAdd, toDelete = b.retriveRoutes(ip, 32, toAPIPaths(ip, 32, nexthops, 65003, weights, nil))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(toAdd).Should(BeEmpty())
+				Expect(toDelete).Should(BeEmpty())
+
+				By("Drop the weights")
+				Expect(b.setBalancer(ip, nexthops, nil, nil)).ShouldNot(HaveOccurred())
+				err, toAdd, toDelete = b.retriveRoutes(ip, 32, toAPIPaths(ip, 32, nexthops, 65003, nil, nil))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(toAdd).Should(BeEmpty())
+				Expect(toDelete).Should(BeEmpty())
+
+				Expect(b.DelBalancer(ip)).ShouldNot(HaveOccurred())
+			})
+
+			It("Should keep the aggregate apart from host routes", func() {
+				aggregate := "100.100.0.0/24"
+				ip := "100.100.0.1"
+				nexthops := []string{"1.1.1.1", "2.2.2.2"}
+
+				By("Add the aggregate and a host route")
+				Expect(b.setBalancer(aggregate, nexthops, nil, nil)).ShouldNot(HaveOccurred())
+				Expect(b.setBalancer(ip, nexthops[:1], nil, nil)).ShouldNot(HaveOccurred())
+				err, toAdd, toDelete := b.retriveRoutes("100.100.0.0", 24, toAPIPaths("100.100.0.0", 24, nexthops, 65003, nil, nil))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(toAdd).Should(BeEmpty())
+				Expect(toDelete).Should(BeEmpty())
+				err, toAdd, toDelete = b.retriveRoutes(ip, 32, toAPIPaths(ip, 32, nexthops[:1], 65003, nil, nil))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(toAdd).Should(BeEmpty())
+				Expect(toDelete).Should(BeEmpty())
+
+				By("Delete the host route")
+				Expect(b.DelBalancer(ip)).ShouldNot(HaveOccurred())
+				err, toAdd, toDelete = b.retriveRoutes(ip, 32, toAPIPaths(ip, 32, nexthops[:1], 65003, nil, nil))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(toAdd).Should(ConsistOf(nexthops[:1]))
+				Expect(toDelete).Should(BeEmpty())
+				err, toAdd, toDelete = b.retriveRoutes("100.100.0.0", 24, toAPIPaths("100.100.0.0", 24, nexthops, 65003, nil, nil))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(toAdd).Should(BeEmpty())
+				Expect(toDelete).Should(BeEmpty())
+
+				Expect(b.DelBalancer(aggregate)).ShouldNot(HaveOccurred())
+				err, toAdd, _ = b.retriveRoutes("100.100.0.0", 24, toAPIPaths("100.100.0.0", 24, nexthops, 65003, nil, nil))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(toAdd).Should(HaveLen(2))
+			})
+
+			It("Should announce v6 routes as /128", func() {
+				ip := "2001:db8::100"
+				nexthops := []string{"2001:db8:1::1", "2001:db8:1::2"}
+
+				Expect(b.setBalancer(ip, nexthops, nil, nil)).ShouldNot(HaveOccurred())
+				err, toAdd, toDelete := b.retriveRoutes(ip, 128, toAPIPaths(ip, 128, nexthops, 65003, nil, nil))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(toAdd).Should(BeEmpty())
+				Expect(toDelete).Should(BeEmpty())
+
+				By("Delete a nexthop")
+				Expect(b.setBalancer(ip, nexthops[:1], nil, nil)).ShouldNot(HaveOccurred())
+				err, toAdd, toDelete = b.retriveRoutes(ip, 128, toAPIPaths(ip, 128, nexthops, 65003, nil, nil))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(toAdd).Should(ConsistOf(nexthops[1]))
+				Expect(toDelete).Should(BeEmpty())
+
+				Expect(b.DelBalancer(ip)).ShouldNot(HaveOccurred())
+				err, toAdd, _ = b.retriveRoutes(ip, 128, toAPIPaths(ip, 128, nexthops[:1], 65003, nil, nil))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(toAdd).Should(HaveLen(1))
+			})
+
+			It("Should use the node address in the family of the ip as nexthop", func() {
+				node := corev1.Node{
+					Status: corev1.NodeStatus{
+						Addresses: []corev1.NodeAddress{
+							{Type: corev1.NodeInternalIP, Address: "192.168.0.1"},
+							{Type: corev1.NodeInternalIP, Address: "2001:db8:1::1"},
+						},
+					},
+				}
+				Expect(b.getNodeNextHop(node, "100.100.100.100")).Should(Equal("192.168.0.1"))
+				Expect(b.getNodeNextHop(node, "2001:db8::100")).Should(Equal("2001:db8:1::1"))
+
+				node.Status.Addresses = node.Status.Addresses[:1]
+				_, err := b.getNodeNextHop(node, "2001:db8::100")
+				Expect(err).Should(HaveOccurred())
+			})
+
+			It("Should skip the nodes without an address in the family of the ip", func() {
+				ip := "2001:db8::101"
+				node := func(name string, addresses ...string) corev1.Node {
+					n := corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+					for _, address := range addresses {
+						n.Status.Addresses = append(n.Status.Addresses, corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: address})
+					}
+					return n
+				}
+				dualStack := node("node1", "192.168.0.1", "2001:db8:1::1")
+				v4Only := node("node2", "192.168.0.2")
+
+				Expect(b.SetBalancer(ip, []corev1.Node{dualStack, v4Only}, nil)).ShouldNot(HaveOccurred())
+				err, toAdd, toDelete := b.retriveRoutes(ip, 128, toAPIPaths(ip, 128, []string{"2001:db8:1::1"}, 65003, nil, nil))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(toAdd).Should(BeEmpty())
+				Expect(toDelete).Should(BeEmpty())
+
+				By("No nexthop left")
+				Expect(b.SetBalancer(ip, []corev1.Node{v4Only}, nil)).Should(HaveOccurred())
+
+				Expect(b.DelBalancer(ip)).ShouldNot(HaveOccurred())
+			})
+
+			It("Should enable the families of the Eips on peers", func() {
+				v6 := &bgpapi.Family{
+					Afi:  api.Family_AFI_IP6.String(),
+					Safi: api.Family_SAFI_UNICAST.String(),
+				}
+				b.SetEipFamilies([]*bgpapi.Family{defaultFamily(net.ParseIP("1.1.1.1")), v6})
+				defer b.SetEipFamilies(nil)
+
+				afiSafis := b.defaultAfiSafis(defaultFamily(net.ParseIP("192.168.0.2")))
+				Expect(afiSafis).Should(HaveLen(2))
+				Expect(afiSafis[0].Config.Family).Should(Equal(defaultFamily(net.ParseIP("192.168.0.2"))))
+				Expect(afiSafis[1].Config.Family).Should(Equal(v6))
+			})
+
+			It("Should replace routes when path attributes change", func() {
+				ip := "100.100.100.102"
+				nexthops := []string{"1.1.1.1", "2.2.2.2"}
+				med := uint32(100)
+				attrs := &bgpapi.PathAttributes{
+					Communities:      []string{"65003:100", "no-export"},
+					LargeCommunities: []string{"65003:1:1"},
+					Med:              &med,
+					AsPathPrepend:    2,
+				}
+				routes := func() map[string]*api.Path {
+					extra, err := attrs.ToGoBgpPathAttributes(65003)
+					Expect(err).ShouldNot(HaveOccurred())
+					return toAPIPaths(ip, 32, nexthops, 65003, nil, extra)
+				}
+
+				By("Add nexthops with path attributes to bgp")
+				Expect(b.setBalancer(ip, nexthops, nil, attrs)).ShouldNot(HaveOccurred())
+				err, toAdd, toDelete := b.retriveRoutes(ip, 32, routes())
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(toAdd).Should(BeEmpty())
+				Expect(toDelete).Should(BeEmpty())
+
+				By("Change the communities")
+				attrs.Communities = []string{"65003:200"}
+				err, toAdd, toDelete = b.retriveRoutes(ip, 32, routes())
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(toAdd).Should(ConsistOf(nexthops))
+				Expect(toDelete).Should(BeEmpty())
+
+				Expect(b.setBalancer(ip, nexthops, nil, attrs)).ShouldNot(HaveOccurred())
+				err, toAdd, toDelete = b.retriveRoutes(ip, 32, routes())
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(toAdd).Should(BeEmpty())
+				Expect(toDelete).Should(BeEmpty())
+
+				By("Drop the path attributes")
+				Expect(b.setBalancer(ip, nexthops, nil, nil)).ShouldNot(HaveOccurred())
+				err, toAdd, toDelete = b.retriveRoutes(ip, 32, toAPIPaths(ip, 32, nexthops, 65003, nil, nil))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(toAdd).Should(BeEmpty())
+				Expect(toDelete).Should(BeEmpty())
+
+				Expect(b.DelBalancer(ip)).ShouldNot(HaveOccurred())
+			})
+
+			It("Should announce the path attributes of the balancer options", func() {
+				ip := "100.100.100.109"
+				node := corev1.Node{
+					ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+					Status: corev1.NodeStatus{
+						Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "1.1.1.1"}},
+					},
+				}
+				med := uint32(100)
+				attrs := &bgpapi.PathAttributes{Med: &med}
+				Expect(b.SetBalancer(ip, []corev1.Node{node}, &speaker.BalancerOptions{
+					PathAttributes: attrs,
+				})).ShouldNot(HaveOccurred())
+
+				extra, err := attrs.ToGoBgpPathAttributes(65003)
+				Expect(err).ShouldNot(HaveOccurred())
+				err, toAdd, toDelete := b.retriveRoutes(ip, 32, toAPIPaths(ip, 32, []string{"1.1.1.1"}, 65003, nil, extra))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(toAdd).Should(BeEmpty())
+				Expect(toDelete).Should(BeEmpty())
+
+				Expect(b.DelBalancer(ip)).ShouldNot(HaveOccurred())
+			})
+
+			It("Should weight the nexthops by the balancer options", func() {
+				ip := "100.100.100.110"
+				node := corev1.Node{
+					ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+					Status: corev1.NodeStatus{
+						Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "1.1.1.1"}},
+					},
+				}
+				Expect(b.SetBalancer(ip, []corev1.Node{node}, &speaker.BalancerOptions{
+					Weights: map[string]uint32{"node1": 3},
+				})).ShouldNot(HaveOccurred())
+
+				err, toAdd, toDelete := b.retriveRoutes(ip, 32, toAPIPaths(ip, 32, []string{"1.1.1.1"}, 65003, map[string]uint32{"1.1.1.1": 3}, nil))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(toAdd).Should(BeEmpty())
+				Expect(toDelete).Should(BeEmpty())
+
+				Expect(b.DelBalancer(ip)).ShouldNot(HaveOccurred())
+			})
+
+			It("Should correct the drift of the global rib", func() {
+				ip := "100.100.100.103"
+				orphanIP := "100.100.100.104"
+				nexthops := []string{"1.1.1.1", "2.2.2.2"}
+				Expect(b.setBalancer(ip, nexthops, nil, nil)).ShouldNot(HaveOccurred())
+
+				missing, orphan, err := b.syncRoutes()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(missing).Should(Equal(0))
+				Expect(orphan).Should(Equal(0))
+
+				By("Withdraw a desired path and add an unknown one behind the speaker")
+				Expect(b.deleteMultiRoutes(ip, 32, nexthops[:1])).ShouldNot(HaveOccurred())
+				orphans := toAPIPaths(orphanIP, 32, nexthops[:1], 65003, nil, nil)
+				Expect(b.addMultiRoutes(orphans, nexthops[:1])).ShouldNot(HaveOccurred())
+
+				missing, orphan, err = b.syncRoutes()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(missing).Should(Equal(1))
+				Expect(orphan).Should(Equal(1))
+
+				err, toAdd, toDelete := b.retriveRoutes(ip, 32, toAPIPaths(ip, 32, nexthops, 65003, nil, nil))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(toAdd).Should(BeEmpty())
+				Expect(toDelete).Should(BeEmpty())
+				err, toAdd, _ = b.retriveRoutes(orphanIP, 32, orphans)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(toAdd).Should(ConsistOf(nexthops[0]))
+
+				missing, orphan, err = b.syncRoutes()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(missing).Should(Equal(0))
+				Expect(orphan).Should(Equal(0))
+
+				Expect(b.DelBalancer(ip)).ShouldNot(HaveOccurred())
+			})
+
+			It("Should withdraw the paths added by hand", func() {
+				ip := "100.100.100.108"
+				By("Add a path through the gobgp api, as over grpc")
+				_, err := b.bgpServer.AddPath(context.Background(), &api.AddPathRequest{
+					Path: toAPIPath(ip, 32, "1.1.1.1", 65003, 0, nil),
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				missing, orphan, err := b.syncRoutes()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(missing).Should(Equal(0))
+				Expect(orphan).Should(Equal(1))
+
+				err, toAdd, _ := b.retriveRoutes(ip, 32, toAPIPaths(ip, 32, []string{"1.1.1.1"}, 65003, nil, nil))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(toAdd).Should(ConsistOf("1.1.1.1"))
+			})
+		})
+	})
+
+	Context("Update BgpConf incrementally", func() {
+		conf := func(port int32, restartTime uint32) *bgpapi.BgpConf {
+			return &bgpapi.BgpConf{
+				Spec: bgpapi.BgpConfSpec{
+					As:         65003,
+					RouterId:   "10.0.255.254",
+					ListenPort: port,
+					GracefulRestart: &bgpapi.GracefulRestart{
+						Enabled:     true,
+						RestartTime: restartTime,
+					},
+				},
+			}
+		}
+		nexthops := []string{"1.1.1.1"}
+		hasPath := func(ip string) bool {
+			err, toAdd, _ := b.retriveRoutes(ip, 32, toAPIPaths(ip, 32, nexthops, 65003, nil, nil))
+			Expect(err).ShouldNot(HaveOccurred())
+			return len(toAdd) == 0
+		}
+		getPeer := func(address string) *api.Peer {
+			var result *api.Peer
+			Expect(b.bgpServer.ListPeer(context.Background(), &api.ListPeerRequest{
+				Address: address,
+			}, func(peer *api.Peer) {
+				result = peer
+			})).ShouldNot(HaveOccurred())
+			return result
+		}
+
+		It("Should apply the other changes without restart", func() {
+			ip := "100.100.100.105"
+			Expect(b.HandleBgpGlobalConfig(conf(17900, 120), "", false)).ShouldNot(HaveOccurred())
+			Expect(b.HandleBgpPeer(&bgpapi.BgpPeer{
+				Spec: bgpapi.BgpPeerSpec{
+					Conf: &bgpapi.PeerConf{
+						PeerAs:          65001,
+						NeighborAddress: "192.168.0.3",
+					},
+					GracefulRestart: &bgpapi.GracefulRestart{
+						Enabled: true,
+					},
+				},
+			}, false)).ShouldNot(HaveOccurred())
+
+			By("Add a path behind the speaker, only a restart would drop it")
+			Expect(b.addMultiRoutes(toAPIPaths(ip, 32, nexthops, 65003, nil, nil), nexthops)).ShouldNot(HaveOccurred())
+
+			changed := conf(17900, 120)
+			changed.Spec.Priority = 1
+			Expect(b.HandleBgpGlobalConfig(changed, "", false)).ShouldNot(HaveOccurred())
+			Expect(hasPath(ip)).Should(BeTrue())
+			Expect(getPeer("192.168.0.3").GracefulRestart.LocalRestarting).Should(BeFalse())
+			Expect(b.conf.Priority).Should(Equal(int32(1)))
+
+			Expect(b.deleteMultiRoutes(ip, 32, nexthops)).ShouldNot(HaveOccurred())
+		})
+
+		It("Should restart gracefully when the graceful restart changes", func() {
+			ip := "100.100.100.105"
+			Expect(b.addMultiRoutes(toAPIPaths(ip, 32, nexthops, 65003, nil, nil), nexthops)).ShouldNot(HaveOccurred())
+
+			Expect(b.HandleBgpGlobalConfig(conf(17900, 90), "", false)).ShouldNot(HaveOccurred())
+			Expect(hasPath(ip)).Should(BeFalse())
+			Expect(getPeer("192.168.0.3").GracefulRestart.LocalRestarting).Should(BeTrue())
+			Expect(b.conf.GracefulRestart.RestartTime).Should(Equal(uint32(90)))
+		})
+
+		It("Should restart gracefully when the listen port changes", func() {
+			ip := "100.100.100.106"
+			orphanIP := "100.100.100.107"
+			Expect(b.setBalancer(ip, nexthops, nil, nil)).ShouldNot(HaveOccurred())
+			Expect(b.addMultiRoutes(toAPIPaths(orphanIP, 32, nexthops, 65003, nil, nil), nexthops)).ShouldNot(HaveOccurred())
+
+			Expect(b.HandleBgpGlobalConfig(conf(17901, 90), "", false)).ShouldNot(HaveOccurred())
+			Expect(hasPath(ip)).Should(BeTrue())
+			Expect(hasPath(orphanIP)).Should(BeFalse())
+
+			By("The peers are restored, the ones with graceful restart as restarting")
+			Expect(getPeer("192.168.0.2")).ShouldNot(BeNil())
+			Expect(getPeer("192.168.0.3").GracefulRestart.LocalRestarting).Should(BeTrue())
+
+			Expect(b.DelBalancer(ip)).ShouldNot(HaveOccurred())
+			Expect(b.HandleBgpPeer(&bgpapi.BgpPeer{
+				Spec: bgpapi.BgpPeerSpec{
+					Conf: &bgpapi.PeerConf{
+						NeighborAddress: "192.168.0.3",
+					},
+				},
+			}, true)).ShouldNot(HaveOccurred())
+		})
+	})
+
+	Context("BFD", func() {
+		peer := &bgpapi.BgpPeer{
+			Spec: bgpapi.BgpPeerSpec{
+				Conf: &bgpapi.PeerConf{
+					PeerAs:          65001,
+					NeighborAddress: "127.0.0.1",
+				},
+				Bfd: &bgpapi.Bfd{
+					DesiredMinTxInterval:  50,
+					RequiredMinRxInterval: 50,
+				},
+			},
+		}
+
+		It("Should fail if bfd is disabled", func() {
+			Expect(b.HandleBgpPeer(peer.DeepCopy(), false)).Should(HaveOccurred())
+		})
+
+		It("Should fail over multihop sessions", func() {
+			b.bfd = bfd.NewManager(23786, 23787)
+			defer func() {
+				b.bfd = nil
+			}()
+			multihop := peer.DeepCopy()
+			multihop.Spec.EbgpMultihop = &bgpapi.EbgpMultihop{
+				Enabled:     true,
+				MultihopTtl: 2,
+			}
+			Expect(b.HandleBgpPeer(multihop, false)).Should(HaveOccurred())
+			_, ok := b.bfd.State("127.0.0.1")
+			Expect(ok).Should(BeFalse())
+		})
+
+		It("Should report the bfd state in the peer status", func() {
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+			b.bfd = bfd.NewManager(23786, 23787)
+			defer func() {
+				b.bfd = nil
+			}()
+			remote := bfd.NewManager(23787, 23786)
+			Expect(b.bfd.Start(stopCh)).ShouldNot(HaveOccurred())
+			Expect(remote.Start(stopCh)).ShouldNot(HaveOccurred())
+			Expect(remote.AddSession("127.0.0.1", bfd.Config{}, nil)).ShouldNot(HaveOccurred())
+
+			Expect(b.HandleBgpPeer(peer.DeepCopy(), false)).ShouldNot(HaveOccurred())
+			bfdState := func() string {
+				for _, p := range b.HandleBgpPeerStatus([]bgpapi.BgpPeer{*peer}) {
+					return p.Status.NodesPeerStatus[util.GetNodeName()].BfdState
+				}
+				return ""
+			}
+			Eventually(bfdState, 5*time.Second).Should(Equal("Up"))
+
+			By("The peer is gone")
+			remote.DeleteSession("127.0.0.1")
+			Eventually(bfdState, time.Second).Should(Equal("Down"))
+
+			Expect(b.HandleBgpPeer(peer.DeepCopy(), true)).ShouldNot(HaveOccurred())
+			_, ok := b.bfd.State("127.0.0.1")
+			Expect(ok).Should(BeFalse())
+		})
+	})
+
+	Context("Extended nexthop", func() {
+		It("Should default the families of the peers over interfaces", func() {
+			peer := &bgpapi.BgpPeer{
+				Spec: bgpapi.BgpPeerSpec{
+					Conf: &bgpapi.PeerConf{
+						PeerAs:            65001,
+						NeighborInterface: "lo",
+					},
+				},
+			}
+			// lo has no IPv6 link-local neighbor for gobgp to peer with
+			Expect(b.HandleBgpPeer(peer, false)).Should(HaveOccurred())
+			Expect(peer.Spec.AfiSafis[0].Config.Family).Should(Equal(defaultFamily(net.IPv6unspecified)))
+			Expect(peer.Spec.AfiSafis[1].Config.Family).Should(Equal(defaultFamily(net.IPv4zero)))
+		})
+
+		It("Should only send the routes through this node with the local address of the session as nexthop", func() {
+			ip := "100.100.100.110"
+			otherIP := "100.100.100.111"
+			node := func(name, address string) corev1.Node {
+				return corev1.Node{
+					ObjectMeta: metav1.ObjectMeta{Name: name},
+					Status: corev1.NodeStatus{
+						Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: address}},
+					},
+				}
+			}
+			nodeName := os.Getenv(constant.EnvNodeName)
+			os.Setenv(constant.EnvNodeName, "node1")
+			defer os.Setenv(constant.EnvNodeName, nodeName)
+
+			remote := server.NewBgpServer()
+			go remote.Serve()
+			defer remote.StopBgp(context.Background(), &api.StopBgpRequest{})
+			Expect(remote.StartBgp(context.Background(), &api.StartBgpRequest{
+				Global: &api.Global{
+					As:              65010,
+					RouterId:        "10.0.0.10",
+					ListenPort:      17910,
+					ListenAddresses: []string{"127.0.0.2"},
+				},
+			})).ShouldNot(HaveOccurred())
+			Expect(remote.AddPeer(context.Background(), &api.AddPeerRequest{
+				Peer: &api.Peer{
+					Conf: &api.PeerConf{
+						NeighborAddress: "127.0.0.1",
+						PeerAs:          65003,
+					},
+					Transport: &api.Transport{
+						PassiveMode: true,
+					},
+				},
+			})).ShouldNot(HaveOccurred())
+			// The remote drops the loopback nexthop, so the routes are checked as they are sent
+			sentNexthop := func(ip string) func() string {
+				return func() string {
+					nexthop := ""
+					b.bgpServer.ListPath(context.Background(), &api.ListPathRequest{
+						TableType: api.TableType_ADJ_OUT,
+						Name:      "127.0.0.2",
+						Family:    &api.Family{Afi: api.Family_AFI_IP, Safi: api.Family_SAFI_UNICAST},
+						Prefixes:  []*api.TableLookupPrefix{{Prefix: ip + "/32"}},
+					}, func(d *api.Destination) {
+						for _, path := range d.Paths {
+							nexthop = fromAPIPath(path).String()
+						}
+					})
+					return nexthop
+				}
+			}
+
+			enabled := true
+			peer := &bgpapi.BgpPeer{
+				Spec: bgpapi.BgpPeerSpec{
+					Conf: &bgpapi.PeerConf{
+						PeerAs:          65010,
+						NeighborAddress: "127.0.0.2",
+					},
+					Transport: &bgpapi.Transport{
+						RemotePort: 17910,
+					},
+					ExtendedNexthop: &enabled,
+				},
+			}
+			Expect(b.HandleBgpPeer(peer.DeepCopy(), false)).ShouldNot(HaveOccurred())
+			Expect(b.SetBalancer(ip, []corev1.Node{node("node1", "1.1.1.1")}, nil)).ShouldNot(HaveOccurred())
+			Expect(b.SetBalancer(otherIP, []corev1.Node{node("node2", "2.2.2.2")}, nil)).ShouldNot(HaveOccurred())
+			Eventually(sentNexthop(ip), 20*time.Second).Should(Equal("127.0.0.1"))
+			Consistently(sentNexthop(otherIP), time.Second).Should(BeEmpty())
+
+			By("Disabling it sends the routes again with their own nexthop")
+			enabled = false
+			Expect(b.HandleBgpPeer(peer.DeepCopy(), false)).ShouldNot(HaveOccurred())
+			Eventually(sentNexthop(ip), 3*time.Second).Should(Equal("1.1.1.1"))
+			Eventually(sentNexthop(otherIP), 3*time.Second).Should(Equal("2.2.2.2"))
+
+			Expect(b.DelBalancer(ip)).ShouldNot(HaveOccurred())
+			Expect(b.DelBalancer(otherIP)).ShouldNot(HaveOccurred())
+			Expect(b.HandleBgpPeer(peer.DeepCopy(), true)).ShouldNot(HaveOccurred())
+		})
+
+		It("Should match every other address of the family", func() {
+			Expect(otherAddresses(net.ParseIP("1.1.1.1"))).Should(HaveLen(32))
+			for _, prefix := range otherAddresses(net.ParseIP("1.1.1.1")) {
+				_, ipNet, err := net.ParseCIDR(prefix)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(ipNet.Contains(net.ParseIP("1.1.1.1"))).Should(BeFalse())
+			}
+			Expect(otherAddresses(net.ParseIP("1.1.1.1"))).Should(ContainElements("128.0.0.0/1", "1.1.1.0/32"))
+			Expect(otherAddresses(net.ParseIP("2001:db8::1"))).Should(HaveLen(128))
+		})
+	})
+
+	Context("Mesh", func() {
+		meshPeer := func(address string) *api.Peer {
+			var result *api.Peer
+			b.bgpServer.ListPeer(context.Background(), &api.ListPeerRequest{
+				Address: address,
+			}, func(peer *api.Peer) {
+				result = peer
+			})
+			return result
+		}
+
+		It("Should peer with the other speakers", func() {
+			Expect(b.HandleMesh([]MeshPeer{{
+				Address:         "192.168.0.11",
+				As:              65003,
+				Port:            17900,
+				ReflectorClient: true,
+			}, {
+				Address: "192.168.0.12",
+				As:      65003,
+			}})).ShouldNot(HaveOccurred())
+
+			peer := meshPeer("192.168.0.11")
+			Expect(peer).ShouldNot(BeNil())
+			Expect(peer.Conf.PeerAs).Should(Equal(uint32(65003)))
+			Expect(peer.Transport.RemotePort).Should(Equal(uint32(17900)))
+			Expect(peer.RouteReflector.RouteReflectorClient).Should(BeTrue())
+			Expect(meshPeer("192.168.0.12")).ShouldNot(BeNil())
+
+			By("The status sync of the BgpPeers keeps them")
+			b.HandleBgpPeerStatus(nil)
+			Expect(meshPeer("192.168.0.11")).ShouldNot(BeNil())
+
+			By("The speakers left are deleted")
+			Expect(b.HandleMesh([]MeshPeer{{
+				Address: "192.168.0.12",
+				As:      65003,
+			}})).ShouldNot(HaveOccurred())
+			Expect(meshPeer("192.168.0.11")).Should(BeNil())
+			Expect(meshPeer("192.168.0.12")).ShouldNot(BeNil())
+
+			Expect(b.HandleMesh(nil)).ShouldNot(HaveOccurred())
+			Expect(meshPeer("192.168.0.12")).Should(BeNil())
+			Expect(b.meshPeers).Should(BeEmpty())
+		})
+	})
+
+	Context("BMP", func() {
+		It("Should stream the routes and the peer state to the bmp servers", func() {
+			// The collector records the types of the messages received
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).ShouldNot(HaveOccurred())
+			defer lis.Close()
+			var (
+				lock     sync.Mutex
+				messages []uint8
+			)
+			go func() {
+				conn, err := lis.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				for {
+					header := make([]byte, bmp.BMP_HEADER_SIZE)
+					if _, err := io.ReadFull(conn, header); err != nil {
+						return
+					}
+					body := make([]byte, binary.BigEndian.Uint32(header[1:5])-bmp.BMP_HEADER_SIZE)
+					if _, err := io.ReadFull(conn, body); err != nil {
+						return
+					}
+					msg, err := bmp.ParseBMPMessage(append(header, body...))
+					if err != nil {
+						return
+					}
+					lock.Lock()
+					messages = append(messages, msg.Header.Type)
+					lock.Unlock()
+				}
+			}()
+			received := func() []uint8 {
+				lock.Lock()
+				defer lock.Unlock()
+				return append([]uint8{}, messages...)
+			}
+
+			port := lis.Addr().(*net.TCPAddr).Port
+			conf := b.conf.DeepCopy()
+			conf.BmpServers = []bgpapi.BmpServer{{
+				Address:               "127.0.0.1",
+				Port:                  uint32(port),
+				RouteMonitoringPolicy: "LOCAL",
+			}}
+			Expect(b.HandleBgpGlobalConfig(&bgpapi.BgpConf{Spec: *conf}, "", false)).ShouldNot(HaveOccurred())
+			Expect(b.bmpServers).Should(HaveLen(1))
+			Eventually(received, 10*time.Second).Should(ContainElement(uint8(bmp.BMP_MSG_INITIATION)))
+
+			ip := "100.100.100.120"
+			Expect(b.setBalancer(ip, []string{"1.1.1.1"}, nil, nil)).ShouldNot(HaveOccurred())
+			defer b.DelBalancer(ip)
+			Eventually(received, 10*time.Second).Should(ContainElement(uint8(bmp.BMP_MSG_ROUTE_MONITORING)))
+
+			By("A session coming up and going down")
+			remote := server.NewBgpServer()
+			go remote.Serve()
+			defer remote.StopBgp(context.Background(), &api.StopBgpRequest{})
+			Expect(remote.StartBgp(context.Background(), &api.StartBgpRequest{
+				Global: &api.Global{
+					As:              65010,
+					RouterId:        "10.0.0.10",
+					ListenPort:      17912,
+					ListenAddresses: []string{"127.0.0.5"},
+				},
+			})).ShouldNot(HaveOccurred())
+			Expect(remote.AddPeer(context.Background(), &api.AddPeerRequest{
+				Peer: &api.Peer{
+					Conf: &api.PeerConf{
+						NeighborAddress: "127.0.0.1",
+						PeerAs:          conf.As,
+					},
+					Transport: &api.Transport{
+						PassiveMode: true,
+					},
+				},
+			})).ShouldNot(HaveOccurred())
+			peer := &bgpapi.BgpPeer{
+				Spec: bgpapi.BgpPeerSpec{
+					Conf: &bgpapi.PeerConf{
+						PeerAs:          65010,
+						NeighborAddress: "127.0.0.5",
+					},
+					Transport: &bgpapi.Transport{
+						RemotePort: 17912,
+					},
+				},
+			}
+			Expect(b.HandleBgpPeer(peer.DeepCopy(), false)).ShouldNot(HaveOccurred())
+			Eventually(received, 20*time.Second).Should(ContainElement(uint8(bmp.BMP_MSG_PEER_UP_NOTIFICATION)))
+			Expect(b.HandleBgpPeer(peer.DeepCopy(), true)).ShouldNot(HaveOccurred())
+			Eventually(received, 10*time.Second).Should(ContainElement(uint8(bmp.BMP_MSG_PEER_DOWN_NOTIFICATION)))
+
+			By("Removing the bmp server")
+			conf.BmpServers = nil
+			Expect(b.HandleBgpGlobalConfig(&bgpapi.BgpConf{Spec: *conf}, "", false)).ShouldNot(HaveOccurred())
+			Expect(b.bmpServers).Should(BeEmpty())
+			Eventually(received, 10*time.Second).Should(ContainElement(uint8(bmp.BMP_MSG_TERMINATION)))
+		})
+	})
+
+	Context("MRT", func() {
+		parse := func(data []byte) []*mrt.MRTMessage {
+			var messages []*mrt.MRTMessage
+			for len(data) > 0 {
+				header := &mrt.MRTHeader{}
+				Expect(header.DecodeFromBytes(data[:mrt.MRT_COMMON_HEADER_LEN])).ShouldNot(HaveOccurred())
+				data = data[mrt.MRT_COMMON_HEADER_LEN:]
+				msg, err := mrt.ParseMRTBody(header, data[:header.Len])
+				Expect(err).ShouldNot(HaveOccurred())
+				messages = append(messages, msg)
+				data = data[header.Len:]
+			}
+			return messages
+		}
+
+		It("Should dump the v4 and v6 routes of the global rib", func() {
+			Expect(b.setBalancer("100.100.100.130", []string{"1.1.1.1", "1.1.1.2"}, nil, nil)).ShouldNot(HaveOccurred())
+			defer b.DelBalancer("100.100.100.130")
+			Expect(b.setBalancer("2001:db8::130", []string{"2001:db8:1::1"}, nil, nil)).ShouldNot(HaveOccurred())
+			defer b.DelBalancer("2001:db8::130")
+
+			buf := &bytes.Buffer{}
+			Expect(b.DumpMRT(buf)).ShouldNot(HaveOccurred())
+			messages := parse(buf.Bytes())
+
+			Expect(messages[0].Header.SubType).Should(Equal(uint16(mrt.PEER_INDEX_TABLE)))
+			Expect(messages[0].Body.(*mrt.PeerIndexTable).Peers[0].AS).Should(Equal(b.conf.As))
+			ribs := make(map[string]*mrt.Rib)
+			for _, msg := range messages[1:] {
+				rib := msg.Body.(*mrt.Rib)
+				ribs[rib.Prefix.String()] = rib
+			}
+			Expect(ribs).Should(HaveKey("100.100.100.130/32"))
+			Expect(ribs["100.100.100.130/32"].Entries).Should(HaveLen(2))
+			Expect(ribs).Should(HaveKey("2001:db8::130/128"))
+			Expect(ribs["2001:db8::130/128"].RouteFamily).Should(Equal(bgppacket.RF_IPv6_UC))
+		})
+
+		It("Should serve the dump over http", func() {
+			Expect(b.setBalancer("100.100.100.131", []string{"1.1.1.1"}, nil, nil)).ShouldNot(HaveOccurred())
+			defer b.DelBalancer("100.100.100.131")
+
+			recorder := httptest.NewRecorder()
+			b.MRTHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/mrt", nil))
+			Expect(recorder.Code).Should(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Type")).Should(Equal("application/octet-stream"))
+			Expect(len(parse(recorder.Body.Bytes()))).Should(BeNumerically(">", 1))
+		})
+
+		It("Should replace the dump file", func() {
+			dir, err := ioutil.TempDir("", "mrt")
+			Expect(err).ShouldNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+
+			file := filepath.Join(dir, "rib.mrt")
+			Expect(b.dumpMRTFile(file)).ShouldNot(HaveOccurred())
+			Expect(b.dumpMRTFile(file)).ShouldNot(HaveOccurred())
+			data, err := ioutil.ReadFile(file)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(parse(data)).ShouldNot(BeEmpty())
+			files, err := ioutil.ReadDir(dir)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(files).Should(HaveLen(1))
+		})
+	})
+
+	Context("Port forward", func() {
+		It("Should forward port 179 to the listen port for the peers using it", func() {
+			ipt := iptables.NewFakeIPTables()
+			b.ipt = ipt
+			defer func() { b.ipt = nil }()
+			port := b.conf.ListenPort
+
+			peer := &bgpapi.BgpPeer{
+				Spec: bgpapi.BgpPeerSpec{
+					Conf: &bgpapi.PeerConf{
+						PeerAs:          65010,
+						NeighborAddress: "127.0.0.6",
+					},
+					Transport: &bgpapi.Transport{
+						LocalAddress: "127.0.0.1",
+					},
+					UsingPortForward: true,
+				},
+			}
+			Expect(b.HandleBgpPeer(peer.DeepCopy(), false)).ShouldNot(HaveOccurred())
+			Expect(ipt.Exists("nat", "PREROUTING", "-j", nettool.BgpNatChain)).Should(BeTrue())
+			Expect(ipt.Exists("nat", nettool.BgpNatChain, nettool.GenerateCretiriaAndAction("127.0.0.6", "127.0.0.1", port)...)).Should(BeTrue())
+
+			By("Changing the local address")
+			peer.Spec.Transport.LocalAddress = "127.0.0.2"
+			Expect(b.HandleBgpPeer(peer.DeepCopy(), false)).ShouldNot(HaveOccurred())
+			Expect(ipt.Data["nat"][nettool.BgpNatChain]).Should(HaveLen(1))
+			Expect(ipt.Exists("nat", nettool.BgpNatChain, nettool.GenerateCretiriaAndAction("127.0.0.6", "127.0.0.2", port)...)).Should(BeTrue())
+
+			By("Disabling the port forward")
+			peer.Spec.UsingPortForward = false
+			Expect(b.HandleBgpPeer(peer.DeepCopy(), false)).ShouldNot(HaveOccurred())
+			Expect(ipt.Data["nat"]).ShouldNot(HaveKey(nettool.BgpNatChain))
+			Expect(ipt.Data["nat"]["PREROUTING"]).Should(BeEmpty())
+
+			By("Deleting the global config")
+			peer.Spec.UsingPortForward = true
+			Expect(b.HandleBgpPeer(peer.DeepCopy(), false)).ShouldNot(HaveOccurred())
+			Expect(b.portForwards).Should(HaveLen(1))
+			Expect(b.deletePortForwards()).ShouldNot(HaveOccurred())
+			Expect(b.portForwards).Should(BeEmpty())
+			Expect(ipt.Data["nat"]).ShouldNot(HaveKey(nettool.BgpNatChain))
+			Expect(b.HandleBgpPeer(peer.DeepCopy(), true)).ShouldNot(HaveOccurred())
+		})
+
+		It("Should refuse IPv6 peers", func() {
+			b.ipt = iptables.NewFakeIPTables()
+			defer func() { b.ipt = nil }()
+
+			Expect(b.HandleBgpPeer(&bgpapi.BgpPeer{
+				Spec: bgpapi.BgpPeerSpec{
+					Conf: &bgpapi.PeerConf{
+						PeerAs:          65010,
+						NeighborAddress: "2001:db8::6",
+					},
+					UsingPortForward: true,
+				},
+			}, false)).Should(HaveOccurred())
+		})
+	})
+
+	Context("Peer state events", func() {
+		It("Should send an event when a session goes up or down", func() {
+			remote := server.NewBgpServer()
+			go remote.Serve()
+			defer remote.StopBgp(context.Background(), &api.StopBgpRequest{})
+			Expect(remote.StartBgp(context.Background(), &api.StartBgpRequest{
+				Global: &api.Global{
+					As:              65010,
+					RouterId:        "10.0.0.10",
+					ListenPort:      17911,
+					ListenAddresses: []string{"127.0.0.3"},
+				},
+			})).ShouldNot(HaveOccurred())
+			remotePeer := &api.Peer{
+				Conf: &api.PeerConf{
+					NeighborAddress: "127.0.0.1",
+					PeerAs:          65003,
+				},
+				Transport: &api.Transport{
+					PassiveMode: true,
+				},
+			}
+			Expect(remote.AddPeer(context.Background(), &api.AddPeerRequest{
+				Peer: remotePeer,
+			})).ShouldNot(HaveOccurred())
+
+			peer := &bgpapi.BgpPeer{
+				Spec: bgpapi.BgpPeerSpec{
+					Conf: &bgpapi.PeerConf{
+						PeerAs:          65010,
+						NeighborAddress: "127.0.0.3",
+					},
+					Transport: &bgpapi.Transport{
+						RemotePort: 17911,
+					},
+				},
+			}
+			Expect(b.HandleBgpPeer(peer.DeepCopy(), false)).ShouldNot(HaveOccurred())
+			defer b.HandleBgpPeer(peer.DeepCopy(), true)
+
+			// The sessions of the other tests send events too
+			nextState := func() string {
+				for {
+					select {
+					case e := <-b.PeerStateEvents():
+						if e.Address == "127.0.0.3" {
+							return e.State
+						}
+					default:
+						return ""
+					}
+				}
+			}
+			Eventually(nextState, 20*time.Second).Should(Equal("ESTABLISHED"))
+
+			By("Deleting the peer on the remote closes the session")
+			Expect(remote.DeletePeer(context.Background(), &api.DeletePeerRequest{
+				Address: "127.0.0.1",
+			})).ShouldNot(HaveOccurred())
+			Eventually(nextState, 10*time.Second).Should(Equal("IDLE"))
+		})
+
+		It("Should only count and send the sessions entering or leaving the established state", func() {
+			address := "192.0.2.1"
+			flaps := func() float64 {
+				families, err := crmetrics.Registry.Gather()
+				Expect(err).ShouldNot(HaveOccurred())
+				for _, family := range families {
+					if family.GetName() != "session_flaps_total" {
+						continue
+					}
+					for _, m := range family.Metric {
+						for _, label := range m.Label {
+							if label.GetName() == "peerIP" && label.GetValue() == address {
+								return m.Counter.GetValue()
+							}
+						}
+					}
+				}
+				return 0
+			}
+			var states []string
+			for _, state := range []api.PeerState_SessionState{
+				api.PeerState_IDLE, api.PeerState_CONNECT, api.PeerState_ACTIVE, api.PeerState_OPENSENT,
+				api.PeerState_OPENCONFIRM, api.PeerState_ESTABLISHED, api.PeerState_IDLE, api.PeerState_CONNECT,
+				api.PeerState_ACTIVE, api.PeerState_IDLE,
+			} {
+				b.onPeerState(&api.Peer{
+					Conf:  &api.PeerConf{NeighborAddress: address},
+					State: &api.PeerState{NeighborAddress: address, SessionState: state},
+				})
+			}
+			for len(b.peerEvents) > 0 {
+				if e := <-b.PeerStateEvents(); e.Address == address {
+					states = append(states, e.State)
+				}
+			}
+			Expect(states).Should(Equal([]string{"ESTABLISHED", "IDLE"}))
+			Expect(flaps()).Should(Equal(float64(1)))
+		})
+	})
+
+	// leakAs starts a remote speaker of the AS at address announcing the prefixes
+	leakAs := func(as uint32, address string, port int32, prefixes ...string) *server.BgpServer {
+		remote := server.NewBgpServer()
+		go remote.Serve()
+		Expect(remote.StartBgp(context.Background(), &api.StartBgpRequest{
+			Global: &api.Global{
+				As: as,
+				// gobgp sends no route to a router with the id of the one it came from
+				RouterId:        address,
+				ListenPort:      port,
+				ListenAddresses: []string{address},
+			},
+		})).ShouldNot(HaveOccurred())
+		Expect(remote.AddPeer(context.Background(), &api.AddPeerRequest{
+			Peer: &api.Peer{
+				Conf: &api.PeerConf{
+					NeighborAddress: "127.0.0.1",
+					PeerAs:          b.conf.As,
+				},
+				Transport: &api.Transport{
+					PassiveMode: true,
+				},
+			},
+		})).ShouldNot(HaveOccurred())
+		// A loopback nexthop would be taken as a withdraw
+		nexthop := &api.Policy{
+			Name: "nexthop",
+			Statements: []*api.Statement{{
+				Actions: &api.Actions{
+					Nexthop: &api.NexthopAction{Address: "10.0.0.10"},
+				},
+			}},
+		}
+		Expect(remote.AddPolicy(context.Background(), &api.AddPolicyRequest{
+			Policy: nexthop,
+		})).ShouldNot(HaveOccurred())
+		Expect(remote.AddPolicyAssignment(context.Background(), &api.AddPolicyAssignmentRequest{
+			Assignment: &api.PolicyAssignment{
+				Name:          "global",
+				Direction:     api.PolicyDirection_EXPORT,
+				Policies:      []*api.Policy{nexthop},
+				DefaultAction: api.RouteAction_ACCEPT,
+			},
+		})).ShouldNot(HaveOccurred())
+		for _, prefix := range prefixes {
+			_, err := remote.AddPath(context.Background(), &api.AddPathRequest{
+				Path: toAPIPath(prefix, 24, address, 0, 0, nil),
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+		}
+		return remote
+	}
+	leak := func(address string, port int32, prefixes ...string) *server.BgpServer {
+		return leakAs(65010, address, port, prefixes...)
+	}
+	received := func(address string) func() int {
+		return func() int {
+			count := 0
+			b.bgpServer.ListPath(context.Background(), &api.ListPathRequest{
+				TableType: api.TableType_GLOBAL,
+				Family:    getFamily("1.1.1.1"),
+			}, func(d *api.Destination) {
+				for _, path := range d.Paths {
+					if path.NeighborIp == address {
+						count++
+					}
+				}
+			})
+			return count
+		}
+	}
+	state := func(address string) func() api.PeerState_SessionState {
+		return func() api.PeerState_SessionState {
+			result := api.PeerState_UNKNOWN
+			b.bgpServer.ListPeer(context.Background(), &api.ListPeerRequest{
+				Address: address,
+			}, func(peer *api.Peer) {
+				result = peer.State.SessionState
+			})
+			return result
+		}
+	}
+
+	Context("Routes received", func() {
+		It("Should reject the routes of the peers with the default import action Reject", func() {
+			remote := leak("127.0.0.7", 17913, "10.10.10.0", "10.10.11.0")
+			defer remote.StopBgp(context.Background(), &api.StopBgpRequest{})
+
+			peer := &bgpapi.BgpPeer{
+				Spec: bgpapi.BgpPeerSpec{
+					Conf: &bgpapi.PeerConf{
+						PeerAs:          65010,
+						NeighborAddress: "127.0.0.7",
+					},
+					Transport: &bgpapi.Transport{
+						RemotePort: 17913,
+					},
+					DefaultImportAction: bgpapi.ImportActionReject,
+				},
+			}
+			Expect(b.HandleBgpPeer(peer.DeepCopy(), false)).ShouldNot(HaveOccurred())
+			defer b.HandleBgpPeer(peer.DeepCopy(), true)
+			Eventually(state("127.0.0.7"), 20*time.Second).Should(Equal(api.PeerState_ESTABLISHED))
+			Consistently(received("127.0.0.7"), 3*time.Second).Should(BeZero())
+
+			By("Accepting the routes again")
+			peer.Spec.DefaultImportAction = bgpapi.ImportActionAccept
+			Expect(b.HandleBgpPeer(peer.DeepCopy(), false)).ShouldNot(HaveOccurred())
+			Eventually(received("127.0.0.7"), 10*time.Second).Should(Equal(2))
+			Expect(b.rejectImports).Should(BeEmpty())
+		})
+
+		It("Should close the session of a peer sending too many routes", func() {
+			remote := leak("127.0.0.8", 17914, "10.10.20.0", "10.10.21.0", "10.10.22.0")
+			defer remote.StopBgp(context.Background(), &api.StopBgpRequest{})
+
+			peer := &bgpapi.BgpPeer{
+				Spec: bgpapi.BgpPeerSpec{
+					Conf: &bgpapi.PeerConf{
+						PeerAs:          65010,
+						NeighborAddress: "127.0.0.8",
+					},
+					Transport: &bgpapi.Transport{
+						RemotePort: 17914,
+					},
+					MaxPrefixes: &bgpapi.MaxPrefixes{
+						Shutdown: 2,
+						Warning:  1,
+					},
+				},
+			}
+			Expect(b.HandleBgpPeer(peer.DeepCopy(), false)).ShouldNot(HaveOccurred())
+			defer b.HandleBgpPeer(peer.DeepCopy(), true)
+			Expect(b.peers["127.0.0.8"].AfiSafis[0].PrefixLimits.MaxPrefixes).Should(Equal(uint32(2)))
+			// The session is closed as soon as the routes come, too fast to be seen established
+			Eventually(func() uint64 {
+				var sent uint64
+				b.bgpServer.ListPeer(context.Background(), &api.ListPeerRequest{
+					Address: "127.0.0.8",
+				}, func(peer *api.Peer) {
+					if peer.State.Messages != nil && peer.State.Messages.Sent != nil {
+						sent = peer.State.Messages.Sent.Notification
+					}
+				})
+				return sent
+			}, 20*time.Second).ShouldNot(BeZero())
+			Expect(received("127.0.0.8")()).Should(BeZero())
+		})
+	})
+
+	Context("Mesh routes", func() {
+		It("Should only send the routes of the Eips to the upstream peers", func() {
+			ip := "100.100.100.122"
+			mesh := leakAs(b.conf.As, "127.0.0.12", 17919, "10.10.50.0")
+			defer mesh.StopBgp(context.Background(), &api.StopBgpRequest{})
+			upstream := leak("127.0.0.13", 17920)
+			defer upstream.StopBgp(context.Background(), &api.StopBgpRequest{})
+
+			Expect(b.HandleMesh([]MeshPeer{{
+				Address: "127.0.0.12",
+				As:      b.conf.As,
+				Port:    17919,
+			}})).ShouldNot(HaveOccurred())
+			defer b.HandleMesh(nil)
+			peer := &bgpapi.BgpPeer{
+				Spec: bgpapi.BgpPeerSpec{
+					Conf: &bgpapi.PeerConf{
+						PeerAs:          65010,
+						NeighborAddress: "127.0.0.13",
+					},
+					Transport: &bgpapi.Transport{
+						RemotePort: 17920,
+					},
+				},
+			}
+			Expect(b.HandleBgpPeer(peer.DeepCopy(), false)).ShouldNot(HaveOccurred())
+			defer b.HandleBgpPeer(peer.DeepCopy(), true)
+			Eventually(state("127.0.0.13"), 20*time.Second).Should(Equal(api.PeerState_ESTABLISHED))
+			Eventually(received("127.0.0.12"), 20*time.Second).Should(Equal(1))
+
+			Expect(b.setBalancer(ip, []string{"1.1.1.1"}, nil, nil)).ShouldNot(HaveOccurred())
+			defer b.DelBalancer(ip)
+
+			// The prefixes and nexthops in the global rib of the upstream peer
+			seen := func() map[string]string {
+				result := make(map[string]string)
+				upstream.ListPath(context.Background(), &api.ListPathRequest{
+					TableType: api.TableType_GLOBAL,
+					Family:    getFamily("1.1.1.1"),
+				}, func(d *api.Destination) {
+					for _, path := range d.Paths {
+						for _, attr := range path.Pattrs {
+							var nexthop api.NextHopAttribute
+							if ptypes.UnmarshalAny(attr, &nexthop) == nil {
+								result[d.Prefix] = nexthop.NextHop
+							}
+						}
+					}
+				})
+				return result
+			}
+			Eventually(seen, 10*time.Second).Should(HaveKeyWithValue(ip+"/32", "1.1.1.1"))
+
+			By("The route learned over the mesh is not sent with the speaker as nexthop")
+			var sent []string
+			Expect(b.bgpServer.ListPath(context.Background(), &api.ListPathRequest{
+				TableType: api.TableType_ADJ_OUT,
+				Name:      "127.0.0.13",
+				Family:    getFamily("1.1.1.1"),
+			}, func(d *api.Destination) {
+				sent = append(sent, d.Prefix)
+			})).ShouldNot(HaveOccurred())
+			Expect(sent).Should(ConsistOf(ip + "/32"))
+			Expect(seen()).ShouldNot(HaveKey("10.10.50.0/24"))
+		})
+	})
+
+	Context("BgpPolicies", func() {
+		policy := func(name string, spec bgpapi.BgpPolicySpec) *bgpapi.BgpPolicy {
+			p := &bgpapi.BgpPolicy{Spec: spec}
+			p.Name = name
+			return p
+		}
+		rejectLeaks := policy("reject-leaks", bgpapi.BgpPolicySpec{
+			PrefixSets: []bgpapi.PrefixSet{{
+				Name:     "leaks",
+				Prefixes: []bgpapi.Prefix{{Prefix: "10.10.40.0/24"}},
+			}},
+			Statements: []bgpapi.Statement{{
+				Conditions: &bgpapi.Conditions{
+					PrefixSet: &bgpapi.MatchSet{Name: "leaks"},
+				},
+				Actions: bgpapi.Actions{RouteAction: bgpapi.RouteActionReject},
+			}},
+		})
+		rejectAll := policy("reject-all", bgpapi.BgpPolicySpec{
+			Statements: []bgpapi.Statement{{
+				Actions: bgpapi.Actions{RouteAction: bgpapi.RouteActionReject},
+			}},
+		})
+		tag := policy("tag", bgpapi.BgpPolicySpec{
+			Statements: []bgpapi.Statement{{
+				Actions: bgpapi.Actions{
+					Community:     &bgpapi.CommunityAction{Type: bgpapi.CommunityActionAdd, Communities: []string{"65001:100"}},
+					AsPathPrepend: &bgpapi.AsPathPrepend{Repeat: 2},
+				},
+			}},
+		})
+		setConfPolicies := func(imports, exports []string) {
+			conf := &bgpapi.BgpConf{Spec: *b.conf.DeepCopy()}
+			conf.Spec.ImportPolicies = imports
+			conf.Spec.ExportPolicies = exports
+			Expect(b.HandleBgpGlobalConfig(conf, b.rack, false)).ShouldNot(HaveOccurred())
+		}
+		policies := func() []string {
+			var names []string
+			b.bgpServer.ListPolicy(context.Background(), &api.ListPolicyRequest{}, func(p *api.Policy) {
+				if strings.HasPrefix(p.Name, bgpPolicyPrefix) {
+					names = append(names, p.Name)
+				}
+			})
+			return names
+		}
+
+		It("Should apply the import policies of the peer and of the BgpConf", func() {
+			Expect(b.HandleBgpPolicy(rejectLeaks, false)).ShouldNot(HaveOccurred())
+			Expect(b.HandleBgpPolicy(rejectAll, false)).ShouldNot(HaveOccurred())
+			remote := leak("127.0.0.9", 17915, "10.10.40.0", "10.10.41.0")
+			defer remote.StopBgp(context.Background(), &api.StopBgpRequest{})
+
+			peer := &bgpapi.BgpPeer{
+				Spec: bgpapi.BgpPeerSpec{
+					Conf: &bgpapi.PeerConf{
+						PeerAs:          65010,
+						NeighborAddress: "127.0.0.9",
+					},
+					Transport: &bgpapi.Transport{
+						RemotePort: 17915,
+					},
+					ImportPolicies: []string{"reject-leaks", "missing"},
+				},
+			}
+			Expect(b.HandleBgpPeer(peer.DeepCopy(), false)).ShouldNot(HaveOccurred())
+			Eventually(state("127.0.0.9"), 20*time.Second).Should(Equal(api.PeerState_ESTABLISHED))
+			Eventually(received("127.0.0.9"), 10*time.Second).Should(Equal(1))
+			Expect(policies()).Should(ConsistOf(bgpPolicyPrefix + "reject-leaks/127.0.0.9"))
+
+			By("Rejecting the routes of all the peers in the BgpConf, but not the local ones")
+			setConfPolicies([]string{"reject-all"}, nil)
+			Eventually(received("127.0.0.9"), 10*time.Second).Should(BeZero())
+			ip := "100.100.100.120"
+			Expect(b.setBalancer(ip, []string{"1.1.1.1"}, nil, nil)).ShouldNot(HaveOccurred())
+			err, toAdd, _ := b.retriveRoutes(ip, 32, toAPIPaths(ip, 32, []string{"1.1.1.1"}, 65003, nil, nil))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(toAdd).Should(BeEmpty())
+			Expect(b.DelBalancer(ip)).ShouldNot(HaveOccurred())
+
+			By("Detaching the policies")
+			setConfPolicies(nil, nil)
+			Eventually(received("127.0.0.9"), 10*time.Second).Should(Equal(1))
+			Expect(b.HandleBgpPeer(peer.DeepCopy(), true)).ShouldNot(HaveOccurred())
+			Expect(policies()).Should(BeEmpty())
+			Expect(b.HandleBgpPolicy(rejectLeaks, true)).ShouldNot(HaveOccurred())
+			Expect(b.HandleBgpPolicy(rejectAll, true)).ShouldNot(HaveOccurred())
+		})
+
+		It("Should not let an import policy accept the routes of a peer with the default import action Reject", func() {
+			acceptAll := policy("accept-all", bgpapi.BgpPolicySpec{
+				Statements: []bgpapi.Statement{{
+					Actions: bgpapi.Actions{RouteAction: bgpapi.RouteActionAccept},
+				}},
+			})
+			Expect(b.HandleBgpPolicy(acceptAll, false)).ShouldNot(HaveOccurred())
+			remote := leak("127.0.0.14", 17921, "10.10.60.0", "10.10.61.0")
+			defer remote.StopBgp(context.Background(), &api.StopBgpRequest{})
+
+			peer := &bgpapi.BgpPeer{
+				Spec: bgpapi.BgpPeerSpec{
+					Conf: &bgpapi.PeerConf{
+						PeerAs:          65010,
+						NeighborAddress: "127.0.0.14",
+					},
+					Transport: &bgpapi.Transport{
+						RemotePort: 17921,
+					},
+					DefaultImportAction: bgpapi.ImportActionReject,
+					ImportPolicies:      []string{"accept-all"},
+				},
+			}
+			Expect(b.HandleBgpPeer(peer.DeepCopy(), false)).ShouldNot(HaveOccurred())
+			setConfPolicies([]string{"accept-all"}, nil)
+			Eventually(state("127.0.0.14"), 20*time.Second).Should(Equal(api.PeerState_ESTABLISHED))
+			Consistently(received("127.0.0.14"), 3*time.Second).Should(BeZero())
+
+			setConfPolicies(nil, nil)
+			Expect(b.HandleBgpPeer(peer.DeepCopy(), true)).ShouldNot(HaveOccurred())
+			Expect(b.HandleBgpPolicy(acceptAll, true)).ShouldNot(HaveOccurred())
+		})
+
+		It("Should apply the export policies to their peer only", func() {
+			ip := "100.100.100.121"
+			Expect(b.HandleBgpPolicy(tag, false)).ShouldNot(HaveOccurred())
+			// The routes are checked as they are sent, the remotes drop the loopback nexthop
+			sent := func(address string) func() []uint32 {
+				return func() []uint32 {
+					var result []uint32
+					b.bgpServer.ListPath(context.Background(), &api.ListPathRequest{
+						TableType: api.TableType_ADJ_OUT,
+						Name:      address,
+						Family:    &api.Family{Afi: api.Family_AFI_IP, Safi: api.Family_SAFI_UNICAST},
+						Prefixes:  []*api.TableLookupPrefix{{Prefix: ip + "/32"}},
+					}, func(d *api.Destination) {
+						for _, path := range d.Paths {
+							for _, attr := range path.Pattrs {
+								var communities api.CommunitiesAttribute
+								if ptypes.UnmarshalAny(attr, &communities) == nil {
+									result = append(result, communities.Communities...)
+								}
+								var asPath api.AsPathAttribute
+								if ptypes.UnmarshalAny(attr, &asPath) == nil {
+									result = append(result, asPath.Segments[0].Numbers...)
+								}
+							}
+						}
+					})
+					return result
+				}
+			}
+
+			for i, address := range []string{"127.0.0.10", "127.0.0.11"} {
+				port := int32(17916 + i)
+				remote := leak(address, port)
+				defer remote.StopBgp(context.Background(), &api.StopBgpRequest{})
+				peer := &bgpapi.BgpPeer{
+					Spec: bgpapi.BgpPeerSpec{
+						Conf: &bgpapi.PeerConf{
+							PeerAs:          65010,
+							NeighborAddress: address,
+						},
+						Transport: &bgpapi.Transport{
+							RemotePort: uint32(port),
+						},
+					},
+				}
+				if i == 0 {
+					peer.Spec.ExportPolicies = []string{"tag"}
+				}
+				Expect(b.HandleBgpPeer(peer.DeepCopy(), false)).ShouldNot(HaveOccurred())
+				defer b.HandleBgpPeer(peer.DeepCopy(), true)
+				Eventually(state(address), 20*time.Second).Should(Equal(api.PeerState_ESTABLISHED))
+			}
+
+			Expect(b.setBalancer(ip, []string{"1.1.1.1"}, nil, nil)).ShouldNot(HaveOccurred())
+			defer b.DelBalancer(ip)
+			Eventually(sent("127.0.0.10"), 10*time.Second).Should(Equal([]uint32{65003, 65003, 65003, 65001<<16 | 100}))
+			Eventually(sent("127.0.0.11"), 10*time.Second).Should(Equal([]uint32{65003}))
+
+			By("Deleting the policy")
+			Expect(b.HandleBgpPolicy(tag, true)).ShouldNot(HaveOccurred())
+			Eventually(sent("127.0.0.10"), 10*time.Second).Should(Equal([]uint32{65003}))
+			Expect(policies()).Should(BeEmpty())
+		})
+	})
+
+	Context("Peer groups", func() {
+		group := &bgpapi.BgpPeerGroup{
+			Spec: bgpapi.BgpPeerGroupSpec{
+				Conf: &bgpapi.PeerGroupConf{
+					PeerAs: 65010,
+				},
+				DynamicNeighbors: []string{"127.0.0.0/8"},
+			},
+		}
+		group.Name = "group1"
+		groupStatus := func() []bgpapi.NodePeerStatus {
+			for _, g := range b.HandleBgpPeerGroupStatus([]bgpapi.BgpPeerGroup{*group}) {
+				return g.Status.NodesPeerGroupStatus[util.GetNodeName()].Neighbors
+			}
+			return nil
+		}
+
+		It("Should accept the sessions of the dynamic neighbors", func() {
+			Expect(b.HandleBgpPeerGroup(group.DeepCopy(), false)).ShouldNot(HaveOccurred())
+			Expect(b.HandleBgpPeerGroup(group.DeepCopy(), false)).ShouldNot(HaveOccurred())
+
+			remote := server.NewBgpServer()
+			go remote.Serve()
+			defer remote.StopBgp(context.Background(), &api.StopBgpRequest{})
+			Expect(remote.StartBgp(context.Background(), &api.StartBgpRequest{
+				Global: &api.Global{
+					As:         65010,
+					RouterId:   "10.0.0.10",
+					ListenPort: -1,
+				},
+			})).ShouldNot(HaveOccurred())
+			Expect(remote.AddPeer(context.Background(), &api.AddPeerRequest{
+				Peer: &api.Peer{
+					Conf: &api.PeerConf{
+						NeighborAddress: "127.0.0.1",
+						PeerAs:          65003,
+					},
+					Transport: &api.Transport{
+						RemotePort: 17901,
+					},
+				},
+			})).ShouldNot(HaveOccurred())
+
+			Eventually(func() string {
+				for _, status := range groupStatus() {
+					return status.PeerState.SessionState
+				}
+				return ""
+			}, 20*time.Second).Should(Equal("ESTABLISHED"))
+
+			By("The dynamic neighbors are not deleted as useless peers")
+			b.HandleBgpPeerStatus(nil)
+			Expect(groupStatus()).Should(HaveLen(1))
+
+			By("Removing the prefix closes the sessions")
+			clone := group.DeepCopy()
+			clone.Spec.DynamicNeighbors = []string{"10.10.0.0/16"}
+			Expect(b.HandleBgpPeerGroup(clone, false)).ShouldNot(HaveOccurred())
+			Expect(groupStatus()).Should(BeEmpty())
+
+			Expect(b.HandleBgpPeerGroup(group.DeepCopy(), true)).ShouldNot(HaveOccurred())
+			Expect(b.peerGroups).ShouldNot(HaveKey(group.Name))
+		})
+
+		It("Should add the groups back when gobgp restarts", func() {
+			Expect(b.HandleBgpPeerGroup(group.DeepCopy(), false)).ShouldNot(HaveOccurred())
+			member := &bgpapi.BgpPeer{
+				Spec: bgpapi.BgpPeerSpec{
+					Conf: &bgpapi.PeerConf{
+						NeighborAddress: "192.168.0.20",
+						PeerGroup:       group.Name,
+					},
+				},
+			}
+			Expect(b.HandleBgpPeer(member, false)).ShouldNot(HaveOccurred())
+
+			remote := server.NewBgpServer()
+			go remote.Serve()
+			defer remote.StopBgp(context.Background(), &api.StopBgpRequest{})
+			Expect(remote.StartBgp(context.Background(), &api.StartBgpRequest{
+				Global: &api.Global{
+					As:         65010,
+					RouterId:   "10.0.0.10",
+					ListenPort: -1,
+				},
+			})).ShouldNot(HaveOccurred())
+			Expect(remote.AddPeer(context.Background(), &api.AddPeerRequest{
+				Peer: &api.Peer{
+					Conf: &api.PeerConf{
+						NeighborAddress: "127.0.0.1",
+						PeerAs:          65003,
+					},
+					Transport: &api.Transport{
+						RemotePort: uint32(b.conf.ListenPort),
+					},
+					Timers: &api.Timers{
+						Config: &api.TimersConfig{
+							ConnectRetry: 1,
+						},
+					},
+				},
+			})).ShouldNot(HaveOccurred())
+			established := func() string {
+				for _, status := range groupStatus() {
+					return status.PeerState.SessionState
+				}
+				return ""
+			}
+			Eventually(established, 20*time.Second).Should(Equal("ESTABLISHED"))
+
+			By("Restart gobgp with another router id")
+			conf := &bgpapi.BgpConf{Spec: *b.conf.DeepCopy()}
+			restarted := conf.DeepCopy()
+			restarted.Spec.RouterId = "10.0.255.253"
+			Expect(b.HandleBgpGlobalConfig(restarted, "", false)).ShouldNot(HaveOccurred())
+			Expect(b.peerGroups).Should(HaveKey(group.Name))
+			Expect(b.peers).Should(HaveKey("192.168.0.20"))
+			Eventually(established, 20*time.Second).Should(Equal("ESTABLISHED"))
+
+			By("Deleting the BgpConf forgets the groups")
+			Expect(b.HandleBgpGlobalConfig(conf, "", true)).ShouldNot(HaveOccurred())
+			Expect(b.peerGroups).Should(BeEmpty())
+			Expect(b.HandleBgpGlobalConfig(conf, "", false)).ShouldNot(HaveOccurred())
 		})
 	})
 })
@@ -0,0 +1,95 @@
+package bgp
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	bgpapi "github.com/openelb/openelb/api/v1alpha2"
+	"github.com/openelb/openelb/pkg/util"
+	api "github.com/osrg/gobgp/api"
+	"golang.org/x/net/context"
+)
+
+const (
+	defaultBmpPort   = 11019
+	defaultBmpPolicy = "LOCAL"
+)
+
+func toBmpRequest(server bgpapi.BmpServer) (*api.AddBmpRequest, error) {
+	if net.ParseIP(server.Address) == nil {
+		return nil, fmt.Errorf("invalid bmp server address %q", server.Address)
+	}
+	if server.RouteMonitoringPolicy == "" {
+		server.RouteMonitoringPolicy = defaultBmpPolicy
+	}
+	policy, ok := api.AddBmpRequest_MonitoringPolicy_value[server.RouteMonitoringPolicy]
+	if !ok {
+		return nil, fmt.Errorf("invalid bmp route monitoring policy %q", server.RouteMonitoringPolicy)
+	}
+
+	request := &api.AddBmpRequest{
+		Address:           server.Address,
+		Port:              server.Port,
+		Policy:            api.AddBmpRequest_MonitoringPolicy(policy),
+		StatisticsTimeout: server.StatisticsTimeout,
+		SysName:           server.SysName,
+		SysDescr:          server.SysDescr,
+	}
+	if request.Port == 0 {
+		request.Port = defaultBmpPort
+	}
+	if request.SysName == "" {
+		request.SysName = util.GetNodeName()
+	}
+	if request.SysDescr == "" {
+		request.SysDescr = "openelb speaker"
+	}
+
+	return request, nil
+}
+
+func bmpKey(request *api.AddBmpRequest) string {
+	return net.JoinHostPort(request.Address, strconv.Itoa(int(request.Port)))
+}
+
+// applyBmpServers connects gobgp to the BMP stations of servers and disconnects it from the other ones.
+// The connections survive the restarts of gobgp, but they could only be changed while it runs.
+// It must be called with the confLock held.
+func (b *Bgp) applyBmpServers(servers []bgpapi.BmpServer) error {
+	requests := make(map[string]*api.AddBmpRequest)
+	for _, server := range servers {
+		request, err := toBmpRequest(server)
+		if err != nil {
+			return err
+		}
+		requests[bmpKey(request)] = request
+	}
+
+	for key, old := range b.bmpServers {
+		if request, ok := requests[key]; ok && proto.Equal(old, request) {
+			continue
+		}
+		err := b.bgpServer.DeleteBmp(context.Background(), &api.DeleteBmpRequest{
+			Address: old.Address,
+			Port:    old.Port,
+		})
+		if err != nil {
+			return err
+		}
+		delete(b.bmpServers, key)
+	}
+
+	for key, request := range requests {
+		if _, ok := b.bmpServers[key]; ok {
+			continue
+		}
+		if err := b.bgpServer.AddBmp(context.Background(), request); err != nil {
+			return err
+		}
+		b.bmpServers[key] = request
+	}
+
+	return nil
+}
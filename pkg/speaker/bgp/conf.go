@@ -1,15 +1,44 @@
 package bgp
 
 import (
+	"reflect"
+
+	"github.com/golang/protobuf/proto"
 	bgpapi "github.com/openelb/openelb/api/v1alpha2"
 	api "github.com/osrg/gobgp/api"
 	"golang.org/x/net/context"
 )
 
+// HandleBgpGlobalConfig applies the global config to gobgp.
+// Only the settings gobgp fixes at start restart it, see needRestart, the other ones
+// are recorded without touching the sessions.
 func (b *Bgp) HandleBgpGlobalConfig(global *bgpapi.BgpConf, rack string, delete bool) error {
+	b.confLock.Lock()
+	defer b.confLock.Unlock()
+
 	b.rack = rack
 
 	if delete {
+		if err := b.applyBmpServers(nil); err != nil {
+			b.log.Error(err, "failed to disconnect from the bmp servers")
+		}
+		if err := b.deletePortForwards(); err != nil {
+			b.log.Error(err, "failed to delete port forwards")
+		}
+		b.conf = nil
+		// gobgp keeps the groups over a stop, they are added back by the reconcile of the next BgpConf
+		for name := range b.peerGroups {
+			if err := b.deletePeerGroup(name); err != nil {
+				b.log.Error(err, "failed to delete peer group", "name", name)
+			}
+		}
+		b.peers = make(map[string]*api.Peer)
+		b.peerGroups = make(map[string]*peerGroup)
+		b.extendedNexthops = make(map[string]bool)
+		b.rejectImports = make(map[string]bool)
+		b.peerPolicies = make(map[string]*peerPolicies)
+		b.appliedPolicies = nil
+		b.meshPeers = make(map[string]bool)
 		return b.bgpServer.StopBgp(context.Background(), nil)
 	}
 
@@ -18,8 +47,92 @@ func (b *Bgp) HandleBgpGlobalConfig(global *bgpapi.BgpConf, rack string, delete
 		return err
 	}
 
+	if b.conf != nil && !needRestart(b.conf, &global.Spec) {
+		if _, err := b.ready(); err == nil {
+			b.log.Info("apply global config without restart")
+			b.conf = global.Spec.DeepCopy()
+			if err = b.applyPolicies(); err != nil {
+				return err
+			}
+			return b.applyBmpServers(global.Spec.BmpServers)
+		}
+	}
+
+	graceful := gracefulRestartEnabled(b.conf)
+	b.conf = nil
+	b.appliedPolicies = nil
 	b.bgpServer.StopBgp(context.Background(), nil)
-	return b.bgpServer.StartBgp(context.Background(), &api.StartBgpRequest{
+	err = b.bgpServer.StartBgp(context.Background(), &api.StartBgpRequest{
 		Global: request,
 	})
+	if err != nil {
+		return err
+	}
+	// Exported before the nexthop self, the routes it rejects are never sent
+	if err = b.addLocalOnlyPolicy(); err != nil {
+		return err
+	}
+	if err = b.addNexthopSelfPolicy(); err != nil {
+		return err
+	}
+	if err = b.addRejectImportPolicy(); err != nil {
+		return err
+	}
+	b.conf = global.Spec.DeepCopy()
+	b.log.Info("restart bgp", "graceful", graceful)
+	// Before the peers are added, so their routes go through the policies. A failed apply
+	// is done again by the next change of a BgpPolicy or BgpPeer.
+	if err = b.applyPolicies(); err != nil {
+		b.log.Error(err, "failed to apply bgp policies")
+	}
+
+	// The peers may refer to the groups
+	b.restorePeerGroups()
+	b.restorePeers(graceful)
+
+	// The global rib is empty after the restart
+	if _, _, err = b.syncRoutes(); err != nil {
+		b.log.Error(err, "failed to restore routes")
+	}
+
+	return b.applyBmpServers(global.Spec.BmpServers)
+}
+
+// needRestart reports whether gobgp has to be restarted to change old to new.
+// AS, router-id and the listen settings identify the speaker, the families and
+// multiple paths are fixed when the global rib is created, and gobgp only reads
+// the global graceful restart settings at start.
+func needRestart(old, new *bgpapi.BgpConfSpec) bool {
+	return old.As != new.As ||
+		old.RouterId != new.RouterId ||
+		old.ListenPort != new.ListenPort ||
+		!reflect.DeepEqual(old.ListenAddresses, new.ListenAddresses) ||
+		!reflect.DeepEqual(old.Families, new.Families) ||
+		old.UseMultiplePaths != new.UseMultiplePaths ||
+		!reflect.DeepEqual(old.GracefulRestart, new.GracefulRestart)
+}
+
+func gracefulRestartEnabled(conf *bgpapi.BgpConfSpec) bool {
+	return conf != nil && conf.GracefulRestart != nil && conf.GracefulRestart.Enabled
+}
+
+// restorePeers adds the peers back after gobgp restarted. If graceful, the peers with
+// graceful restart enabled are told that we are restarting, so they keep our routes
+// until the end-of-rib and we defer our updates until theirs are received.
+// A peer failing to be added is forgotten and added again by the next reconcile of the BgpPeer.
+func (b *Bgp) restorePeers(graceful bool) {
+	for address, peer := range b.peers {
+		request := proto.Clone(peer).(*api.Peer)
+		if graceful && request.GracefulRestart != nil && request.GracefulRestart.Enabled {
+			request.GracefulRestart.LocalRestarting = true
+		}
+
+		err := b.bgpServer.AddPeer(context.Background(), &api.AddPeerRequest{
+			Peer: request,
+		})
+		if err != nil {
+			b.log.Error(err, "failed to restore peer", "address", address)
+			delete(b.peers, address)
+		}
+	}
 }
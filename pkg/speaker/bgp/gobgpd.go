@@ -1,7 +1,9 @@
 package bgp
 
 import (
+	bgpapi "github.com/openelb/openelb/api/v1alpha2"
 	"github.com/openelb/openelb/pkg/speaker"
+	"github.com/openelb/openelb/pkg/speaker/bfd"
 	api "github.com/osrg/gobgp/api"
 	"github.com/osrg/gobgp/pkg/server"
 	"golang.org/x/net/context"
@@ -10,7 +12,10 @@ import (
 	"sync"
 )
 
-var _ speaker.Speaker = &Bgp{}
+var (
+	_ speaker.Speaker = &Bgp{}
+	_ speaker.Lister  = &Bgp{}
+)
 
 func NewGoBgpd(bgpOptions *BgpOptions) *Bgp {
 	maxSize := 4 << 20 //4MB
@@ -18,10 +23,31 @@ func NewGoBgpd(bgpOptions *BgpOptions) *Bgp {
 
 	bgpServer := server.NewBgpServer(server.GrpcListenAddress(bgpOptions.GrpcHosts), server.GrpcOption(grpcOpts))
 
-	return &Bgp{
-		bgpServer: bgpServer,
-		log:       ctrl.Log.WithName("bgpserver"),
+	b := &Bgp{
+		bgpServer:        bgpServer,
+		log:              ctrl.Log.WithName("bgpserver"),
+		peers:            make(map[string]*api.Peer),
+		peerGroups:       make(map[string]*peerGroup),
+		extendedNexthops: make(map[string]bool),
+		localNexthops:    make(map[string]bool),
+		rejectImports:    make(map[string]bool),
+		bgpPolicies:      make(map[string]*bgpapi.BgpPolicySpec),
+		peerPolicies:     make(map[string]*peerPolicies),
+		meshPeers:        make(map[string]bool),
+		bmpServers:       make(map[string]*api.AddBmpRequest),
+		portForwards:     make(map[string]*portForward),
+		routes:           make(map[string]*route),
+		peerEvents:       make(chan PeerStateEvent, peerEventsSize),
+		peerStates:       make(map[string]api.PeerState_SessionState),
+		routeSyncPeriod:  bgpOptions.RouteSyncPeriod,
+		mrtDumpFile:      bgpOptions.MrtDumpFile,
+		mrtDumpInterval:  bgpOptions.MrtDumpInterval,
 	}
+	if bgpOptions.BfdPort > 0 {
+		b.bfd = bfd.NewManager(bgpOptions.BfdPort, bfd.DefaultPort)
+	}
+
+	return b
 }
 
 func (b *Bgp) run(stopCh <-chan struct{}) {
@@ -31,6 +57,11 @@ func (b *Bgp) run(stopCh <-chan struct{}) {
 	go b.bgpServer.Serve()
 	<-stopCh
 	log.Info("gobgpd ending")
+	b.confLock.Lock()
+	if err := b.deletePortForwards(); err != nil {
+		log.Error(err, "failed to delete port forwards")
+	}
+	b.confLock.Unlock()
 	err := b.bgpServer.StopBgp(context.Background(), &api.StopBgpRequest{})
 	if err != nil {
 		log.Error(err, "failed to stop gobgpd")
@@ -39,6 +70,19 @@ func (b *Bgp) run(stopCh <-chan struct{}) {
 
 func (b *Bgp) Start(stopCh <-chan struct{}) error {
 	go b.run(stopCh)
+	b.watchPeerState(stopCh)
+	if b.bfd != nil {
+		// Peers without bfd are not affected, so keep bgp running
+		if err := b.bfd.Start(stopCh); err != nil {
+			b.log.Error(err, "failed to start bfd")
+		}
+	}
+	if b.routeSyncPeriod > 0 {
+		go b.runRouteSync(stopCh)
+	}
+	if b.mrtDumpFile != "" && b.mrtDumpInterval > 0 {
+		go b.runMRTDump(b.mrtDumpFile, b.mrtDumpInterval, stopCh)
+	}
 	return nil
 }
 
@@ -0,0 +1,148 @@
+package bgp
+
+import (
+	api "github.com/osrg/gobgp/api"
+	"golang.org/x/net/context"
+)
+
+const (
+	// rejectImportPolicy is imported from all the peers right after acceptLocalPolicy, the routes
+	// received from the neighbors in rejectImportSet are rejected before any BgpPolicy applies.
+	rejectImportPolicy = "openelb-reject-import"
+	rejectImportSet    = "openelb-reject-import"
+	// noNeighborPrefix keeps rejectImportSet from being empty, gobgp matches every path with an empty set
+	noNeighborPrefix = "0.0.0.0/32"
+	// acceptLocalPolicy is imported first, the routes of the Eips go through the import policy
+	// of gobgp too and must not be rejected by those meant for the peers
+	acceptLocalPolicy = "openelb-accept-local"
+)
+
+// addRejectImportPolicy must be called after gobgp starts, which resets the policies.
+func (b *Bgp) addRejectImportPolicy() error {
+	list := []string{noNeighborPrefix}
+	for address := range b.rejectImports {
+		prefix, err := hostPrefix(address)
+		if err != nil {
+			return err
+		}
+		list = append(list, prefix)
+	}
+
+	err := b.bgpServer.AddDefinedSet(context.Background(), &api.AddDefinedSetRequest{
+		DefinedSet: &api.DefinedSet{
+			DefinedType: api.DefinedType_NEIGHBOR,
+			Name:        rejectImportSet,
+			List:        list,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	policy := &api.Policy{
+		Name: rejectImportPolicy,
+		Statements: []*api.Statement{
+			{
+				Conditions: &api.Conditions{
+					NeighborSet: &api.MatchSet{
+						MatchType: api.MatchType_ANY,
+						Name:      rejectImportSet,
+					},
+				},
+				Actions: &api.Actions{
+					RouteAction: api.RouteAction_REJECT,
+				},
+			},
+		},
+	}
+	err = b.bgpServer.AddPolicy(context.Background(), &api.AddPolicyRequest{
+		Policy: policy,
+	})
+	if err != nil {
+		return err
+	}
+
+	acceptLocal := &api.Policy{
+		Name: acceptLocalPolicy,
+		Statements: []*api.Statement{
+			{
+				Conditions: &api.Conditions{
+					RouteType: api.Conditions_ROUTE_TYPE_LOCAL,
+				},
+				Actions: &api.Actions{
+					RouteAction: api.RouteAction_ACCEPT,
+				},
+			},
+		},
+	}
+	err = b.bgpServer.AddPolicy(context.Background(), &api.AddPolicyRequest{
+		Policy: acceptLocal,
+	})
+	if err != nil {
+		return err
+	}
+
+	return b.bgpServer.AddPolicyAssignment(context.Background(), &api.AddPolicyAssignmentRequest{
+		Assignment: &api.PolicyAssignment{
+			Name:          globalPolicyAssignment,
+			Direction:     api.PolicyDirection_IMPORT,
+			Policies:      []*api.Policy{acceptLocal, policy},
+			DefaultAction: api.RouteAction_ACCEPT,
+		},
+	})
+}
+
+// setRejectImport adds or removes the neighbor address in rejectImportSet, and applies the import
+// policy again to the routes received from the neighbor. It must be called with the confLock held.
+func (b *Bgp) setRejectImport(address string, enabled bool) error {
+	if b.rejectImports[address] == enabled {
+		return nil
+	}
+
+	prefix, err := hostPrefix(address)
+	if err != nil {
+		return err
+	}
+	// The set is added with the recorded addresses when gobgp starts
+	if b.conf == nil {
+		if enabled {
+			b.rejectImports[address] = true
+		} else {
+			delete(b.rejectImports, address)
+		}
+		return nil
+	}
+
+	set := &api.DefinedSet{
+		DefinedType: api.DefinedType_NEIGHBOR,
+		Name:        rejectImportSet,
+		List:        []string{prefix},
+	}
+	if enabled {
+		err = b.bgpServer.AddDefinedSet(context.Background(), &api.AddDefinedSetRequest{
+			DefinedSet: set,
+		})
+	} else {
+		err = b.bgpServer.DeleteDefinedSet(context.Background(), &api.DeleteDefinedSetRequest{
+			DefinedSet: set,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	if enabled {
+		b.rejectImports[address] = true
+	} else {
+		delete(b.rejectImports, address)
+	}
+
+	// The peer is not there yet if it is being added
+	b.bgpServer.ResetPeer(context.Background(), &api.ResetPeerRequest{
+		Address:   address,
+		Soft:      true,
+		Direction: api.ResetPeerRequest_IN,
+	})
+
+	return nil
+}
@@ -0,0 +1,194 @@
+package bgp
+
+import (
+	"fmt"
+	"net"
+
+	bgpapi "github.com/openelb/openelb/api/v1alpha2"
+	api "github.com/osrg/gobgp/api"
+	"golang.org/x/net/context"
+)
+
+const (
+	// localOnlyPolicy is exported first to all the peers, the peers out of meshPeerSet are only sent
+	// the routes of the Eips. gobgp would send them the routes learned over the mesh with itself as
+	// nexthop, drawing the traffic to this node instead of the nodes of the endpoints.
+	localOnlyPolicy = "openelb-local-only"
+	meshPeerSet     = "openelb-mesh-peers"
+)
+
+// MeshPeer is another speaker of the cluster to peer with.
+type MeshPeer struct {
+	Address string
+	As      uint32
+	Port    uint32
+	// ReflectorClient is set on the route reflectors for the speakers they reflect the routes to
+	ReflectorClient bool
+	ClusterId       string
+}
+
+func (p MeshPeer) toGoBgpPeer(afiSafis []*bgpapi.AfiSafi) (*api.Peer, error) {
+	spec := bgpapi.BgpPeerSpec{
+		Conf: &bgpapi.PeerConf{
+			NeighborAddress: p.Address,
+			PeerAs:          p.As,
+		},
+		Transport: &bgpapi.Transport{
+			RemotePort: p.Port,
+		},
+		AfiSafis: afiSafis,
+	}
+	request, err := spec.ToGoBgpPeer()
+	if err != nil {
+		return nil, err
+	}
+	if p.ReflectorClient {
+		request.RouteReflector = &api.RouteReflector{
+			RouteReflectorClient:    true,
+			RouteReflectorClusterId: p.ClusterId,
+		}
+	}
+
+	return request, nil
+}
+
+// HandleMesh replaces the sessions with the other speakers by peers. They are kept
+// by the status sync of the BgpPeers and restored with them after gobgp restarts.
+func (b *Bgp) HandleMesh(peers []MeshPeer) error {
+	b.confLock.Lock()
+	defer b.confLock.Unlock()
+
+	requests := make(map[string]*api.Peer)
+	for _, peer := range peers {
+		ip := net.ParseIP(peer.Address)
+		if ip == nil {
+			return fmt.Errorf("invalid mesh peer address %q", peer.Address)
+		}
+		request, err := peer.toGoBgpPeer(b.defaultAfiSafis(defaultFamily(ip)))
+		if err != nil {
+			return err
+		}
+		requests[ip.String()] = request
+	}
+
+	for address := range b.meshPeers {
+		if _, ok := requests[address]; ok {
+			continue
+		}
+		b.forgetPeer(address)
+		b.bgpServer.DeletePeer(context.Background(), &api.DeletePeerRequest{
+			Address: address,
+		})
+		if err := b.setMeshPeer(address, false); err != nil {
+			return err
+		}
+	}
+
+	for address, request := range requests {
+		// Before the session comes up, so the peer is sent the routes of the mesh
+		if err := b.setMeshPeer(address, true); err != nil {
+			return err
+		}
+		if err := b.applyPeer(address, request); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addLocalOnlyPolicy must be called after gobgp starts, which resets the policies.
+func (b *Bgp) addLocalOnlyPolicy() error {
+	list := []string{noNeighborPrefix}
+	for address := range b.meshPeers {
+		prefix, err := hostPrefix(address)
+		if err != nil {
+			return err
+		}
+		list = append(list, prefix)
+	}
+
+	err := b.bgpServer.AddDefinedSet(context.Background(), &api.AddDefinedSetRequest{
+		DefinedSet: &api.DefinedSet{
+			DefinedType: api.DefinedType_NEIGHBOR,
+			Name:        meshPeerSet,
+			List:        list,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	policy := &api.Policy{
+		Name: localOnlyPolicy,
+	}
+	for _, routeType := range []api.Conditions_RouteType{api.Conditions_ROUTE_TYPE_INTERNAL, api.Conditions_ROUTE_TYPE_EXTERNAL} {
+		policy.Statements = append(policy.Statements, &api.Statement{
+			Conditions: &api.Conditions{
+				NeighborSet: &api.MatchSet{
+					MatchType: api.MatchType_INVERT,
+					Name:      meshPeerSet,
+				},
+				RouteType: routeType,
+			},
+			Actions: &api.Actions{
+				RouteAction: api.RouteAction_REJECT,
+			},
+		})
+	}
+	err = b.bgpServer.AddPolicy(context.Background(), &api.AddPolicyRequest{
+		Policy: policy,
+	})
+	if err != nil {
+		return err
+	}
+
+	return b.bgpServer.AddPolicyAssignment(context.Background(), &api.AddPolicyAssignmentRequest{
+		Assignment: &api.PolicyAssignment{
+			Name:          globalPolicyAssignment,
+			Direction:     api.PolicyDirection_EXPORT,
+			Policies:      []*api.Policy{policy},
+			DefaultAction: api.RouteAction_ACCEPT,
+		},
+	})
+}
+
+// setMeshPeer adds or removes the neighbor address in meshPeerSet. It must be called with the confLock held.
+func (b *Bgp) setMeshPeer(address string, enabled bool) error {
+	if b.meshPeers[address] == enabled {
+		return nil
+	}
+
+	prefix, err := hostPrefix(address)
+	if err != nil {
+		return err
+	}
+	// The set is added with the recorded addresses when gobgp starts
+	if b.conf != nil {
+		set := &api.DefinedSet{
+			DefinedType: api.DefinedType_NEIGHBOR,
+			Name:        meshPeerSet,
+			List:        []string{prefix},
+		}
+		if enabled {
+			err = b.bgpServer.AddDefinedSet(context.Background(), &api.AddDefinedSetRequest{
+				DefinedSet: set,
+			})
+		} else {
+			err = b.bgpServer.DeleteDefinedSet(context.Background(), &api.DeleteDefinedSetRequest{
+				DefinedSet: set,
+			})
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if enabled {
+		b.meshPeers[address] = true
+	} else {
+		delete(b.meshPeers, address)
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package bgp
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/openelb/openelb/pkg/util"
+	api "github.com/osrg/gobgp/api"
+	bgppacket "github.com/osrg/gobgp/pkg/packet/bgp"
+	"github.com/osrg/gobgp/pkg/packet/mrt"
+	"golang.org/x/net/context"
+)
+
+// mrtFamilies are the families of the global rib dumped and the MRT subtypes of their RIB records.
+var mrtFamilies = []struct {
+	family  *api.Family
+	subtype mrt.MRTSubTypeTableDumpv2
+}{
+	{&api.Family{Afi: api.Family_AFI_IP, Safi: api.Family_SAFI_UNICAST}, mrt.RIB_IPV4_UNICAST},
+	{&api.Family{Afi: api.Family_AFI_IP6, Safi: api.Family_SAFI_UNICAST}, mrt.RIB_IPV6_UNICAST},
+}
+
+func writeMRT(w io.Writer, timestamp uint32, subtype mrt.MRTSubTypeTableDumpv2, body mrt.Body) error {
+	msg, err := mrt.NewMRTMessage(timestamp, mrt.TABLE_DUMPv2, subtype, body)
+	if err != nil {
+		return err
+	}
+	data, err := msg.Serialize()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+
+	return err
+}
+
+// toRibEntry decodes the binary nlri and attributes of path, ListPath must enable both of them.
+func toRibEntry(path *api.Path, index uint16) (bgppacket.AddrPrefixInterface, *mrt.RibEntry, error) {
+	nlri, err := bgppacket.NewPrefixFromRouteFamily(uint16(path.Family.Afi), uint8(path.Family.Safi))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = nlri.DecodeFromBytes(path.NlriBinary); err != nil {
+		return nil, nil, err
+	}
+
+	attrs := make([]bgppacket.PathAttributeInterface, 0, len(path.PattrsBinary))
+	for _, data := range path.PattrsBinary {
+		attr, err := bgppacket.GetPathAttribute(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err = attr.DecodeFromBytes(data); err != nil {
+			return nil, nil, err
+		}
+		attrs = append(attrs, attr)
+	}
+
+	var originated uint32
+	if age, err := ptypes.Timestamp(path.Age); err == nil {
+		originated = uint32(age.Unix())
+	}
+
+	return nlri, mrt.NewRibEntry(index, originated, 0, attrs, false), nil
+}
+
+// DumpMRT writes a TABLE_DUMP_V2 snapshot of the IPv4 and IPv6 unicast global rib to w as described
+// in RFC 6396. The locally originated paths are from the peer at index 0 of the peer index table.
+func (b *Bgp) DumpMRT(w io.Writer) error {
+	global, err := b.ready()
+	if err != nil {
+		return err
+	}
+	timestamp := uint32(time.Now().Unix())
+
+	peers := []*mrt.Peer{mrt.NewPeer(global.RouterId, "0.0.0.0", global.As, true)}
+	indexes := make(map[string]uint16)
+	err = b.bgpServer.ListPeer(context.Background(), &api.ListPeerRequest{}, func(peer *api.Peer) {
+		if peer.State == nil || peer.State.NeighborAddress == "" {
+			return
+		}
+		routerID := peer.State.RouterId
+		if routerID == "" {
+			routerID = "0.0.0.0"
+		}
+		indexes[peer.State.NeighborAddress] = uint16(len(peers))
+		peers = append(peers, mrt.NewPeer(routerID, peer.State.NeighborAddress, peer.State.PeerAs, true))
+	})
+	if err != nil {
+		return err
+	}
+	err = writeMRT(w, timestamp, mrt.PEER_INDEX_TABLE, mrt.NewPeerIndexTable(global.RouterId, util.GetNodeName(), peers))
+	if err != nil {
+		return err
+	}
+
+	seq := uint32(0)
+	for _, f := range mrtFamilies {
+		var destinations []*api.Destination
+		err = b.bgpServer.ListPath(context.Background(), &api.ListPathRequest{
+			TableType:             api.TableType_GLOBAL,
+			Family:                f.family,
+			EnableNlriBinary:      true,
+			EnableAttributeBinary: true,
+		}, func(d *api.Destination) {
+			destinations = append(destinations, d)
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, d := range destinations {
+			var prefix bgppacket.AddrPrefixInterface
+			entries := make([]*mrt.RibEntry, 0, len(d.Paths))
+			for _, path := range d.Paths {
+				nlri, entry, err := toRibEntry(path, indexes[path.NeighborIp])
+				if err != nil {
+					return fmt.Errorf("failed to dump path of %s, %v", d.Prefix, err)
+				}
+				prefix = nlri
+				entries = append(entries, entry)
+			}
+			if prefix == nil {
+				continue
+			}
+
+			if err = writeMRT(w, timestamp, f.subtype, mrt.NewRib(seq, prefix, entries)); err != nil {
+				return err
+			}
+			seq++
+		}
+	}
+
+	return nil
+}
+
+// MRTHandler returns the handler streaming DumpMRT over http.
+func (b *Bgp) MRTHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := b.ready(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=rib.%s.%s.mrt",
+			util.GetNodeName(), time.Now().UTC().Format("20060102.1504")))
+		if err := b.DumpMRT(w); err != nil {
+			// The status is sent already, the truncated dump is all that could be told
+			b.log.Error(err, "failed to dump mrt")
+		}
+	})
+}
+
+// dumpMRTFile replaces file with a new dump, so that it always holds a complete one.
+func (b *Bgp) dumpMRTFile(file string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = b.DumpMRT(tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), file)
+}
+
+// runMRTDump dumps the global rib to file every period, the last dump is kept for post-mortems.
+func (b *Bgp) runMRTDump(file string, period time.Duration, stopCh <-chan struct{}) {
+	t := time.NewTicker(period)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			if _, err := b.ready(); err != nil {
+				continue
+			}
+			if err := b.dumpMRTFile(file); err != nil {
+				b.log.Error(err, "failed to dump mrt", "file", file)
+			}
+
+		case <-stopCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package bgp
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+
+	api "github.com/osrg/gobgp/api"
+	"golang.org/x/net/context"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// nexthopSelfPolicy is exported to all the peers, the neighbors in extendedNexthopSet are only sent
+	// the routes through this node, with the local address of the session as nexthop. The routes through
+	// the other nodes would get it too, drawing their traffic to this node.
+	nexthopSelfPolicy  = "openelb-nexthop-self"
+	extendedNexthopSet = "openelb-extended-nexthop"
+	// linkLocalPrefix matches the sessions over interfaces, only an address on the link is a valid nexthop there
+	linkLocalPrefix = "fe80::/10"
+	// globalPolicyAssignment is the name of the policies applied to the peers that are not route server clients
+	globalPolicyAssignment = "global"
+)
+
+func hostPrefix(address string) (string, error) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return "", fmt.Errorf("invalid neighbor address %s", address)
+	}
+	if ip.To4() != nil {
+		return ip.String() + "/32", nil
+	}
+
+	return ip.String() + "/128", nil
+}
+
+// addNexthopSelfPolicy must be called after gobgp starts, which resets the policies.
+func (b *Bgp) addNexthopSelfPolicy() error {
+	list := []string{linkLocalPrefix}
+	for address := range b.extendedNexthops {
+		prefix, err := hostPrefix(address)
+		if err != nil {
+			return err
+		}
+		list = append(list, prefix)
+	}
+
+	err := b.bgpServer.AddDefinedSet(context.Background(), &api.AddDefinedSetRequest{
+		DefinedSet: &api.DefinedSet{
+			DefinedType: api.DefinedType_NEIGHBOR,
+			Name:        extendedNexthopSet,
+			List:        list,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	policy := b.nexthopSelfPolicy()
+	err = b.bgpServer.AddPolicy(context.Background(), &api.AddPolicyRequest{
+		Policy: policy,
+	})
+	if err != nil {
+		return err
+	}
+
+	return b.bgpServer.AddPolicyAssignment(context.Background(), &api.AddPolicyAssignmentRequest{
+		Assignment: &api.PolicyAssignment{
+			Name:          globalPolicyAssignment,
+			Direction:     api.PolicyDirection_EXPORT,
+			Policies:      []*api.Policy{policy},
+			DefaultAction: api.RouteAction_ACCEPT,
+		},
+	})
+}
+
+// nexthopSelfPolicy returns the policy for the nexthops of this node recorded in localNexthops.
+// gobgp has no inverted nexthop condition, so the other nexthops are matched by the prefixes
+// covering every other address of their family. The nexthop conditions see the nexthop of the
+// path before the nexthop self.
+func (b *Bgp) nexthopSelfPolicy() *api.Policy {
+	extended := &api.MatchSet{
+		MatchType: api.MatchType_ANY,
+		Name:      extendedNexthopSet,
+	}
+	policy := &api.Policy{
+		Name: nexthopSelfPolicy,
+	}
+
+	locals := make([]string, 0, len(b.localNexthops))
+	for nexthop := range b.localNexthops {
+		locals = append(locals, nexthop)
+	}
+	sort.Strings(locals)
+	var rejected []string
+	v4, v6 := false, false
+	for _, nexthop := range locals {
+		ip := net.ParseIP(nexthop)
+		if ip.To4() != nil {
+			v4 = true
+		} else {
+			v6 = true
+		}
+		rejected = append(rejected, otherAddresses(ip)...)
+	}
+	if !v4 {
+		rejected = append(rejected, "0.0.0.0/0")
+	}
+	if !v6 {
+		rejected = append(rejected, "::/0")
+	}
+	// An empty list matches every path
+	if len(locals) > 0 {
+		policy.Statements = append(policy.Statements, &api.Statement{
+			Conditions: &api.Conditions{
+				NeighborSet:   extended,
+				NextHopInList: locals,
+			},
+			Actions: &api.Actions{
+				Nexthop: &api.NexthopAction{
+					Self: true,
+				},
+			},
+		})
+	}
+	policy.Statements = append(policy.Statements, &api.Statement{
+		Conditions: &api.Conditions{
+			NeighborSet:   extended,
+			NextHopInList: rejected,
+		},
+		Actions: &api.Actions{
+			RouteAction: api.RouteAction_REJECT,
+		},
+	})
+
+	return policy
+}
+
+// otherAddresses returns the prefixes matching every address of the family of ip but ip.
+func otherAddresses(ip net.IP) []string {
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 8*net.IPv4len
+	}
+
+	result := make([]string, 0, bits)
+	for i := 0; i < bits; i++ {
+		other := make(net.IP, len(ip))
+		copy(other, ip)
+		other[i/8] ^= 0x80 >> uint(i%8)
+		mask := net.CIDRMask(i+1, bits)
+		result = append(result, (&net.IPNet{IP: other.Mask(mask), Mask: mask}).String())
+	}
+
+	return result
+}
+
+// nodeNextHops returns the nexthops of the routes through node, one per family.
+func (b *Bgp) nodeNextHops(node corev1.Node) []string {
+	var nexthops []string
+	for _, ip := range []string{net.IPv4zero.String(), net.IPv6zero.String()} {
+		if nexthop, err := b.getNodeNextHop(node, ip); err == nil {
+			nexthops = append(nexthops, nexthop)
+		}
+	}
+
+	return nexthops
+}
+
+// setLocalNexthops records the nexthops of the routes through this node, and replaces nexthopSelfPolicy
+// if they changed. gobgp refuses to delete the policy in use, so it is detached first.
+func (b *Bgp) setLocalNexthops(nexthops []string) error {
+	b.confLock.Lock()
+	defer b.confLock.Unlock()
+
+	locals := make(map[string]bool, len(nexthops))
+	for _, nexthop := range nexthops {
+		locals[nexthop] = true
+	}
+	if reflect.DeepEqual(locals, b.localNexthops) {
+		return nil
+	}
+	b.localNexthops = locals
+	// The policy is added with the recorded nexthops when gobgp starts
+	if b.conf == nil {
+		return nil
+	}
+
+	var imports, exports []string
+	if b.appliedPolicies != nil {
+		imports, exports = b.appliedPolicies.imports, b.appliedPolicies.exports
+	}
+	err := b.bgpServer.SetPolicyAssignment(context.Background(), &api.SetPolicyAssignmentRequest{
+		Assignment: &api.PolicyAssignment{
+			Name:          globalPolicyAssignment,
+			Direction:     api.PolicyDirection_EXPORT,
+			Policies:      []*api.Policy{{Name: localOnlyPolicy}},
+			DefaultAction: api.RouteAction_ACCEPT,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	err = b.bgpServer.DeletePolicy(context.Background(), &api.DeletePolicyRequest{
+		Policy: &api.Policy{Name: nexthopSelfPolicy},
+		All:    true,
+	})
+	if err != nil {
+		return err
+	}
+	err = b.bgpServer.AddPolicy(context.Background(), &api.AddPolicyRequest{
+		Policy: b.nexthopSelfPolicy(),
+	})
+	if err != nil {
+		return err
+	}
+	if err = b.setPolicyAssignments(imports, exports); err != nil {
+		return err
+	}
+
+	b.bgpServer.ResetPeer(context.Background(), &api.ResetPeerRequest{
+		Address:   "all",
+		Soft:      true,
+		Direction: api.ResetPeerRequest_OUT,
+	})
+
+	return nil
+}
+
+// setExtendedNexthop adds or removes the neighbor address in extendedNexthopSet, and sends the routes
+// again to the neighbor with the new nexthop. It must be called with the confLock held.
+func (b *Bgp) setExtendedNexthop(address string, enabled bool) error {
+	if b.extendedNexthops[address] == enabled {
+		return nil
+	}
+
+	prefix, err := hostPrefix(address)
+	if err != nil {
+		return err
+	}
+	// The set is added with the recorded addresses when gobgp starts
+	if b.conf == nil {
+		if enabled {
+			b.extendedNexthops[address] = true
+		} else {
+			delete(b.extendedNexthops, address)
+		}
+		return nil
+	}
+
+	set := &api.DefinedSet{
+		DefinedType: api.DefinedType_NEIGHBOR,
+		Name:        extendedNexthopSet,
+		List:        []string{prefix},
+	}
+	if enabled {
+		err = b.bgpServer.AddDefinedSet(context.Background(), &api.AddDefinedSetRequest{
+			DefinedSet: set,
+		})
+	} else {
+		err = b.bgpServer.DeleteDefinedSet(context.Background(), &api.DeleteDefinedSetRequest{
+			DefinedSet: set,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	if enabled {
+		b.extendedNexthops[address] = true
+	} else {
+		delete(b.extendedNexthops, address)
+	}
+
+	// The peer is not there yet if it is being added
+	b.bgpServer.ResetPeer(context.Background(), &api.ResetPeerRequest{
+		Address:   address,
+		Soft:      true,
+		Direction: api.ResetPeerRequest_OUT,
+	})
+
+	return nil
+}
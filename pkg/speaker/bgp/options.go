@@ -1,27 +1,96 @@
 package bgp
 
 import (
+	"fmt"
+	"sync"
+	"time"
+
 	"github.com/go-logr/logr"
+	bgpapi "github.com/openelb/openelb/api/v1alpha2"
+	"github.com/openelb/openelb/pkg/nettool/iptables"
+	"github.com/openelb/openelb/pkg/speaker/bfd"
+	api "github.com/osrg/gobgp/api"
 	"github.com/osrg/gobgp/pkg/server"
 	"github.com/spf13/pflag"
 )
 
 type BgpOptions struct {
-	GrpcHosts string `long:"api-hosts" description:"specify the hosts that gobgpd listens on" default:":50051"`
+	GrpcHosts       string        `long:"api-hosts" description:"specify the hosts that gobgpd listens on" default:":50051"`
+	RouteSyncPeriod time.Duration `long:"route-sync-period" description:"specify the period of reconciling the services and comparing the global rib with their routes" default:"1m"`
+	BfdPort         int           `long:"bfd-port" description:"specify the port that bfd control packets are received on, 3784 by RFC 5881" default:"0"`
+	MrtDumpFile     string        `long:"mrt-dump-file" description:"specify the file the global rib is periodically dumped to in MRT format"`
+	MrtDumpInterval time.Duration `long:"mrt-dump-interval" description:"specify the period of dumping the global rib to the mrt dump file" default:"10m"`
 }
 
 func NewBgpOptions() *BgpOptions {
 	return &BgpOptions{
-		GrpcHosts: ":50051",
+		GrpcHosts:       ":50051",
+		RouteSyncPeriod: time.Minute,
+		MrtDumpInterval: 10 * time.Minute,
 	}
 }
 
 func (options *BgpOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&options.GrpcHosts, "api-hosts", options.GrpcHosts, "specify the hosts that gobgpd listens on")
+	fs.IntVar(&options.BfdPort, "bfd-port", options.BfdPort, fmt.Sprintf("specify the port that bfd control packets are received on, %d by RFC 5881, 0 disables bfd", bfd.DefaultPort))
+	fs.DurationVar(&options.RouteSyncPeriod, "route-sync-period", options.RouteSyncPeriod, "specify the period of reconciling the services and comparing the global rib with their routes, 0 disables it")
+	fs.StringVar(&options.MrtDumpFile, "mrt-dump-file", options.MrtDumpFile, "specify the file the global rib is periodically dumped to in MRT format, empty disables it")
+	fs.DurationVar(&options.MrtDumpInterval, "mrt-dump-interval", options.MrtDumpInterval, "specify the period of dumping the global rib to the mrt dump file")
 }
 
 type Bgp struct {
 	bgpServer *server.BgpServer
 	rack      string
 	log       logr.Logger
+
+	lock sync.Mutex
+	// families of the Eips announced through bgp, enabled on the peers without afiSafis
+	eipFamilies []*bgpapi.Family
+
+	// confLock serializes the changes of the global config with the changes of the peers
+	confLock sync.Mutex
+	// conf is the global config gobgp runs with, nil if stopped
+	conf *bgpapi.BgpConfSpec
+	// peers are the requests of the peers added to gobgp, keyed by neighbor address
+	peers map[string]*api.Peer
+	// extendedNexthops are the neighbor addresses of the peers with extended nexthop
+	extendedNexthops map[string]bool
+	// localNexthops are the nexthops of the routes through this node, kept over the restarts of gobgp
+	localNexthops map[string]bool
+	// rejectImports are the neighbor addresses of the peers whose routes are rejected
+	rejectImports map[string]bool
+	// meshPeers are the neighbor addresses of the other speakers peered with over iBGP
+	meshPeers map[string]bool
+	// bmpServers are the requests of the BMP stations gobgp is connected to, keyed by host:port
+	bmpServers map[string]*api.AddBmpRequest
+	// portForwards are the DNATs of port 179 to the listen port, keyed by neighbor address
+	portForwards map[string]*portForward
+	// ipt runs the port forwards, created with the first one
+	ipt iptables.IptablesIface
+	// peerGroups are the peer groups added to gobgp, keyed by name, added back when gobgp restarts
+	peerGroups map[string]*peerGroup
+	// bgpPolicies are the specs of the BgpPolicies keyed by name, they survive the restarts of gobgp
+	bgpPolicies map[string]*bgpapi.BgpPolicySpec
+	// peerPolicies are the BgpPolicies attached to the peers, keyed by neighbor address
+	peerPolicies map[string]*peerPolicies
+	// appliedPolicies are the BgpPolicies in gobgp, nil until gobgp starts
+	appliedPolicies *policyState
+
+	// peerEvents are the sessions entering or leaving the established state
+	peerEvents chan PeerStateEvent
+	// stateLock guards peerStates, the last session states seen keyed by neighbor address or interface
+	stateLock  sync.Mutex
+	peerStates map[string]api.PeerState_SessionState
+
+	// bfd runs the sessions of the peers with bfd enabled, nil if disabled
+	bfd *bfd.Manager
+
+	// routeLock serializes the changes of the global rib with the route sync
+	routeLock       sync.Mutex
+	routes          map[string]*route
+	routeSyncPeriod time.Duration
+
+	// mrtDumpFile is the file the global rib is dumped to every mrtDumpInterval, empty if disabled
+	mrtDumpFile     string
+	mrtDumpInterval time.Duration
 }
@@ -4,12 +4,18 @@ import (
 	"context"
 	"fmt"
 	"hash/fnv"
+	"math"
 	"net"
+	"sort"
+	"strings"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/any"
+	"github.com/openelb/openelb/api/v1alpha2"
 	"github.com/openelb/openelb/pkg/constant"
 	"github.com/openelb/openelb/pkg/metrics"
+	"github.com/openelb/openelb/pkg/speaker"
 	"github.com/openelb/openelb/pkg/util"
 	api "github.com/osrg/gobgp/api"
 	bgppacket "github.com/osrg/gobgp/pkg/packet/bgp"
@@ -23,6 +29,19 @@ func getPathIdentifier(nexthop string) uint32 {
 	return h.Sum32()
 }
 
+// parsePrefix splits ip into the announced prefix, a plain address is a host route.
+func parsePrefix(ip string) (string, uint32) {
+	if _, cidr, err := net.ParseCIDR(ip); err == nil {
+		ones, _ := cidr.Mask.Size()
+		return cidr.IP.String(), uint32(ones)
+	}
+	if net.ParseIP(ip).To4() == nil {
+		return ip, 128
+	}
+
+	return ip, 32
+}
+
 func getFamily(ip string) *api.Family {
 	family := &api.Family{
 		Afi:  api.Family_AFI_IP,
@@ -38,7 +57,10 @@ func getFamily(ip string) *api.Family {
 	return family
 }
 
-func toAPIPath(ip string, prefix uint32, nexthop string) *api.Path {
+// toAPIPath builds the path announced for ip through nexthop. A non-zero weight is
+// encoded as a link-bandwidth extended community so that upstream routers could do
+// weighted ECMP between nexthops, extra are the optional attributes of the Eip/Service.
+func toAPIPath(ip string, prefix uint32, nexthop string, as, weight uint32, extra []*any.Any) *api.Path {
 	nlri, _ := ptypes.MarshalAny(&api.IPAddressPrefix{
 		Prefix:    ip,
 		PrefixLen: prefix,
@@ -50,6 +72,10 @@ func toAPIPath(ip string, prefix uint32, nexthop string) *api.Path {
 		NextHop: nexthop,
 	})
 	attrs := []*any.Any{a1, a2}
+	if weight > 0 {
+		attrs = append(attrs, linkBandwidth(as, weight))
+	}
+	attrs = append(attrs, extra...)
 
 	return &api.Path{
 		Family:     getFamily(ip),
@@ -59,6 +85,58 @@ func toAPIPath(ip string, prefix uint32, nexthop string) *api.Path {
 	}
 }
 
+func linkBandwidth(as, weight uint32) *any.Any {
+	if as > 0xffff {
+		as = bgppacket.AS_TRANS
+	}
+	lb, _ := ptypes.MarshalAny(&api.TwoOctetAsSpecificExtended{
+		IsTransitive: false,
+		SubType:      uint32(bgppacket.EC_SUBTYPE_LINK_BANDWIDTH),
+		As:           as,
+		LocalAdmin:   math.Float32bits(float32(weight)),
+	})
+	a, _ := ptypes.MarshalAny(&api.ExtendedCommunitiesAttribute{
+		Communities: []*any.Any{lb},
+	})
+	return a
+}
+
+// toAPIPaths builds the desired paths of ip keyed by nexthop.
+func toAPIPaths(ip string, prefix uint32, nexthops []string, as uint32, weights map[string]uint32, extra []*any.Any) map[string]*api.Path {
+	paths := make(map[string]*api.Path, len(nexthops))
+	for _, nexthop := range nexthops {
+		paths[nexthop] = toAPIPath(ip, prefix, nexthop, as, weights[nexthop], extra)
+	}
+
+	return paths
+}
+
+// getPathAttributes returns the attributes of path other than the nexthop in a canonical form,
+// two paths with the same result announce the same thing.
+func getPathAttributes(path *api.Path) string {
+	var attrs []string
+	for _, attr := range path.Pattrs {
+		var value ptypes.DynamicAny
+
+		if ptypes.UnmarshalAny(attr, &value) != nil {
+			continue
+		}
+
+		switch value.Message.(type) {
+		case *api.NextHopAttribute, *api.MpReachNLRIAttribute:
+			continue
+		case *api.AsPathAttribute:
+			if len(value.Message.(*api.AsPathAttribute).Segments) == 0 {
+				continue
+			}
+		}
+		attrs = append(attrs, proto.CompactTextString(value.Message))
+	}
+	sort.Strings(attrs)
+
+	return strings.Join(attrs, ";")
+}
+
 func fromAPIPath(path *api.Path) net.IP {
 	for _, attr := range path.Pattrs {
 		var value ptypes.DynamicAny
@@ -68,43 +146,47 @@ func fromAPIPath(path *api.Path) net.IP {
 		switch a := value.Message.(type) {
 		case *api.NextHopAttribute:
 			return net.ParseIP(a.NextHop)
+		case *api.MpReachNLRIAttribute:
+			// v6 paths carry the nexthop in MP_REACH_NLRI
+			if len(a.NextHops) > 0 {
+				return net.ParseIP(a.NextHops[0])
+			}
 		}
 	}
 
 	return nil
 }
 
-func (b *Bgp) retriveRoutes(ip string, prefix uint32, nexthops []string) (err error, toAdd, toDelete []string) {
+// retriveRoutes compares the paths of ip in the global rib with the desired paths keyed by nexthop.
+// A nexthop whose attributes changed is returned in toAdd, adding it again replaces the path.
+func (b *Bgp) retriveRoutes(ip string, prefix uint32, paths map[string]*api.Path) (err error, toAdd, toDelete []string) {
 	listPathRequest := &api.ListPathRequest{
 		TableType: api.TableType_GLOBAL,
 		Family:    getFamily(ip),
 		Prefixes: []*api.TableLookupPrefix{
 			&api.TableLookupPrefix{
-				Prefix: ip,
+				// A plain address would match the longest prefix, like the aggregate of the Eip
+				Prefix: fmt.Sprintf("%s/%d", ip, prefix),
 			},
 		},
 	}
 
-	origins := make(map[string]bool)
-	news := make(map[string]bool)
-	for _, item := range nexthops {
-		news[item] = true
-	}
+	origins := make(map[string]string)
 	found := false
 	fn := func(d *api.Destination) {
 		found = true
 		for _, path := range d.Paths {
 			nexthop := fromAPIPath(path)
-			origins[nexthop.String()] = true
+			origins[nexthop.String()] = getPathAttributes(path)
 		}
 		//compare
 		for key := range origins {
-			if _, ok := news[key]; !ok {
+			if _, ok := paths[key]; !ok {
 				toDelete = append(toDelete, key)
 			}
 		}
-		for key := range news {
-			if _, ok := origins[key]; !ok {
+		for key, path := range paths {
+			if attrs, ok := origins[key]; !ok || attrs != getPathAttributes(path) {
 				toAdd = append(toAdd, key)
 			}
 		}
@@ -115,38 +197,58 @@ func (b *Bgp) retriveRoutes(ip string, prefix uint32, nexthops []string) (err er
 		return
 	}
 	if !found {
-		toAdd = nexthops
+		for key := range paths {
+			toAdd = append(toAdd, key)
+		}
 	}
 
 	return
 }
 
-func (b *Bgp) ready() error {
+func (b *Bgp) ready() (*api.Global, error) {
 	response, err := b.bgpServer.GetBgp(context.Background(), nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if response.Global.As == 0 {
-		return fmt.Errorf("Bgp not ready, please config bgpconf/bgppeer")
+		return nil, fmt.Errorf("Bgp not ready, please config bgpconf/bgppeer")
 	}
 
-	return nil
+	return response.Global, nil
 }
 
-func (b *Bgp) setBalancer(ip string, nexthops []string) error {
-	err := b.ready()
+func (b *Bgp) setBalancer(ip string, nexthops []string, weights map[string]uint32, attrs *v1alpha2.PathAttributes) error {
+	b.routeLock.Lock()
+	defer b.routeLock.Unlock()
+
+	ip, prefix := parsePrefix(ip)
+	// Remember the intent even if bgp is not ready, the route sync announces it later
+	b.routes[routeKey(ip, prefix)] = &route{
+		ip:       ip,
+		prefix:   prefix,
+		nexthops: nexthops,
+		weights:  weights,
+		attrs:    attrs,
+	}
+
+	global, err := b.ready()
 	if err != nil {
 		return err
 	}
 
-	prefix := uint32(32)
-	err, toAdd, toDelete := b.retriveRoutes(ip, prefix, nexthops)
+	extra, err := attrs.ToGoBgpPathAttributes(global.As)
 	if err != nil {
 		return err
 	}
 
-	err = b.addMultiRoutes(ip, prefix, toAdd)
+	paths := toAPIPaths(ip, prefix, nexthops, global.As, weights, extra)
+	err, toAdd, toDelete := b.retriveRoutes(ip, prefix, paths)
+	if err != nil {
+		return err
+	}
+
+	err = b.addMultiRoutes(paths, toAdd)
 	if err != nil {
 		return err
 	}
@@ -178,43 +280,72 @@ func (b *Bgp) getPeers() []*api.Peer {
 	return peerList
 }
 
-func (b *Bgp) SetBalancer(ip string, nodes []corev1.Node) error {
+// SetBalancer announces ip through the nodes of the rack of this speaker. The nodes without an
+// InternalIP in the family of ip are skipped, it only fails if none of them is left.
+func (b *Bgp) SetBalancer(ip string, nodes []corev1.Node, options *speaker.BalancerOptions) error {
 	var nexthops []string
+	var skipped error
+	weights := make(map[string]uint32)
+	addr, _ := parsePrefix(ip)
 
 	for _, node := range nodes {
+		if node.Name == util.GetNodeName() {
+			if err := b.setLocalNexthops(b.nodeNextHops(node)); err != nil {
+				return err
+			}
+		}
 		rack := ""
 		if node.Labels != nil {
 			rack = node.Labels[constant.OpenELBNodeRack]
 		}
 		if rack == b.rack || b.rack == "" {
-			nexthop, err := b.getNodeNextHop(node)
+			nexthop, err := b.getNodeNextHop(node, addr)
 			if err != nil {
-				return err
+				b.log.Info("skip the node as nexthop", "ip", ip, "reason", err.Error())
+				skipped = err
+				continue
 			}
 			nexthops = append(nexthops, nexthop)
+			if options != nil {
+				weights[nexthop] = options.Weights[node.Name]
+			}
 		}
 	}
 
-	ctrl.Log.Info("bgp setBalancer", "nexthops", nexthops)
+	if len(nexthops) == 0 && skipped != nil {
+		return skipped
+	}
+
+	var attrs *v1alpha2.PathAttributes
+	if options != nil {
+		attrs = options.PathAttributes
+	}
+
+	ctrl.Log.Info("bgp setBalancer", "nexthops", nexthops, "weights", weights, "attributes", attrs)
 
-	return b.setBalancer(ip, nexthops)
+	return b.setBalancer(ip, nexthops, weights, attrs)
 }
 
-func (b *Bgp) getNodeNextHop(node corev1.Node) (string, error) {
+// getNodeNextHop returns the first InternalIP of the node in the same family as ip.
+func (b *Bgp) getNodeNextHop(node corev1.Node, ip string) (string, error) {
+	v4 := net.ParseIP(ip).To4() != nil
 	for _, addr := range node.Status.Addresses {
-		if addr.Type == corev1.NodeInternalIP {
+		if addr.Type != corev1.NodeInternalIP {
+			continue
+		}
+		nexthop := net.ParseIP(addr.Address)
+		if nexthop != nil && (nexthop.To4() != nil) == v4 {
 			return addr.Address, nil
 		}
 	}
 
-	return "", fmt.Errorf("node has no internal ip")
+	return "", fmt.Errorf("node %s has no internal ip in the family of %s", node.Name, ip)
 }
 
-func (b *Bgp) addMultiRoutes(ip string, prefix uint32, nexthops []string) error {
+func (b *Bgp) addMultiRoutes(paths map[string]*api.Path, nexthops []string) error {
 	for _, nexthop := range nexthops {
-		apipath := toAPIPath(ip, prefix, nexthop)
 		_, err := b.bgpServer.AddPath(context.Background(), &api.AddPathRequest{
-			Path: apipath,
+			Path: paths[nexthop],
 		})
 		if err != nil {
 			return err
@@ -225,7 +356,7 @@ func (b *Bgp) addMultiRoutes(ip string, prefix uint32, nexthops []string) error
 
 func (b *Bgp) deleteMultiRoutes(ip string, prefix uint32, nexthops []string) error {
 	for _, nexthop := range nexthops {
-		apipath := toAPIPath(ip, prefix, nexthop)
+		apipath := toAPIPath(ip, prefix, nexthop, 0, 0, nil)
 		err := b.bgpServer.DeletePath(context.Background(), &api.DeletePathRequest{
 			Path: apipath,
 		})
@@ -236,14 +367,37 @@ func (b *Bgp) deleteMultiRoutes(ip string, prefix uint32, nexthops []string) err
 	return nil
 }
 
+// Balancers returns the ips of the host routes and the prefixes of the other routes.
+func (b *Bgp) Balancers() []string {
+	b.routeLock.Lock()
+	defer b.routeLock.Unlock()
+
+	var result []string
+	for key, r := range b.routes {
+		if _, prefix := parsePrefix(r.ip); prefix == r.prefix {
+			result = append(result, r.ip)
+			continue
+		}
+		result = append(result, key)
+	}
+
+	return result
+}
+
 func (b *Bgp) DelBalancer(ip string) error {
-	err := b.ready()
+	b.routeLock.Lock()
+	defer b.routeLock.Unlock()
+
+	ip, prefix := parsePrefix(ip)
+	delete(b.routes, routeKey(ip, prefix))
+
+	_, err := b.ready()
 	if err != nil {
 		return err
 	}
 
 	lookup := &api.TableLookupPrefix{
-		Prefix: ip,
+		Prefix: fmt.Sprintf("%s/%d", ip, prefix),
 	}
 	listPathRequest := &api.ListPathRequest{
 		TableType: api.TableType_GLOBAL,
@@ -257,8 +411,11 @@ func (b *Bgp) DelBalancer(ip string) error {
 			existPath = false
 		}
 		for _, path := range d.Paths {
+			// The NLRI inside MP_REACH_NLRI of a listed v6 path has no identifier, rebuild the path instead
+			withdraw := toAPIPath(ip, prefix, fromAPIPath(path).String(), 0, 0, nil)
+			withdraw.Identifier = path.Identifier
 			errDelete = b.bgpServer.DeletePath(context.Background(), &api.DeletePathRequest{
-				Path: path,
+				Path: withdraw,
 			})
 			if errDelete != nil {
 				return
@@ -0,0 +1,266 @@
+package bgp
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	bgpapi "github.com/openelb/openelb/api/v1alpha2"
+	"github.com/openelb/openelb/pkg/util"
+	api "github.com/osrg/gobgp/api"
+	"golang.org/x/net/context"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// peerGroup is the request of a peer group added to gobgp and the prefixes of its dynamic neighbors.
+type peerGroup struct {
+	request  *api.PeerGroup
+	prefixes []string
+}
+
+// neighborAddress returns the address of a peer, the dynamic neighbors only have it in the state.
+func neighborAddress(peer *api.Peer) string {
+	if peer.Conf.NeighborAddress != "" {
+		return peer.Conf.NeighborAddress
+	}
+	if peer.State != nil {
+		return peer.State.NeighborAddress
+	}
+
+	return ""
+}
+
+// isDynamicNeighbor must be called with the confLock held.
+func (b *Bgp) isDynamicNeighbor(peer *api.Peer) bool {
+	if peer.Conf == nil || peer.Conf.PeerGroup == "" {
+		return false
+	}
+	_, ok := b.peerGroups[peer.Conf.PeerGroup]
+	_, static := b.peers[neighborAddress(peer)]
+
+	return ok && !static
+}
+
+// HandleBgpPeerGroup adds the peer group to gobgp and accepts the sessions from its dynamic neighbors.
+// gobgp could not remove the prefix of a dynamic neighbor, so the group is added again when one is removed.
+func (b *Bgp) HandleBgpPeerGroup(group *bgpapi.BgpPeerGroup, delete bool) error {
+	b.confLock.Lock()
+	defer b.confLock.Unlock()
+
+	name := group.Name
+	if delete {
+		return b.deletePeerGroup(name)
+	}
+
+	prefixes := make([]string, 0, len(group.Spec.DynamicNeighbors))
+	for _, prefix := range group.Spec.DynamicNeighbors {
+		_, ipNet, err := net.ParseCIDR(prefix)
+		if err != nil {
+			return fmt.Errorf("field Spec.DynamicNeighbors invalid, %v", err)
+		}
+		prefixes = append(prefixes, ipNet.String())
+	}
+	sort.Strings(prefixes)
+
+	// set default afisafi
+	if len(group.Spec.AfiSafis) == 0 {
+		ip := net.IPv4zero
+		if len(prefixes) > 0 {
+			ip, _, _ = net.ParseCIDR(prefixes[0])
+		}
+		group.Spec.AfiSafis = b.defaultAfiSafis(defaultFamily(ip))
+	}
+
+	request, err := group.Spec.ToGoBgpPeerGroup(name)
+	if err != nil {
+		return err
+	}
+
+	old, ok := b.peerGroups[name]
+	if ok && proto.Equal(old.request, request) && reflect.DeepEqual(old.prefixes, prefixes) {
+		return nil
+	}
+
+	if ok && !containsAll(prefixes, old.prefixes) {
+		if err = b.deletePeerGroup(name); err != nil {
+			return err
+		}
+		ok = false
+	}
+
+	if ok {
+		_, err = b.bgpServer.UpdatePeerGroup(context.Background(), &api.UpdatePeerGroupRequest{
+			PeerGroup: request,
+		})
+	} else {
+		err = b.bgpServer.AddPeerGroup(context.Background(), &api.AddPeerGroupRequest{
+			PeerGroup: request,
+		})
+	}
+	if err != nil {
+		return err
+	}
+	record := &peerGroup{request: request}
+	if ok {
+		record.prefixes = old.prefixes
+	}
+	b.peerGroups[name] = record
+
+	// AddDynamicNeighbor must only be called after the group is added
+	for _, prefix := range prefixes {
+		if contains(record.prefixes, prefix) {
+			continue
+		}
+
+		err = b.bgpServer.AddDynamicNeighbor(context.Background(), &api.AddDynamicNeighborRequest{
+			DynamicNeighbor: &api.DynamicNeighbor{
+				Prefix:    prefix,
+				PeerGroup: name,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		record.prefixes = append(record.prefixes, prefix)
+	}
+	sort.Strings(record.prefixes)
+
+	return nil
+}
+
+// deletePeerGroup closes the sessions of the dynamic neighbors, gobgp refuses to delete a group in use.
+// It must be called with the confLock held.
+func (b *Bgp) deletePeerGroup(name string) error {
+	if _, ok := b.peerGroups[name]; !ok {
+		return nil
+	}
+
+	var members []*api.Peer
+	err := b.bgpServer.ListPeer(context.Background(), &api.ListPeerRequest{}, func(peer *api.Peer) {
+		if b.isDynamicNeighbor(peer) && peer.Conf.PeerGroup == name {
+			members = append(members, peer)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		b.bgpServer.DeletePeer(context.Background(), &api.DeletePeerRequest{
+			Address: neighborAddress(member),
+		})
+	}
+
+	err = b.bgpServer.DeletePeerGroup(context.Background(), &api.DeletePeerGroupRequest{
+		Name: name,
+	})
+	if err != nil {
+		return err
+	}
+	delete(b.peerGroups, name)
+
+	return nil
+}
+
+// restorePeerGroups adds the peer groups and their dynamic neighbors back after gobgp restarted.
+// gobgp may keep the groups over a restart, they are replaced so they match the records.
+// A group failing to be added is forgotten and added again by the next reconcile of the BgpPeerGroup.
+// It must be called with the confLock held.
+func (b *Bgp) restorePeerGroups() {
+	for name, group := range b.peerGroups {
+		b.bgpServer.DeletePeerGroup(context.Background(), &api.DeletePeerGroupRequest{
+			Name: name,
+		})
+		err := b.bgpServer.AddPeerGroup(context.Background(), &api.AddPeerGroupRequest{
+			PeerGroup: group.request,
+		})
+		if err == nil {
+			// AddDynamicNeighbor must only be called after the group is added
+			for _, prefix := range group.prefixes {
+				err = b.bgpServer.AddDynamicNeighbor(context.Background(), &api.AddDynamicNeighborRequest{
+					DynamicNeighbor: &api.DynamicNeighbor{
+						Prefix:    prefix,
+						PeerGroup: name,
+					},
+				})
+				if err != nil {
+					b.bgpServer.DeletePeerGroup(context.Background(), &api.DeletePeerGroupRequest{
+						Name: name,
+					})
+					break
+				}
+			}
+		}
+		if err != nil {
+			b.log.Error(err, "failed to restore peer group", "name", name)
+			delete(b.peerGroups, name)
+		}
+	}
+}
+
+func contains(s []string, x string) bool {
+	for _, y := range s {
+		if x == y {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsAll(s []string, sub []string) bool {
+	for _, x := range sub {
+		if !contains(s, x) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// HandleBgpPeerGroupStatus returns the groups with the sessions of their dynamic neighbors on this node.
+func (b *Bgp) HandleBgpPeerGroupStatus(groups []bgpapi.BgpPeerGroup) []*bgpapi.BgpPeerGroup {
+	neighbors := make(map[string][]bgpapi.NodePeerStatus)
+
+	b.confLock.Lock()
+	b.bgpServer.ListPeer(context.Background(), &api.ListPeerRequest{}, func(peer *api.Peer) {
+		if !b.isDynamicNeighbor(peer) {
+			return
+		}
+
+		tmp, err := bgpapi.GetStatusFromGoBgpPeer(peer)
+		if err != nil {
+			ctrl.Log.Error(err, "failed to ConverStatusFromGoBgpPeer", "peer", peer)
+			return
+		}
+		tmp.BfdState = b.bfdState(tmp.PeerState.NeighborAddress)
+		neighbors[peer.Conf.PeerGroup] = append(neighbors[peer.Conf.PeerGroup], tmp)
+	})
+	b.confLock.Unlock()
+
+	var result []*bgpapi.BgpPeerGroup
+	for _, group := range groups {
+		clone := group.DeepCopy()
+		if clone.Status.NodesPeerGroupStatus == nil {
+			clone.Status.NodesPeerGroupStatus = make(map[string]bgpapi.NodePeerGroupStatus)
+		}
+
+		status, ok := neighbors[group.Name]
+		if !ok {
+			delete(clone.Status.NodesPeerGroupStatus, util.GetNodeName())
+			result = append(result, clone)
+			continue
+		}
+
+		sort.Slice(status, func(i, j int) bool {
+			return status[i].PeerState.NeighborAddress < status[j].PeerState.NeighborAddress
+		})
+		clone.Status.NodesPeerGroupStatus[util.GetNodeName()] = bgpapi.NodePeerGroupStatus{
+			Neighbors: status,
+		}
+		result = append(result, clone)
+	}
+
+	return result
+}
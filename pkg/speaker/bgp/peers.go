@@ -5,6 +5,7 @@ import (
 	"net"
 	"strconv"
 
+	"github.com/golang/protobuf/proto"
 	bgpapi "github.com/openelb/openelb/api/v1alpha2"
 	"github.com/openelb/openelb/pkg/metrics"
 	"github.com/openelb/openelb/pkg/util"
@@ -28,6 +29,52 @@ func defaultFamily(ip net.IP) *bgpapi.Family {
 	return family
 }
 
+// SetEipFamilies records the families of the Eips in use, they take effect on the next HandleBgpPeer.
+func (b *Bgp) SetEipFamilies(families []*bgpapi.Family) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.eipFamilies = families
+}
+
+// defaultAfiSafis enables the unicast families of the session, and the families of the Eips
+// so that v6 routes could be advertised over a v4 session and vice versa.
+func (b *Bgp) defaultAfiSafis(sessionFamilies ...*bgpapi.Family) []*bgpapi.AfiSafi {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	families := sessionFamilies
+	for _, family := range b.eipFamilies {
+		found := false
+		for _, f := range families {
+			if *f == *family {
+				found = true
+				break
+			}
+		}
+		if !found {
+			families = append(families, family)
+		}
+	}
+
+	var afiSafis []*bgpapi.AfiSafi
+	for _, family := range families {
+		afiSafis = append(afiSafis, &bgpapi.AfiSafi{
+			Config: &bgpapi.AfiSafiConfig{
+				Family:  family,
+				Enabled: true,
+			},
+			AddPaths: &bgpapi.AddPaths{
+				Config: &bgpapi.AddPathsConfig{
+					SendMax: 10,
+				},
+			},
+		})
+	}
+
+	return afiSafis
+}
+
 func (b *Bgp) HandleBgpPeerStatus(bgpPeers []bgpapi.BgpPeer) []*bgpapi.BgpPeer {
 	var (
 		result []*bgpapi.BgpPeer
@@ -44,7 +91,7 @@ func (b *Bgp) HandleBgpPeerStatus(bgpPeers []bgpapi.BgpPeer) []*bgpapi.BgpPeer {
 		var found *bgpapi.BgpPeer
 
 		for _, bgpPeer := range bgpPeers {
-			if bgpPeer.Spec.Conf.NeighborAddress == tmp.PeerState.NeighborAddress {
+			if matchPeer(&bgpPeer, peer) {
 				found = &bgpPeer
 				break
 			}
@@ -58,9 +105,9 @@ func (b *Bgp) HandleBgpPeerStatus(bgpPeers []bgpapi.BgpPeer) []*bgpapi.BgpPeer {
 				clone.Status.NodesPeerStatus = make(map[string]bgpapi.NodePeerStatus)
 			}
 
-			if clone.Spec.Conf.NeighborAddress == tmp.PeerState.NeighborAddress {
-				clone.Status.NodesPeerStatus[util.GetNodeName()] = tmp
-			}
+			// The neighbor address learned over the interface is reported in the peer state
+			tmp.BfdState = b.bfdState(tmp.PeerState.NeighborAddress)
+			clone.Status.NodesPeerStatus[util.GetNodeName()] = tmp
 
 			result = append(result, clone)
 		}
@@ -69,8 +116,24 @@ func (b *Bgp) HandleBgpPeerStatus(bgpPeers []bgpapi.BgpPeer) []*bgpapi.BgpPeer {
 		Address: "",
 	}, fn)
 
+	b.confLock.Lock()
+	defer b.confLock.Unlock()
 	for _, del := range dels {
+		// The dynamic neighbors belong to their BgpPeerGroup, the mesh peers to the BgpConf
+		if b.isDynamicNeighbor(del) || b.meshPeers[peerKey(del)] {
+			continue
+		}
 		ctrl.Log.Info("delete useless bgp peer", "peer", del)
+		b.forgetPeer(peerKey(del))
+		if del.Conf.NeighborInterface == "" {
+			b.setExtendedNexthop(del.Conf.NeighborAddress, false)
+			b.setPortForward(del.Conf.NeighborAddress, nil)
+			b.setRejectImport(del.Conf.NeighborAddress, false)
+			b.setPeerPolicies(del.Conf.NeighborAddress, nil)
+		}
+		if b.bfd != nil {
+			b.bfd.DeleteSession(del.Conf.NeighborAddress)
+		}
 		b.bgpServer.DeletePeer(context.Background(), &api.DeletePeerRequest{
 			Address:   del.Conf.NeighborAddress,
 			Interface: del.Conf.NeighborInterface,
@@ -80,6 +143,15 @@ func (b *Bgp) HandleBgpPeerStatus(bgpPeers []bgpapi.BgpPeer) []*bgpapi.BgpPeer {
 	return result
 }
 
+// matchPeer reports whether peer of gobgp is the session of bgpPeer.
+func matchPeer(bgpPeer *bgpapi.BgpPeer, peer *api.Peer) bool {
+	if bgpPeer.Spec.Conf.NeighborInterface != "" {
+		return bgpPeer.Spec.Conf.NeighborInterface == peer.Conf.NeighborInterface
+	}
+
+	return peer.Conf.NeighborInterface == "" && bgpPeer.Spec.Conf.NeighborAddress == peer.State.NeighborAddress
+}
+
 func (b *Bgp) GetBgpConfStatus() bgpapi.BgpConf {
 	result, err := b.bgpServer.GetBgp(context.Background(), nil)
 	if err != nil {
@@ -108,50 +180,120 @@ func (b *Bgp) GetBgpConfStatus() bgpapi.BgpConf {
 }
 
 func (b *Bgp) HandleBgpPeer(neighbor *bgpapi.BgpPeer, delete bool) error {
+	b.confLock.Lock()
+	defer b.confLock.Unlock()
+
 	// set default afisafi
+	unnumbered := neighbor.Spec.Conf.NeighborInterface != ""
 	if len(neighbor.Spec.AfiSafis) == 0 {
-		ip := net.ParseIP(neighbor.Spec.Conf.NeighborAddress)
-		if ip == nil {
-			return fmt.Errorf("field Spec.Conf.NeighborAddress invalid")
+		if unnumbered {
+			// The session runs over the IPv6 link-local addresses, IPv4 is carried by extended nexthop
+			neighbor.Spec.AfiSafis = b.defaultAfiSafis(defaultFamily(net.IPv6unspecified), defaultFamily(net.IPv4zero))
+		} else {
+			ip := net.ParseIP(neighbor.Spec.Conf.NeighborAddress)
+			if ip == nil {
+				return fmt.Errorf("field Spec.Conf.NeighborAddress invalid")
+			}
+			neighbor.Spec.AfiSafis = b.defaultAfiSafis(defaultFamily(ip))
 		}
-		neighbor.Spec.AfiSafis = append(neighbor.Spec.AfiSafis, &bgpapi.AfiSafi{
-			Config: &bgpapi.AfiSafiConfig{
-				Family:  defaultFamily(ip),
-				Enabled: true,
-			},
-			AddPaths: &bgpapi.AddPaths{
-				Config: &bgpapi.AddPathsConfig{
-					SendMax: 10,
-				},
-			},
-		})
 	}
 
 	request, e := neighbor.Spec.ToGoBgpPeer()
 	if e != nil {
 		return e
 	}
+	reject := !delete && neighbor.Spec.DefaultImportAction == bgpapi.ImportActionReject
+	if unnumbered && reject {
+		return fmt.Errorf("field Spec.DefaultImportAction Reject is not supported over interfaces")
+	}
+	var policies *peerPolicies
+	if !delete && (len(neighbor.Spec.ImportPolicies) > 0 || len(neighbor.Spec.ExportPolicies) > 0) {
+		if unnumbered {
+			return fmt.Errorf("field Spec.ImportPolicies and Spec.ExportPolicies are not supported over interfaces")
+		}
+		policies = &peerPolicies{
+			imports: neighbor.Spec.ImportPolicies,
+			exports: neighbor.Spec.ExportPolicies,
+		}
+	}
 
 	b.UpdatePeerMetrics(neighbor, delete)
+	if e = b.handleBfd(neighbor, delete); e != nil {
+		return e
+	}
+
+	address := peerKey(request)
+	if !unnumbered {
+		// Before the peer is added, so no route of it gets in
+		if e = b.setRejectImport(address, reject); e != nil {
+			return e
+		}
+		if e = b.setPeerPolicies(address, policies); e != nil {
+			return e
+		}
+		extendedNexthop := !delete && neighbor.Spec.ExtendedNexthop != nil && *neighbor.Spec.ExtendedNexthop
+		if e = b.setExtendedNexthop(address, extendedNexthop); e != nil {
+			return e
+		}
+		forward, e := b.desiredPortForward(neighbor, delete)
+		if e != nil {
+			return e
+		}
+		if e = b.setPortForward(address, forward); e != nil {
+			return e
+		}
+	}
 	if delete {
+		b.forgetPeer(address)
 		b.bgpServer.DeletePeer(context.Background(), &api.DeletePeerRequest{
 			Address:   request.Conf.NeighborAddress,
 			Interface: request.Conf.NeighborInterface,
 		})
 	} else {
-		_, e = b.bgpServer.UpdatePeer(context.Background(), &api.UpdatePeerRequest{
+		return b.applyPeer(address, request)
+	}
+
+	return nil
+}
+
+// applyPeer adds or updates the peer in gobgp. It must be called with the confLock held.
+func (b *Bgp) applyPeer(address string, request *api.Peer) error {
+	// gobgp compares an update with the defaulted config of the peer, so even an unchanged
+	// request would reset the session
+	if old, ok := b.peers[address]; ok && proto.Equal(old, request) {
+		return nil
+	}
+
+	_, err := b.bgpServer.UpdatePeer(context.Background(), &api.UpdatePeerRequest{
+		Peer: request,
+	})
+	if err != nil {
+		err = b.bgpServer.AddPeer(context.Background(), &api.AddPeerRequest{
 			Peer: request,
 		})
-		if e != nil {
-			return b.bgpServer.AddPeer(context.Background(), &api.AddPeerRequest{
-				Peer: request,
-			})
+		if err != nil {
+			return err
 		}
 	}
+	b.peers[address] = request
 
 	return nil
 }
 
+// peerKey identifies a peer in gobgp, the peers over interfaces have no neighbor address in their config.
+func peerKey(peer *api.Peer) string {
+	if peer.Conf.NeighborInterface != "" {
+		return peer.Conf.NeighborInterface
+	}
+
+	return peer.Conf.NeighborAddress
+}
+
+// forgetPeer must be called with the confLock held.
+func (b *Bgp) forgetPeer(address string) {
+	delete(b.peers, address)
+}
+
 func (b *Bgp) UpdatePeerMetrics(peer *bgpapi.BgpPeer, delete bool) {
 	status := peer.Status
 	for node, peerStatus := range status.NodesPeerStatus {
@@ -0,0 +1,296 @@
+package bgp
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	bgpapi "github.com/openelb/openelb/api/v1alpha2"
+	api "github.com/osrg/gobgp/api"
+	"golang.org/x/net/context"
+)
+
+// bgpPolicyPrefix names the gobgp policies of the BgpPolicies, openelb-policy/<name> is applied
+// to all the peers and openelb-policy/<name>/<address> to the peer of the address only.
+const bgpPolicyPrefix = "openelb-policy/"
+
+// peerPolicies are the names of the BgpPolicies attached to a BgpPeer.
+type peerPolicies struct {
+	imports []string
+	exports []string
+}
+
+// policyState is what the attached BgpPolicies are translated into in gobgp.
+type policyState struct {
+	sets     []*api.DefinedSet
+	policies []*api.Policy
+	// imports and exports are the names of the policies in the global assignments, in order
+	imports []string
+	exports []string
+	// partial is set while the state is being applied, so a failed apply is done again
+	partial bool
+}
+
+func (s *policyState) equal(o *policyState) bool {
+	if s.partial || o.partial || len(s.sets) != len(o.sets) || len(s.policies) != len(o.policies) {
+		return false
+	}
+	for i := range s.sets {
+		if !proto.Equal(s.sets[i], o.sets[i]) {
+			return false
+		}
+	}
+	for i := range s.policies {
+		if !proto.Equal(s.policies[i], o.policies[i]) {
+			return false
+		}
+	}
+
+	return equalStrings(s.imports, o.imports) && equalStrings(s.exports, o.exports)
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// HandleBgpPolicy records the BgpPolicy and applies it again where it is attached.
+func (b *Bgp) HandleBgpPolicy(policy *bgpapi.BgpPolicy, delete bool) error {
+	b.confLock.Lock()
+	defer b.confLock.Unlock()
+
+	if delete {
+		b.forgetPolicy(policy.Name)
+	} else {
+		b.bgpPolicies[policy.Name] = policy.Spec.DeepCopy()
+	}
+
+	return b.applyPolicies()
+}
+
+// forgetPolicy must be called with the confLock held.
+func (b *Bgp) forgetPolicy(name string) {
+	delete(b.bgpPolicies, name)
+}
+
+// setPeerPolicies records the BgpPolicies attached to the peer of the address, nil detaches all of them.
+// It must be called with the confLock held.
+func (b *Bgp) setPeerPolicies(address string, policies *peerPolicies) error {
+	if policies == nil || len(policies.imports) == 0 && len(policies.exports) == 0 {
+		if _, ok := b.peerPolicies[address]; !ok {
+			return nil
+		}
+		delete(b.peerPolicies, address)
+	} else {
+		b.peerPolicies[address] = policies
+	}
+
+	return b.applyPolicies()
+}
+
+// desiredPolicies translates the BgpPolicies attached to the peers, then those attached to the BgpConf.
+// The policies of a peer are restricted to its neighbor address, as gobgp only assigns policies per
+// peer to the route server clients. A missing or invalid BgpPolicy is skipped until it is fixed.
+// It must be called with the confLock held.
+func (b *Bgp) desiredPolicies() *policyState {
+	state := &policyState{}
+	added := make(map[string]bool)
+	attach := func(list []string, names []string, address string) []string {
+		for _, name := range names {
+			policyName := bgpPolicyPrefix + name
+			if address != "" {
+				policyName += "/" + address
+			}
+			if contains(list, policyName) {
+				continue
+			}
+
+			if !added[policyName] {
+				spec, ok := b.bgpPolicies[name]
+				if !ok {
+					b.log.Info("BgpPolicy not found, skip it", "name", name, "peer", address)
+					continue
+				}
+				sets, policy, err := spec.ToGoBgpPolicy(policyName, b.conf.As)
+				if err != nil {
+					b.log.Error(err, "BgpPolicy invalid, skip it", "name", name)
+					continue
+				}
+				if address != "" {
+					sets, policy = restrictToNeighbor(sets, policy, address)
+				}
+				state.sets = append(state.sets, sets...)
+				state.policies = append(state.policies, policy)
+				added[policyName] = true
+			}
+			list = append(list, policyName)
+		}
+		return list
+	}
+
+	addresses := make([]string, 0, len(b.peerPolicies))
+	for address := range b.peerPolicies {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		state.imports = attach(state.imports, b.peerPolicies[address].imports, address)
+		state.exports = attach(state.exports, b.peerPolicies[address].exports, address)
+	}
+	state.imports = attach(state.imports, b.conf.ImportPolicies, "")
+	state.exports = attach(state.exports, b.conf.ExportPolicies, "")
+
+	return state
+}
+
+// restrictToNeighbor makes the statements of policy only match the routes of the neighbor of address.
+// The neighbor conditions of the statements are known for the address, so the statements not matching
+// it are dropped and the other ones get the neighbor set of the address, named as the policy.
+func restrictToNeighbor(sets []*api.DefinedSet, policy *api.Policy, address string) ([]*api.DefinedSet, *api.Policy) {
+	ip := net.ParseIP(address)
+	prefix, _ := hostPrefix(address)
+	neighbors := make(map[string]bool)
+	for _, set := range sets {
+		if set.DefinedType != api.DefinedType_NEIGHBOR {
+			continue
+		}
+		for _, n := range set.List {
+			if _, ipNet, err := net.ParseCIDR(n); err == nil && ipNet.Contains(ip) {
+				neighbors[set.Name] = true
+			}
+		}
+	}
+
+	statements := make([]*api.Statement, 0, len(policy.Statements))
+	for _, statement := range policy.Statements {
+		if statement.Conditions == nil {
+			statement.Conditions = &api.Conditions{}
+		}
+		if m := statement.Conditions.NeighborSet; m != nil && neighbors[m.Name] == (m.MatchType == api.MatchType_INVERT) {
+			continue
+		}
+		statement.Conditions.NeighborSet = &api.MatchSet{
+			MatchType: api.MatchType_ANY,
+			Name:      policy.Name,
+		}
+		statements = append(statements, statement)
+	}
+	policy.Statements = statements
+
+	return append(sets, &api.DefinedSet{
+		DefinedType: api.DefinedType_NEIGHBOR,
+		Name:        policy.Name,
+		List:        []string{prefix},
+	}), policy
+}
+
+// setPolicyAssignments sets the global assignments, imports go after the local routes accepted
+// and the routes of the peers with rejectImport rejected, so no BgpPolicy lets those in, exports
+// after the routes of the mesh rejected for the other peers and the nexthop self.
+func (b *Bgp) setPolicyAssignments(imports, exports []string) error {
+	policies := func(names ...string) []*api.Policy {
+		result := make([]*api.Policy, 0, len(names))
+		for _, name := range names {
+			result = append(result, &api.Policy{Name: name})
+		}
+		return result
+	}
+
+	err := b.bgpServer.SetPolicyAssignment(context.Background(), &api.SetPolicyAssignmentRequest{
+		Assignment: &api.PolicyAssignment{
+			Name:          globalPolicyAssignment,
+			Direction:     api.PolicyDirection_IMPORT,
+			Policies:      policies(append([]string{acceptLocalPolicy, rejectImportPolicy}, imports...)...),
+			DefaultAction: api.RouteAction_ACCEPT,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return b.bgpServer.SetPolicyAssignment(context.Background(), &api.SetPolicyAssignmentRequest{
+		Assignment: &api.PolicyAssignment{
+			Name:          globalPolicyAssignment,
+			Direction:     api.PolicyDirection_EXPORT,
+			Policies:      policies(append([]string{localOnlyPolicy, nexthopSelfPolicy}, exports...)...),
+			DefaultAction: api.RouteAction_ACCEPT,
+		},
+	})
+}
+
+// applyPolicies replaces the policies of the BgpPolicies in gobgp if the attached ones changed,
+// and sends the routes of all the peers through them again. gobgp refuses to delete the policies
+// and sets in use, so they are detached first. It must be called with the confLock held.
+func (b *Bgp) applyPolicies() error {
+	if b.conf == nil {
+		return nil
+	}
+
+	desired := b.desiredPolicies()
+	if b.appliedPolicies != nil && b.appliedPolicies.equal(desired) {
+		return nil
+	}
+
+	if old := b.appliedPolicies; old != nil {
+		if err := b.setPolicyAssignments(nil, nil); err != nil {
+			return err
+		}
+		// Those of a partial state may not have been added
+		for _, policy := range old.policies {
+			b.bgpServer.DeletePolicy(context.Background(), &api.DeletePolicyRequest{
+				Policy: &api.Policy{Name: policy.Name},
+				All:    true,
+			})
+		}
+		for _, set := range old.sets {
+			b.bgpServer.DeleteDefinedSet(context.Background(), &api.DeleteDefinedSetRequest{
+				DefinedSet: &api.DefinedSet{
+					DefinedType: set.DefinedType,
+					Name:        set.Name,
+				},
+				All: true,
+			})
+		}
+	}
+
+	desired.partial = true
+	b.appliedPolicies = desired
+	for _, set := range desired.sets {
+		err := b.bgpServer.AddDefinedSet(context.Background(), &api.AddDefinedSetRequest{
+			DefinedSet: set,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add defined set %s, %v", set.Name, err)
+		}
+	}
+	for _, policy := range desired.policies {
+		err := b.bgpServer.AddPolicy(context.Background(), &api.AddPolicyRequest{
+			Policy: policy,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add policy %s, %v", policy.Name, err)
+		}
+	}
+	if err := b.setPolicyAssignments(desired.imports, desired.exports); err != nil {
+		return err
+	}
+	desired.partial = false
+
+	// The routes already received and sent went through the old policies
+	b.bgpServer.ResetPeer(context.Background(), &api.ResetPeerRequest{
+		Address:   "all",
+		Soft:      true,
+		Direction: api.ResetPeerRequest_BOTH,
+	})
+
+	return nil
+}
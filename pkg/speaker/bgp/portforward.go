@@ -0,0 +1,138 @@
+package bgp
+
+import (
+	"fmt"
+	"net"
+
+	bgpapi "github.com/openelb/openelb/api/v1alpha2"
+	"github.com/openelb/openelb/pkg/nettool"
+	"github.com/openelb/openelb/pkg/nettool/iptables"
+	"github.com/vishvananda/netlink"
+)
+
+// bgpPort is the port the peers open the sessions to, it needs no port forward
+const bgpPort = 179
+
+// portForward is the DNAT of the sessions a router opens to port 179 of the node to the listen port of gobgp.
+type portForward struct {
+	router string
+	local  string
+	port   int32
+}
+
+// routeSource returns the address of the node on the route to ip.
+func routeSource(ip net.IP) (string, error) {
+	routes, err := netlink.RouteGet(ip)
+	if err != nil || len(routes) == 0 || routes[0].Src == nil {
+		return "", fmt.Errorf("no route to %s, err=%v", ip, err)
+	}
+
+	return routes[0].Src.String(), nil
+}
+
+// desiredPortForward returns the port forward of neighbor, nil if it needs none.
+// It must be called with the confLock held.
+func (b *Bgp) desiredPortForward(neighbor *bgpapi.BgpPeer, delete bool) (*portForward, error) {
+	if delete || !neighbor.Spec.UsingPortForward || b.conf == nil {
+		return nil, nil
+	}
+	port := b.conf.ListenPort
+	if port <= 0 || port == bgpPort {
+		return nil, nil
+	}
+
+	router := net.ParseIP(neighbor.Spec.Conf.NeighborAddress)
+	if router == nil || router.To4() == nil {
+		return nil, fmt.Errorf("field Spec.UsingPortForward only supports IPv4 neighbor addresses")
+	}
+
+	var local string
+	if neighbor.Spec.Transport != nil {
+		local = neighbor.Spec.Transport.LocalAddress
+	}
+	if local == "" {
+		var err error
+		if local, err = routeSource(router); err != nil {
+			return nil, err
+		}
+	}
+
+	return &portForward{
+		router: router.String(),
+		local:  local,
+		port:   port,
+	}, nil
+}
+
+func (b *Bgp) getIPTables() (iptables.IptablesIface, error) {
+	if b.ipt == nil {
+		ipt, err := iptables.New()
+		if err != nil {
+			return nil, err
+		}
+		b.ipt = ipt
+	}
+
+	return b.ipt, nil
+}
+
+// setPortForward replaces the port forward of the peer at address with desired. The chain of the
+// port forwards is created with the first one and deleted with the last one.
+// It must be called with the confLock held.
+func (b *Bgp) setPortForward(address string, desired *portForward) error {
+	old, ok := b.portForwards[address]
+	if (!ok && desired == nil) || (ok && desired != nil && *old == *desired) {
+		return nil
+	}
+
+	ipt, err := b.getIPTables()
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		err = nettool.DeletePortForwardOfBGP(ipt, old.router, old.local, old.port)
+		if err != nil {
+			return err
+		}
+		delete(b.portForwards, address)
+	}
+
+	if desired != nil {
+		if len(b.portForwards) == 0 {
+			if err = nettool.NewChainOfBGP(ipt); err != nil {
+				return err
+			}
+		}
+		err = nettool.AddPortForwardOfBGP(ipt, desired.router, desired.local, desired.port)
+		if err != nil {
+			return err
+		}
+		b.portForwards[address] = desired
+	}
+
+	if len(b.portForwards) == 0 {
+		return nettool.DeleteChainOfBGP(ipt)
+	}
+
+	return nil
+}
+
+// deletePortForwards removes all the port forwards with their chain, when gobgp stops.
+// It must be called with the confLock held.
+func (b *Bgp) deletePortForwards() error {
+	if len(b.portForwards) == 0 {
+		return nil
+	}
+
+	ipt, err := b.getIPTables()
+	if err != nil {
+		return err
+	}
+	if err = nettool.DeleteChainOfBGP(ipt); err != nil {
+		return err
+	}
+	b.portForwards = make(map[string]*portForward)
+
+	return nil
+}
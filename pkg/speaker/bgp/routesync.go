@@ -0,0 +1,142 @@
+package bgp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/openelb/openelb/api/v1alpha2"
+	"github.com/openelb/openelb/pkg/metrics"
+	"github.com/openelb/openelb/pkg/util"
+	api "github.com/osrg/gobgp/api"
+)
+
+// route is the desired announcement of a prefix, as passed to the last setBalancer.
+type route struct {
+	ip       string
+	prefix   uint32
+	nexthops []string
+	weights  map[string]uint32
+	attrs    *v1alpha2.PathAttributes
+}
+
+func routeKey(ip string, prefix uint32) string {
+	if addr := net.ParseIP(ip); addr != nil {
+		ip = addr.String()
+	}
+
+	return fmt.Sprintf("%s/%d", ip, prefix)
+}
+
+// isLocalPath reports whether path was originated by this speaker rather than learned from a peer.
+func isLocalPath(path *api.Path) bool {
+	return net.ParseIP(path.NeighborIp) == nil
+}
+
+func (b *Bgp) runRouteSync(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(b.routeSyncPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			missing, orphan, err := b.syncRoutes()
+			if err != nil {
+				b.log.Error(err, "failed to sync routes")
+				continue
+			}
+			if missing+orphan > 0 {
+				b.log.Info("corrected route drift", "missing", missing, "orphan", orphan)
+			}
+		}
+	}
+}
+
+// syncRoutes compares the local paths in the global rib with the desired routes,
+// then adds the missing paths and withdraws the orphan ones.
+// Paths whose attributes drifted are counted as missing, adding them again replaces them.
+// The desired routes are those the lb controller last set from the Services, it reconciles
+// all of them every route sync period to set them again.
+func (b *Bgp) syncRoutes() (missing, orphan int, err error) {
+	b.routeLock.Lock()
+	defer b.routeLock.Unlock()
+
+	global, err := b.ready()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// attributes of the local paths in the rib, keyed by prefix and nexthop
+	current := make(map[string]map[string]string)
+	var orphans []*api.Path
+	for _, family := range []*api.Family{
+		{Afi: api.Family_AFI_IP, Safi: api.Family_SAFI_UNICAST},
+		{Afi: api.Family_AFI_IP6, Safi: api.Family_SAFI_UNICAST},
+	} {
+		fn := func(d *api.Destination) {
+			for _, path := range d.Paths {
+				if !isLocalPath(path) {
+					continue
+				}
+
+				ip, prefix := parsePrefix(d.Prefix)
+				key := routeKey(ip, prefix)
+				nexthop := fromAPIPath(path).String()
+				if r, ok := b.routes[key]; !ok || !util.ContainsString(r.nexthops, nexthop) {
+					// The NLRI inside MP_REACH_NLRI of a listed v6 path has no identifier, rebuild the path instead
+					withdraw := toAPIPath(ip, prefix, nexthop, 0, 0, nil)
+					withdraw.Identifier = path.Identifier
+					orphans = append(orphans, withdraw)
+					continue
+				}
+				if current[key] == nil {
+					current[key] = make(map[string]string)
+				}
+				current[key][nexthop] = getPathAttributes(path)
+			}
+		}
+		err = b.bgpServer.ListPath(context.Background(), &api.ListPathRequest{
+			TableType: api.TableType_GLOBAL,
+			Family:    family,
+		}, fn)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	for _, path := range orphans {
+		err = b.bgpServer.DeletePath(context.Background(), &api.DeletePathRequest{
+			Path: path,
+		})
+		if err != nil {
+			return missing, orphan, err
+		}
+		orphan++
+	}
+
+	for key, r := range b.routes {
+		extra, err := r.attrs.ToGoBgpPathAttributes(global.As)
+		if err != nil {
+			return missing, orphan, err
+		}
+
+		paths := toAPIPaths(r.ip, r.prefix, r.nexthops, global.As, r.weights, extra)
+		var toAdd []string
+		for nexthop, path := range paths {
+			if attrs, ok := current[key][nexthop]; !ok || attrs != getPathAttributes(path) {
+				toAdd = append(toAdd, nexthop)
+			}
+		}
+		if err = b.addMultiRoutes(paths, toAdd); err != nil {
+			return missing, orphan, err
+		}
+		missing += len(toAdd)
+	}
+
+	metrics.UpdateRouteDriftMetrics(util.GetNodeName(), missing, orphan)
+
+	return missing, orphan, nil
+}
@@ -0,0 +1,74 @@
+package bgp
+
+import (
+	"github.com/openelb/openelb/pkg/metrics"
+	"github.com/openelb/openelb/pkg/util"
+	api "github.com/osrg/gobgp/api"
+	"golang.org/x/net/context"
+)
+
+// peerEventsSize is the number of peer state events buffered for the controller,
+// the ones exceeding it are dropped and left to the periodic status sync.
+const peerEventsSize = 64
+
+// PeerStateEvent is a session entering or leaving the established state.
+type PeerStateEvent struct {
+	// Address is the neighbor address, the one learned for the peers over interfaces
+	Address string
+	// Interface is the neighbor interface of the peers over interfaces
+	Interface string
+	// State is the new session state, as in NodePeerStatus
+	State string
+}
+
+// PeerStateEvents returns the channel the peer state events are sent to.
+func (b *Bgp) PeerStateEvents() <-chan PeerStateEvent {
+	return b.peerEvents
+}
+
+// watchPeerState subscribes to the peer state changes of gobgp, which survives the restarts of gobgp.
+func (b *Bgp) watchPeerState(stopCh <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+
+	err := b.bgpServer.MonitorPeer(ctx, &api.MonitorPeerRequest{}, b.onPeerState)
+	if err != nil {
+		b.log.Error(err, "failed to watch peer state")
+	}
+}
+
+// onPeerState only passes on the sessions entering or leaving the established state, a peer down
+// goes through the other states on every connect retry.
+func (b *Bgp) onPeerState(peer *api.Peer) {
+	e := PeerStateEvent{
+		Address:   peer.State.NeighborAddress,
+		Interface: peer.Conf.NeighborInterface,
+		State:     peer.State.SessionState.String(),
+	}
+	b.log.Info("bgp peer state changed", "peer", e.Address, "interface", e.Interface, "state", e.State)
+
+	peerIP := e.Address
+	if e.Interface != "" {
+		peerIP = e.Interface
+	}
+	established := peer.State.SessionState == api.PeerState_ESTABLISHED
+	b.stateLock.Lock()
+	wasEstablished := b.peerStates[peerIP] == api.PeerState_ESTABLISHED
+	b.peerStates[peerIP] = peer.State.SessionState
+	b.stateLock.Unlock()
+	if established == wasEstablished {
+		return
+	}
+
+	if wasEstablished {
+		metrics.UpdateBGPSessionFlapMetrics(peerIP, util.GetNodeName())
+	}
+
+	select {
+	case b.peerEvents <- e:
+	default:
+	}
+}
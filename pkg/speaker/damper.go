@@ -0,0 +1,257 @@
+package speaker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// retryBase and retryMax bound the backoff of the deferred changes the wrapped speaker failed to apply
+	retryBase = time.Second
+	retryMax  = time.Minute
+)
+
+// Deferrer is implemented by the speakers which may apply a SetBalancer later, see Damper.
+type Deferrer interface {
+	// Pending returns how long until the deferred changes of ip are applied, 0 if there are none
+	Pending(ip string) time.Duration
+}
+
+// damped is the state of one ip behind the Damper.
+type damped struct {
+	// applied are the names of the nexthops the wrapped speaker announces
+	applied map[string]struct{}
+	desired []corev1.Node
+	options *BalancerOptions
+	timer   *time.Timer
+	// deadline is when the pending timer fires
+	deadline time.Time
+	// holding is true if the pending timer withdraws the last nexthop
+	holding bool
+	// retries counts the failed applies of the pending timer
+	retries int
+	// generation invalidates timers which fired while being stopped
+	generation int
+}
+
+func (e *damped) stop() {
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	e.holding = false
+	e.retries = 0
+	e.generation++
+}
+
+// Damper sits between the lb controller and a Speaker.
+// New nexthops are only passed on once no other nexthop was added for the whole debounce window,
+// and the last nexthop of an ip is kept for the hold-down duration before it is withdrawn.
+// Other removals are passed on immediately. The deferred changes the speaker fails to apply
+// are retried with backoff.
+type Damper struct {
+	s        Speaker
+	debounce time.Duration
+	holdDown time.Duration
+	log      logr.Logger
+
+	lock    sync.Mutex
+	entries map[string]*damped
+}
+
+// NewDamper returns s itself if both debounce and hold-down are disabled.
+func NewDamper(s Speaker, opts *Options) Speaker {
+	if opts == nil || (opts.DebounceWindow <= 0 && opts.HoldDown <= 0) {
+		return s
+	}
+
+	return &Damper{
+		s:        s,
+		debounce: opts.DebounceWindow,
+		holdDown: opts.HoldDown,
+		log:      ctrl.Log.WithName("damper"),
+		entries:  make(map[string]*damped),
+	}
+}
+
+func (d *Damper) SetBalancer(ip string, nexthops []corev1.Node, options *BalancerOptions) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	e, ok := d.entries[ip]
+	if !ok {
+		e = &damped{
+			applied: make(map[string]struct{}),
+		}
+		d.entries[ip] = e
+	}
+	previous := make(map[string]struct{})
+	for _, node := range e.desired {
+		previous[node.Name] = struct{}{}
+	}
+	e.desired = nexthops
+	e.options = options
+
+	var kept []corev1.Node
+	added := 0
+	// fresh is true if a nexthop was added since the last call, it restarts the debounce window
+	fresh := false
+	for _, node := range nexthops {
+		if _, ok := e.applied[node.Name]; ok {
+			kept = append(kept, node)
+			continue
+		}
+		added++
+		if _, ok := previous[node.Name]; !ok {
+			fresh = true
+		}
+	}
+
+	switch {
+	case len(nexthops) == 0:
+		if len(e.applied) == 0 || d.holdDown <= 0 {
+			e.stop()
+			return d.apply(ip, e, nexthops)
+		}
+		if !e.holding {
+			// The pending debounce or retry would withdraw it before the hold-down ends
+			e.stop()
+			d.schedule(ip, e, d.holdDown)
+			e.holding = true
+		}
+		return nil
+	case added == 0 || d.debounce <= 0:
+		e.stop()
+		return d.apply(ip, e, nexthops)
+	}
+
+	// Withdraw the removed nexthops now, unless it is the last one and should be held
+	if len(kept) != len(e.applied) && (len(kept) > 0 || d.holdDown <= 0) {
+		if err := d.apply(ip, e, kept); err != nil {
+			return err
+		}
+	}
+	if e.timer == nil || e.holding || fresh {
+		e.stop()
+		d.schedule(ip, e, d.debounce)
+	}
+
+	return nil
+}
+
+// Pending returns how long until the deferred changes of ip are passed on, 0 if there are none.
+func (d *Damper) Pending(ip string) time.Duration {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	e, ok := d.entries[ip]
+	if !ok || e.timer == nil {
+		return 0
+	}
+	if delay := time.Until(e.deadline); delay > 0 {
+		return delay
+	}
+
+	// The timer is firing
+	return time.Millisecond
+}
+
+// Balancers returns the ips of the wrapped speaker, and the ones whose changes are deferred.
+func (d *Damper) Balancers() []string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	ips := make(map[string]struct{})
+	for ip := range d.entries {
+		ips[ip] = struct{}{}
+	}
+	if l, ok := d.s.(Lister); ok {
+		for _, ip := range l.Balancers() {
+			ips[ip] = struct{}{}
+		}
+	}
+
+	var result []string
+	for ip := range ips {
+		result = append(result, ip)
+	}
+
+	return result
+}
+
+func (d *Damper) DelBalancer(ip string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if e, ok := d.entries[ip]; ok {
+		e.stop()
+		delete(d.entries, ip)
+	}
+
+	return d.s.DelBalancer(ip)
+}
+
+func (d *Damper) Start(stopCh <-chan struct{}) error {
+	if err := d.s.Start(stopCh); err != nil {
+		return err
+	}
+
+	go func() {
+		<-stopCh
+
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		for _, e := range d.entries {
+			e.stop()
+		}
+	}()
+
+	return nil
+}
+
+// apply must be called with the lock held, the options are the last ones of ip.
+func (d *Damper) apply(ip string, e *damped, nexthops []corev1.Node) error {
+	if err := d.s.SetBalancer(ip, nexthops, e.options); err != nil {
+		return err
+	}
+
+	e.applied = make(map[string]struct{})
+	for _, node := range nexthops {
+		e.applied[node.Name] = struct{}{}
+	}
+
+	return nil
+}
+
+// schedule must be called with the lock held.
+func (d *Damper) schedule(ip string, e *damped, delay time.Duration) {
+	generation := e.generation
+	e.deadline = time.Now().Add(delay)
+	e.timer = time.AfterFunc(delay, func() {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+
+		if d.entries[ip] != e || e.generation != generation {
+			return
+		}
+		e.timer = nil
+		e.holding = false
+		if err := d.apply(ip, e, e.desired); err != nil {
+			// Nothing else calls back for the deferred changes, so retry them here
+			retry := retryBase << e.retries
+			if retry > retryMax || retry <= 0 {
+				retry = retryMax
+			} else {
+				e.retries++
+			}
+			d.log.Error(err, "failed to apply damped nexthops, retry later", "ip", ip, "after", retry)
+			d.schedule(ip, e, retry)
+			return
+		}
+		e.retries = 0
+	})
+}
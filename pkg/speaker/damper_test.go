@@ -0,0 +1,153 @@
+package speaker_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/openelb/openelb/pkg/speaker"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func nodes(names ...string) []corev1.Node {
+	var result []corev1.Node
+	for _, name := range names {
+		result = append(result, corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	return result
+}
+
+// flaky fails the first calls of SetBalancer.
+type flaky struct {
+	*speaker.Fake
+	failures int32
+}
+
+func (f *flaky) SetBalancer(ip string, nexthops []corev1.Node, options *speaker.BalancerOptions) error {
+	if atomic.AddInt32(&f.failures, -1) >= 0 {
+		return errors.New("flaky")
+	}
+	return f.Fake.SetBalancer(ip, nexthops, options)
+}
+
+var _ = Describe("Damper", func() {
+	const (
+		ip     = "10.0.0.1"
+		window = 200 * time.Millisecond
+	)
+
+	var (
+		fake   *speaker.Fake
+		damper speaker.Speaker
+		stopCh chan struct{}
+	)
+
+	BeforeEach(func() {
+		fake = speaker.NewFake()
+		damper = speaker.NewDamper(fake, &speaker.Options{
+			DebounceWindow: window,
+			HoldDown:       window,
+		})
+		stopCh = make(chan struct{})
+		Expect(damper.Start(stopCh)).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		close(stopCh)
+	})
+
+	It("Should return the speaker itself if disabled", func() {
+		Expect(speaker.NewDamper(fake, speaker.NewOptions())).Should(BeIdenticalTo(fake))
+	})
+
+	It("Should announce new nexthops after the debounce window", func() {
+		Expect(damper.SetBalancer(ip, nodes("node1"), nil)).ShouldNot(HaveOccurred())
+		Expect(fake.Equal(ip, nil)).Should(BeTrue())
+		Eventually(func() bool { return fake.Equal(ip, []string{"node1"}) }, 3*window).Should(BeTrue())
+
+		Expect(damper.SetBalancer(ip, nodes("node1", "node2"), nil)).ShouldNot(HaveOccurred())
+		Consistently(func() bool { return fake.Equal(ip, []string{"node1"}) }, window/2).Should(BeTrue())
+		Eventually(func() bool { return fake.Equal(ip, []string{"node1", "node2"}) }, 3*window).Should(BeTrue())
+	})
+
+	It("Should not announce nexthops which flap within the debounce window", func() {
+		Expect(damper.SetBalancer(ip, nodes("node1"), nil)).ShouldNot(HaveOccurred())
+		Eventually(func() bool { return fake.Equal(ip, []string{"node1"}) }, 3*window).Should(BeTrue())
+
+		Expect(damper.SetBalancer(ip, nodes("node1", "node2"), nil)).ShouldNot(HaveOccurred())
+		Expect(damper.SetBalancer(ip, nodes("node1"), nil)).ShouldNot(HaveOccurred())
+		Consistently(func() bool { return fake.Equal(ip, []string{"node1"}) }, 2*window).Should(BeTrue())
+	})
+
+	It("Should withdraw removed nexthops immediately", func() {
+		Expect(damper.SetBalancer(ip, nodes("node1", "node2"), nil)).ShouldNot(HaveOccurred())
+		Eventually(func() bool { return fake.Equal(ip, []string{"node1", "node2"}) }, 3*window).Should(BeTrue())
+
+		Expect(damper.SetBalancer(ip, nodes("node1"), nil)).ShouldNot(HaveOccurred())
+		Expect(fake.Equal(ip, []string{"node1"})).Should(BeTrue())
+	})
+
+	It("Should hold the last nexthop before withdrawing it", func() {
+		Expect(damper.SetBalancer(ip, nodes("node1"), nil)).ShouldNot(HaveOccurred())
+		Eventually(func() bool { return fake.Equal(ip, []string{"node1"}) }, 3*window).Should(BeTrue())
+
+		Expect(damper.SetBalancer(ip, nil, nil)).ShouldNot(HaveOccurred())
+		Expect(fake.Equal(ip, []string{"node1"})).Should(BeTrue())
+		Eventually(func() bool { return fake.Equal(ip, nil) }, 3*window).Should(BeTrue())
+	})
+
+	It("Should keep the route if the nexthop comes back within the hold-down", func() {
+		Expect(damper.SetBalancer(ip, nodes("node1"), nil)).ShouldNot(HaveOccurred())
+		Eventually(func() bool { return fake.Equal(ip, []string{"node1"}) }, 3*window).Should(BeTrue())
+
+		Expect(damper.SetBalancer(ip, nil, nil)).ShouldNot(HaveOccurred())
+		Expect(damper.SetBalancer(ip, nodes("node1"), nil)).ShouldNot(HaveOccurred())
+		Consistently(func() bool { return fake.Equal(ip, []string{"node1"}) }, 2*window).Should(BeTrue())
+	})
+
+	It("Should delete the balancer immediately", func() {
+		Expect(damper.SetBalancer(ip, nodes("node1"), nil)).ShouldNot(HaveOccurred())
+		Expect(damper.DelBalancer(ip)).ShouldNot(HaveOccurred())
+		Consistently(func() bool { return fake.Equal(ip, nil) }, 2*window).Should(BeTrue())
+	})
+
+	It("Should restart the debounce window when a nexthop is added", func() {
+		Expect(damper.SetBalancer(ip, nodes("node1"), nil)).ShouldNot(HaveOccurred())
+		time.Sleep(window / 2)
+		Expect(damper.SetBalancer(ip, nodes("node1", "node2"), nil)).ShouldNot(HaveOccurred())
+		Consistently(func() bool { return fake.Equal(ip, nil) }, window*3/4).Should(BeTrue())
+		Eventually(func() bool { return fake.Equal(ip, []string{"node1", "node2"}) }, 3*window).Should(BeTrue())
+	})
+
+	It("Should report the pending changes", func() {
+		deferrer := damper.(speaker.Deferrer)
+		Expect(damper.SetBalancer(ip, nodes("node1"), nil)).ShouldNot(HaveOccurred())
+		Expect(deferrer.Pending(ip)).Should(BeNumerically(">", 0))
+		Expect(deferrer.Pending(ip)).Should(BeNumerically("<=", window))
+		Eventually(func() time.Duration { return deferrer.Pending(ip) }, 3*window).Should(BeZero())
+		Expect(fake.Equal(ip, []string{"node1"})).Should(BeTrue())
+	})
+
+	It("Should list the deferred balancers with those of the speaker", func() {
+		Expect(fake.SetBalancer("10.0.0.2", nodes("node1"), nil)).ShouldNot(HaveOccurred())
+		Expect(damper.SetBalancer(ip, nodes("node1"), nil)).ShouldNot(HaveOccurred())
+		Expect(damper.(speaker.Lister).Balancers()).Should(ConsistOf(ip, "10.0.0.2"))
+	})
+
+	It("Should retry the nexthops the speaker failed to apply", func() {
+		failing := &flaky{Fake: speaker.NewFake(), failures: 1}
+		damper := speaker.NewDamper(failing, &speaker.Options{
+			DebounceWindow: window,
+		})
+		Expect(damper.Start(stopCh)).ShouldNot(HaveOccurred())
+
+		Expect(damper.SetBalancer(ip, nodes("node1"), nil)).ShouldNot(HaveOccurred())
+		Eventually(func() int32 { return atomic.LoadInt32(&failing.failures) }, 3*window).Should(BeZero())
+		Expect(failing.Equal(ip, nil)).Should(BeTrue())
+		Expect(damper.(speaker.Deferrer).Pending(ip)).Should(BeNumerically(">", 0))
+		Eventually(func() bool { return failing.Equal(ip, []string{"node1"}) }, 5*time.Second).Should(BeTrue())
+	})
+})
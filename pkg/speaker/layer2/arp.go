@@ -179,7 +179,7 @@ func (a *arpSpeaker) gratuitous(ip, nodeIP net.IP) error {
 	return nil
 }
 
-func (a *arpSpeaker) SetBalancer(ip string, nodes []corev1.Node) error {
+func (a *arpSpeaker) SetBalancer(ip string, nodes []corev1.Node, _ *speaker.BalancerOptions) error {
 	if nodes[0].Annotations != nil {
 		nexthop := nodes[0].Annotations[constant.OpenELBLayer2Annotation]
 		// check for valid CIDR range
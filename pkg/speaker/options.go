@@ -0,0 +1,24 @@
+package speaker
+
+import (
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+type Options struct {
+	DebounceWindow time.Duration
+	HoldDown       time.Duration
+}
+
+func NewOptions() *Options {
+	return &Options{
+		DebounceWindow: 0,
+		HoldDown:       0,
+	}
+}
+
+func (options *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.DurationVar(&options.DebounceWindow, "debounce-window", options.DebounceWindow, "DebounceWindow is the duration a new nexthop of a service must stay before it is announced, 0 announces it immediately.")
+	fs.DurationVar(&options.HoldDown, "hold-down", options.HoldDown, "HoldDown is the duration the last nexthop of a service is kept before the route is withdrawn, 0 withdraws it immediately.")
+}
@@ -1,17 +1,33 @@
 package speaker
 
 import (
+	"github.com/openelb/openelb/api/v1alpha2"
 	"github.com/projectcalico/libcalico-go/lib/set"
 	corev1 "k8s.io/api/core/v1"
 	"sync"
 )
 
+// BalancerOptions are the settings of a balancer besides its nexthops, nil means none.
+// The speakers ignore the ones they do not support.
+type BalancerOptions struct {
+	// PathAttributes of the routes announced by the bgp speaker
+	PathAttributes *v1alpha2.PathAttributes
+	// Weights of the nexthops by node name for weighted ECMP, a missing or zero weight means unweighted
+	Weights map[string]uint32
+}
+
 type Speaker interface {
-	SetBalancer(ip string, nexthops []corev1.Node) error
+	SetBalancer(ip string, nexthops []corev1.Node, options *BalancerOptions) error
 	DelBalancer(ip string) error
 	Start(stopCh <-chan struct{}) error
 }
 
+// Lister is implemented by the speakers which can list what they announce.
+type Lister interface {
+	// Balancers returns the ips and prefixes set by SetBalancer and not deleted since
+	Balancers() []string
+}
+
 type Fake struct {
 	lock     sync.Mutex
 	nextHops map[string]set.Set
@@ -23,7 +39,7 @@ func NewFake() *Fake {
 	}
 }
 
-func (f *Fake) SetBalancer(ip string, nexthops []corev1.Node) error {
+func (f *Fake) SetBalancer(ip string, nexthops []corev1.Node, _ *BalancerOptions) error {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
@@ -73,6 +89,18 @@ func (f *Fake) DelBalancer(ip string) error {
 	return nil
 }
 
+func (f *Fake) Balancers() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var result []string
+	for ip := range f.nextHops {
+		result = append(result, ip)
+	}
+
+	return result
+}
+
 func (f *Fake) Start(stopCh <-chan struct{}) error {
 	return nil
 }
@@ -0,0 +1,13 @@
+package speaker_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestSpeaker(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Speaker Suite")
+}
@@ -28,7 +28,7 @@ type KeepAlivedConfig struct {
 	Args []string
 }
 
-func (k *KeepAlived) SetBalancer(configMap string, nexthops []corev1.Node) error {
+func (k *KeepAlived) SetBalancer(configMap string, nexthops []corev1.Node, _ *speaker.BalancerOptions) error {
 	ip := strings.SplitN(configMap, ":", 2)
 	k.cm = &corev1.ConfigMap{
 		TypeMeta: metav1.TypeMeta{
@@ -65,11 +65,19 @@ func (k *KeepAlived) SetBalancer(configMap string, nexthops []corev1.Node) error
 
 func (k *KeepAlived) DelBalancer(configMap string) error {
 	var err error
-	if _, err = k.clientset.CoreV1().ConfigMaps(k.cm.ObjectMeta.Namespace).Get(context.TODO(), k.cm.ObjectMeta.Name, metav1.GetOptions{}); err == nil {
+	var cm *corev1.ConfigMap
+	if cm, err = k.clientset.CoreV1().ConfigMaps(util.EnvNamespace()).Get(context.TODO(), constant.OpenELBVipConfigMap, metav1.GetOptions{}); err == nil {
+		// Nothing set since the manager started, e.g. a withdrawn service
+		if k.cm == nil {
+			k.cm = cm
+		}
 		ip := strings.SplitN(configMap, ":", 2)
 		delete(k.cm.Data, ip[0])
 		k.cm, err = k.clientset.CoreV1().ConfigMaps(k.cm.ObjectMeta.Namespace).Update(context.TODO(), k.cm, metav1.UpdateOptions{})
 	}
+	if errors.IsNotFound(err) {
+		return nil
+	}
 	return err
 }
 
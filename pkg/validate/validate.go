@@ -47,3 +47,24 @@ func HasOpenELBDefaultEipAnnotation(annotation map[string]string) bool {
 	}
 	return false
 }
+
+func HasOpenELBWeightedECMPAnnotation(annotation map[string]string) bool {
+	if annotation == nil {
+		return false
+	}
+	if value, ok := annotation[constant.OpenELBWeightedECMPAnnotationKey]; ok {
+		return strings.ToLower(value) == "true"
+	}
+	return false
+}
+
+// HasOpenELBWithdrawAnnotation returns whether the annotation is set, and its value.
+func HasOpenELBWithdrawAnnotation(annotation map[string]string) (bool, bool) {
+	if annotation == nil {
+		return false, false
+	}
+	if value, ok := annotation[constant.OpenELBWithdrawAnnotationKey]; ok {
+		return true, strings.ToLower(value) == "true"
+	}
+	return false, false
+}